@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Output of the sort, a file is written next to its target and replaces it on commit,
+// so the target may be one of the inputs and is never left half written
+type output struct {
+	io.Writer
+	file   *os.File
+	target string
+}
+
+func createOutput(path string) (*output, error) {
+	if path == "" {
+		return &output{Writer: os.Stdout}, nil
+	}
+
+	// A symlink keeps pointing to the result, the file it points to is replaced
+	target, err := filepath.EvalSymlinks(path)
+	if errors.Is(err, os.ErrNotExist) {
+		target = path
+	} else if err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+
+	// The result keeps the permissions and, where it may, the owner of the file it replaces
+	mode := os.FileMode(0644)
+	info, err := os.Stat(target)
+	if err == nil {
+		mode = info.Mode().Perm()
+		err = keepOwner(file, info)
+	} else if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	if err := errors.Join(err, file.Chmod(mode)); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	return &output{Writer: file, file: file, target: target}, nil
+}
+
+func (o *output) commit() error {
+	if o.file == nil {
+		return nil
+	}
+
+	file := o.file
+	o.file = nil
+
+	if err := errors.Join(file.Sync(), file.Close()); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	if err := os.Rename(file.Name(), o.target); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return nil
+}
+
+// Drops the output unless it was committed
+func (o *output) discard() {
+	if o.file == nil {
+		return
+	}
+
+	o.file.Close()
+	os.Remove(o.file.Name())
+	o.file = nil
+}
+
+// Inputs given as arguments, - is the standard input
+func openInputs(names []string) ([]io.Reader, func(), error) {
+	var files []*os.File
+	closeAll := func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}
+
+	inputs := make([]io.Reader, len(names))
+	for idx, name := range names {
+		if name == "-" {
+			inputs[idx] = os.Stdin
+			continue
+		}
+
+		file, err := os.Open(name)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		files = append(files, file)
+		inputs[idx] = file
+	}
+
+	return inputs, closeAll, nil
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import (
+	"io/fs"
+	"os"
+)
+
+// Files get the owner of the process that creates them, there is nothing to keep
+func keepOwner(file *os.File, info fs.FileInfo) error {
+	return nil
+}
@@ -1,383 +1,289 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
-	"io"
 	"os"
-	"slices"
-	"strconv"
 	"strings"
 	"unicode"
-)
 
-type sortItem struct {
-	val          string
-	sortKeyStr   string
-	sortKeyFloat float64
-	isNumber     bool
-}
+	"sortUtil/sorting"
+)
 
 type config struct {
-	inputFile        string
-	outputFile       string
-	column           int
-	sep              string
-	numSort          bool
-	reverse          bool
-	uniqueOutput     bool
-	monthSort        bool
-	ignoreTrailSpace bool
-	checkSorted      bool
-	withSuffix       bool
+	// Inputs are read one after another, - is the standard input
+	inputFiles  []string
+	outputFile  string
+	checkSorted bool
+	checkQuiet  bool
+	// Inputs of -m are sorted already
+	merge  bool
+	sorter *sorting.Sorter
 }
 
-func sortStrData(arr []*sortItem) {
-	for idx := range arr {
-		arr[idx].sortKeyStr = strings.ToLower(arr[idx].sortKeyStr)
-	}
-
-	reverseASCII := func(a, b *sortItem) int {
-		for i := range min(len(a.sortKeyStr), len(b.sortKeyStr)) {
-			if a.sortKeyStr[i] != b.sortKeyStr[i] {
-				return 0
-			}
-
-			if a.val[i] == b.val[i] {
-				continue
-			}
-
-			if unicode.IsLower(rune(a.val[i])) {
-				return -1
-			}
-			return 1
+// Keys of -k, -K adds to the same list so that keys keep their order on the command line
+type keyList []*sorting.Key
 
-		}
-
-		if len(a.val) < len(b.val) {
-			return -1
-		}
-
-		return 1
+func (k *keyList) String() string {
+	var specs []string
+	for _, key := range *k {
+		specs = append(specs, key.String())
 	}
-
-	slices.SortFunc(arr, func(a, b *sortItem) int {
-		return strings.Compare(a.sortKeyStr, b.sortKeyStr)
-	})
-
-	slices.SortStableFunc(arr, reverseASCII)
+	return strings.Join(specs, " ")
 }
 
-func sortNumeric(arr []*sortItem) {
-	var nums []*sortItem
-	var NaNs []*sortItem
-
-	for idx := range arr {
-		if arr[idx].isNumber {
-			nums = append(nums, arr[idx])
-		} else {
-			NaNs = append(NaNs, arr[idx])
-		}
-	}
-
-	floatSort := func(a, b *sortItem) int {
-		if a.sortKeyFloat < b.sortKeyFloat {
-			return -1
-		} else if a.sortKeyFloat > b.sortKeyFloat {
-			return 1
-		}
-		return 0
+func (k *keyList) Set(value string) error {
+	key, err := sorting.ParseKey(value)
+	if err != nil {
+		return err
 	}
 
-	slices.SortFunc(nums, floatSort)
-	sortStrData(NaNs)
+	*k = append(*k, key)
+	return nil
+}
 
-	gIdx := 0
-	for idx := range nums {
-		arr[gIdx] = nums[idx]
-		gIdx++
-	}
+type namedKeyList struct {
+	keys *keyList
+}
 
-	for idx := range NaNs {
-		arr[gIdx] = NaNs[idx]
-		gIdx++
+func (k *namedKeyList) String() string {
+	if k.keys == nil {
+		return ""
 	}
+	return k.keys.String()
 }
 
-func getSortData(filename string) ([]string, error) {
-	file, err := os.Open(filename)
+func (k *namedKeyList) Set(value string) error {
+	key, err := sorting.ParseNamedKey(value)
 	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	sc := bufio.NewScanner(file)
-	lines := make([]string, 0)
-
-	for sc.Scan() {
-		lines = append(lines, sc.Text())
+		return err
 	}
 
-	if err = sc.Err(); err != nil {
-		return nil, err
-	}
-
-	return lines, nil
-
+	*k.keys = append(*k.keys, key)
+	return nil
 }
 
-func sendSorted(data []*sortItem, cfg *config) {
-	cur := 0
-	end := len(data)
-	incr := 1
-
-	if cfg.reverse {
-		end = 1
-		cur = len(data) - 1
-		incr = -1
-	}
-
-	var stream io.Writer = os.Stdout
-
-	if cfg.outputFile != "" {
-		file, err := os.Create(cfg.outputFile)
+// --time alone detects the layout of every timestamp, --time=LAYOUT sets it
+type timeFlag struct {
+	key    *sorting.Key
+	layout string
+}
 
-		if err != nil {
-			fmt.Println("Couldn't open output file")
-			os.Exit(1)
-		}
+func (t *timeFlag) String() string {
+	return t.layout
+}
 
-		stream = file
-		defer file.Close()
+func (t *timeFlag) Set(value string) error {
+	t.key.Time = value != "false"
+	t.layout = ""
+	if value != "true" && value != "false" {
+		t.layout = value
 	}
+	return nil
+}
 
-	var prev string
-	var startFlag = true
+// The flag package gives boolean flags a value only after =, as --time needs
+func (t *timeFlag) IsBoolFlag() bool {
+	return true
+}
 
-	for ; incr*cur < end; cur += incr {
-		if cfg.uniqueOutput && !startFlag && prev == data[cur].val {
+// GNU sort accepts keys glued to the flag like -k2,2n, the flag package doesn't
+func splitGluedKeys(args []string) []string {
+	var res []string
+	for _, arg := range args {
+		if len(arg) > 2 && strings.HasPrefix(arg, "-k") && unicode.IsDigit(rune(arg[2])) {
+			res = append(res, "-k", arg[2:])
 			continue
 		}
-
-		fmt.Fprintln(stream, data[cur].val)
-		startFlag = false
-		prev = data[cur].val
+		res = append(res, arg)
 	}
+	return res
 }
 
-func intoSortItems(data []string, cfg *config) []*sortItem {
-	var getKey func(string) string
+// GNU sort accepts options after file names, the flag package stops at the first one.
+// Returns the file names, everything after -- is a file name
+func parseArgs(args []string) []string {
+	var files []string
+	for {
+		flag.CommandLine.Parse(args)
+		rest := flag.Args()
 
-	if cfg.column < 0 {
-		getKey = func(s string) string {
-			return s
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(files, rest...)
 		}
-	} else {
-		getKey = func(s string) string {
-			if len(s) == 0 {
-				return s
-			}
-
-			spl := strings.Split(s, cfg.sep)
-			if len(spl) > cfg.column {
-				return spl[cfg.column]
-			}
-
-			return ""
+		if len(rest) == 0 {
+			return files
 		}
-	}
 
-	res := make([]*sortItem, len(data))
-	for i := range data {
-		res[i] = &sortItem{
-			val:        data[i],
-			sortKeyStr: getKey(data[i]),
-		}
+		files = append(files, rest[0])
+		args = rest[1:]
 	}
+}
 
-	if cfg.numSort || cfg.withSuffix {
-		tryToNumber(res, cfg.withSuffix)
-	}
+// Exit statuses of GNU sort, errors don't look like an unsorted input to -c and -C
+const (
+	exitUnsorted = 1
+	exitError    = 2
+)
 
-	if cfg.monthSort {
-		tryToMonth(res)
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(exitError)
 	}
-
-	return res
 }
 
-func tryToNumber(data []*sortItem, withSuffix bool) {
-	var getNum func(string) (float64, bool)
-
-	var defaultGetNum = func(s string) (float64, bool) {
-		num, err := strconv.ParseFloat(s, 64)
-		return num, err == nil
+func getConfig() *config {
+	cfg := &config{sorter: &sorting.Sorter{}}
+	s := cfg.sorter
+
+	var keys keyList
+	var defaults sorting.Key
+
+	flag.Var(&keys, "k", "Sort by key F[.C][OPTS][,F[.C][OPTS]], may be repeated (OPTS are b, g, h, M, n, R, r, t, V)")
+	sep := flag.String("s", " ", "Use as separator for columns (Default is single space)")
+	flag.BoolVar(&defaults.Numeric, "n", false, "Sort by numeric value")
+	flag.BoolVar(&defaults.Reverse, "r", false, "Sort in reverse")
+	flag.BoolVar(&s.Unique, "u", false, "Only display the first of lines with equal keys")
+	flag.BoolVar(&defaults.Month, "M", false, "Sort by month")
+	flag.BoolVar(&s.TrimSpace, "b", false, "Ignore trail space")
+	flag.BoolVar(&defaults.Human, "h", false, "Sort by numeric value considering suffix")
+	flag.BoolVar(&defaults.General, "g", false, "Sort by general numeric value, e.g. 1e3, inf or nan")
+	flag.BoolVar(&defaults.Version, "V", false, "Sort by version, e.g. file2 before file10")
+	flag.BoolVar(&defaults.Random, "R", false, "Shuffle keeping lines with equal keys together")
+	timeLayout := &timeFlag{key: &defaults}
+	flag.Var(timeLayout, "time", "Sort by timestamp, --time=LAYOUT takes a Go layout like 2006-01-02 15:04 or unix, without it ISO 8601, RFC 3339, syslog, Unix epochs and more are detected")
+	randomSeed := flag.Uint64("random-seed", 0, "Seed of -R, the same seed gives the same order (0 means a random seed)")
+	flag.BoolVar(&cfg.checkSorted, "c", false, "Check if sorted")
+	flag.BoolVar(&cfg.checkQuiet, "C", false, "Check if sorted, only report it by the exit status")
+	flag.BoolVar(&s.CheckAll, "check-all", false, "Check if sorted and report every line out of order")
+	bufferSize := flag.String("S", "", "Use at most this much memory, larger inputs are sorted with temporary files (e.g. 100M)")
+	flag.StringVar(&s.TempDir, "T", os.TempDir(), "Directory for temporary files")
+	flag.IntVar(&s.MergeBatch, "batch-size", sorting.DefaultMergeBatch, "Merge at most this many temporary files at once, more take several passes")
+	flag.IntVar(&s.Parallel, "parallel", 1, "Sort with this many goroutines at once")
+	locale := flag.String("locale", "", "Compare strings by the Unicode collation rules of the locale, e.g. en, ru or de_DE")
+	ignoreCase := flag.Bool("f", false, "Ignore case")
+	flag.BoolVar(&cfg.merge, "m", false, "Merge already sorted files given as arguments")
+	flag.StringVar(&cfg.outputFile, "o", "", "Write the result to the file instead of the standard output, it may be one of the inputs")
+	flag.Var(&namedKeyList{keys: &keys}, "K", "Sort by a column name of --header or a JSON path like .user.age, NAME[:OPTS], may be repeated")
+	flag.StringVar(&s.Format, "format", sorting.FormatLines, "Input format, one of lines, csv, tsv, jsonl")
+	flag.BoolVar(&s.Header, "header", false, "First record of csv or tsv input is a header")
+	flag.BoolVar(&s.Count, "count", false, "Prefix lines with the number of lines with equal keys, as uniq -c")
+	flag.BoolVar(&s.DuplicatesOnly, "duplicates-only", false, "Only display lines whose keys occur more than once, once per key")
+	flag.BoolVar(&s.UniqueOnly, "unique-only", false, "Only display lines whose keys occur once")
+	flag.IntVar(&s.Top, "top", 0, "Only display the first K lines of the sorted output, as sort | head -K")
+	flag.IntVar(&s.Bottom, "bottom", 0, "Only display the last K lines of the sorted output, as sort | tail -K")
+	cfg.inputFiles = parseArgs(splitGluedKeys(os.Args[1:]))
+
+	if *bufferSize != "" {
+		size, err := sorting.ParseSize(*bufferSize)
+		exitOnError(err)
+		s.BufferSize = size
 	}
 
-	suffixMap := map[rune]float64{
-		'k': 1 << 10,
-		'm': 1 << 20,
-		'g': 1 << 30,
-		't': 1 << 40,
-		'p': 1 << 50,
+	if s.Parallel < 1 {
+		fmt.Println("Parallel must be a positive number")
+		os.Exit(exitError)
 	}
 
-	if withSuffix {
-		getNum = func(s string) (float64, bool) {
-			if num, isNum := defaultGetNum(s); isNum {
-				return num, isNum
-			}
-
-			if len(s) < 2 {
-				return 0, false
-			}
-
-			var num float64 = 0
-			var isNum = false
-
-			if parsed, err := strconv.ParseFloat(s[:len(s)-1], 64); err == nil {
-				num = parsed
-				isNum = true
-			}
-
-			if isNum {
-				mult, ok := suffixMap[unicode.ToLower(rune(s[len(s)-1]))]
-				num *= mult
-				isNum = ok
-			}
-
-			return num, isNum
-		}
-	} else {
-		getNum = defaultGetNum
+	if len(cfg.inputFiles) == 0 {
+		cfg.inputFiles = []string{"-"}
 	}
 
-	for i := range data {
-		data[i].sortKeyFloat, data[i].isNumber = getNum(data[i].sortKeyStr)
+	if cfg.checkQuiet || s.CheckAll {
+		cfg.checkSorted = true
 	}
 
-}
-
-func tryToMonth(data []*sortItem) {
-	months := []string{
-		"january",
-		"february",
-		"march",
-		"april",
-		"may",
-		"june",
-		"july",
-		"august",
-		"september",
-		"october",
-		"november",
-		"december",
+	if cfg.merge || cfg.checkSorted {
+		s.BufferSize = 0
 	}
 
-	for dataIdx := range data {
-		if len(data[dataIdx].sortKeyStr) < 3 {
-			continue
-		}
+	comparator, err := sorting.NewComparator().
+		Key(keys...).
+		Separator(*sep).
+		Defaults(defaults).
+		Locale(*locale).
+		IgnoreCase(*ignoreCase).
+		RandomSeed(*randomSeed).
+		TimeLayout(timeLayout.layout).
+		Build()
+	exitOnError(err)
+	s.Comparator = comparator
+	exitOnError(s.Validate())
 
-		for monthIdx := range months {
-			if strings.HasPrefix(months[monthIdx], strings.ToLower(data[dataIdx].sortKeyStr)) {
-				data[dataIdx].sortKeyFloat = float64(monthIdx)
-				data[dataIdx].isNumber = true
-				break
-			}
-		}
-	}
+	return cfg
 }
 
-func sortData(data []*sortItem, cfg *config) {
-	if cfg.withSuffix || cfg.numSort || cfg.monthSort {
-		sortNumeric(data)
+// Exits with exitUnsorted if the file isn't sorted, with -C nothing is printed
+func sendCheck(disorders []sorting.Disorder, cfg *config) {
+	if len(disorders) == 0 {
+		if !cfg.checkQuiet {
+			fmt.Println("File is sorted")
+		}
 		return
 	}
 
-	sortStrData(data)
-}
-
-func boolToInt(b bool) int8 {
-	if b {
-		return 1
-	}
-	return 0
-}
-
-func getConfig() *config {
-	cfg := new(config)
-
-	flag.IntVar(&cfg.column, "k", -1, "Use values of given column for sorting")
-	flag.StringVar(&cfg.sep, "s", " ", "Use as separator for columns (Default is single space)")
-	flag.BoolVar(&cfg.numSort, "n", false, "Sort by numeric value")
-	flag.BoolVar(&cfg.reverse, "r", false, "Sort in reverse")
-	flag.BoolVar(&cfg.uniqueOutput, "u", false, "Only display unique lines")
-	flag.BoolVar(&cfg.monthSort, "M", false, "Sort by month")
-	flag.BoolVar(&cfg.ignoreTrailSpace, "b", false, "Ignore trail space")
-	flag.BoolVar(&cfg.withSuffix, "h", false, "Sort by numeric value considering suffix")
-	flag.BoolVar(&cfg.checkSorted, "c", false, "Check if sorted")
-	flag.Parse()
-
-	cfg.inputFile = flag.Arg(0)
-	cfg.outputFile = flag.Arg(1)
-
-	if boolToInt(cfg.numSort)+boolToInt(cfg.withSuffix)+boolToInt(cfg.monthSort) > 1 {
-		fmt.Println("You can only choose one sorting methond")
-		os.Exit(1)
+	if cfg.checkQuiet {
+		os.Exit(exitUnsorted)
 	}
 
-	return cfg
-}
-
-func checkSorted(data []*sortItem, cfg *config) int {
-	cmpr := func(i1 *sortItem, i2 *sortItem) bool { return i1.sortKeyStr < i2.sortKeyStr }
-
-	if cfg.monthSort || cfg.numSort || cfg.withSuffix {
-		cmpr = func(i1 *sortItem, i2 *sortItem) bool { return i1.sortKeyFloat < i2.sortKeyFloat }
+	checkAll := cfg.sorter.CheckAll
+	if !checkAll {
+		fmt.Printf("File is sorted up to line: %v\n", disorders[0].Line)
 	}
 
-	for idx := 0; idx < len(data)-1; idx++ {
-		if cmpr(data[idx], data[idx+1]) == cfg.reverse {
-			return idx + 2
+	for _, d := range disorders {
+		if checkAll {
+			fmt.Printf("Disorder at line: %v\n", d.Line)
 		}
+		fmt.Printf("\t%v: %v\n\t%v: %v\n", d.Line-1, d.Prev, d.Line, d.Cur)
 	}
 
-	return -1
-}
-
-func sendCheck(upTo int) {
-	if upTo == -1 {
-		fmt.Println("File is sorted")
-		return
+	if checkAll {
+		fmt.Printf("Lines out of order: %v\n", len(disorders))
 	}
-	fmt.Printf("File is sorted up to line: %v\n", upTo)
+	os.Exit(exitUnsorted)
 }
 
 func main() {
 	cfg := getConfig()
 
-	data, err := getSortData(cfg.inputFile)
+	inputs, closeAll, err := openInputs(cfg.inputFiles)
 	if err != nil {
-		fmt.Println("Couldn't read input file")
-		os.Exit(1)
+		fmt.Println("Couldn't read input file:", err.Error())
+		os.Exit(exitError)
 	}
+	defer closeAll()
 
-	if cfg.ignoreTrailSpace {
-		for i := range data {
-			data[i] = strings.Trim(data[i], " ")
+	if cfg.checkSorted {
+		disorders, err := cfg.sorter.Check(inputs...)
+		if err != nil {
+			fmt.Println("Couldn't check input file:", err.Error())
+			os.Exit(exitError)
 		}
+		sendCheck(disorders, cfg)
+		return
 	}
 
-	sortItems := intoSortItems(data, cfg)
-
-	if cfg.checkSorted {
-		sendCheck(checkSorted(sortItems, cfg))
-		return
+	stream, err := createOutput(cfg.outputFile)
+	if err != nil {
+		fmt.Println("Couldn't open output file:", err.Error())
+		os.Exit(exitError)
 	}
+	defer stream.discard()
 
-	sortData(sortItems, cfg)
+	if cfg.merge {
+		err = cfg.sorter.Merge(stream, inputs...)
+	} else {
+		err = cfg.sorter.Sort(stream, inputs...)
+	}
+	if err == nil {
+		err = stream.commit()
+	}
 
-	sendSorted(sortItems, cfg)
+	if err != nil {
+		stream.discard()
+		fmt.Println("Couldn't sort input file:", err.Error())
+		os.Exit(exitError)
+	}
 }
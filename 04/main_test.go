@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The test binary runs main when it is started by srt
+func TestMain(m *testing.M) {
+	if os.Getenv("SRT_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// Runs the command line tool with the arguments, returns its output and exit status
+func srt(t *testing.T, stdin string, args ...string) (string, int) {
+	t.Helper()
+
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "SRT_TEST_MAIN=1")
+	cmd.Stdin = strings.NewReader(stdin)
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return out.String(), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), 0
+}
+
+func writeFile(t *testing.T, path string, content string, mode os.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSortInputs(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	writeFile(t, a, "c\na", 0644)
+	writeFile(t, b, "d\nb\n", 0644)
+
+	tests := []struct {
+		name  string
+		stdin string
+		args  []string
+		want  string
+	}{
+		{name: "several inputs", args: []string{a, b}, want: "a\nb\nc\nd\n"},
+		{name: "standard input", stdin: "y\nx\n", args: nil, want: "x\ny\n"},
+		{name: "dash is the standard input", stdin: "y\nx\n", args: []string{a, "-"}, want: "a\nc\nx\ny\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, status := srt(t, tt.stdin, tt.args...)
+			if status != 0 || out != tt.want {
+				t.Errorf("srt %v = %q, %v, want %q, 0", tt.args, out, status, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortOutputInPlace(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data")
+	writeFile(t, path, "b\nc\na\n", 0600)
+
+	if out, status := srt(t, "", path, "-o", path); status != 0 {
+		t.Fatalf("srt -o = %q, %v", out, status)
+	}
+
+	if got := readFile(t, path); got != "a\nb\nc\n" {
+		t.Errorf("Output = %q, want the sorted input", got)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Output mode = %v, want the mode of the replaced file", info.Mode().Perm())
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("Directory has %v files after sorting, want only the output: %v", len(entries), err)
+	}
+}
+
+func TestSortOutputSymlink(t *testing.T) {
+	dir := t.TempDir()
+	path, link := filepath.Join(dir, "data"), filepath.Join(dir, "link")
+	writeFile(t, path, "b\na\n", 0640)
+	if err := os.Symlink("data", link); err != nil {
+		t.Skip(err)
+	}
+
+	if out, status := srt(t, "", link, "-o", link); status != 0 {
+		t.Fatalf("srt -o = %q, %v", out, status)
+	}
+
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("%v was replaced by a regular file", link)
+	}
+	if got := readFile(t, path); got != "a\nb\n" {
+		t.Errorf("Target of the link = %q, want the sorted input", got)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("Target of the link has mode %v, %v, want 0640", info.Mode().Perm(), err)
+	}
+}
+
+func TestSortOutputNewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "new")
+
+	if out, status := srt(t, "b\na\n", "-o", path); status != 0 {
+		t.Fatalf("srt -o = %q, %v", out, status)
+	}
+	if got := readFile(t, path); got != "a\nb\n" {
+		t.Errorf("Output = %q, want the sorted input", got)
+	}
+}
+
+func TestCheckExitStatus(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	tests := []struct {
+		name   string
+		stdin  string
+		args   []string
+		status int
+	}{
+		{name: "sorted", stdin: "a\nb\n", args: []string{"-C"}, status: 0},
+		{name: "not sorted", stdin: "b\na\n", args: []string{"-C"}, status: 1},
+		{name: "not sorted with -c", stdin: "b\na\n", args: []string{"-c"}, status: 1},
+		{name: "missing input", args: []string{"-C", missing}, status: 2},
+		{name: "invalid record", stdin: "{\"a\": 1}\n{\n", args: []string{"-C", "--format", "jsonl"}, status: 2},
+		{name: "invalid option", args: []string{"-C", "--parallel", "0"}, status: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if out, status := srt(t, tt.stdin, tt.args...); status != tt.status {
+				t.Errorf("srt %v = %q, %v, want status %v", tt.args, out, status, tt.status)
+			}
+		})
+	}
+}
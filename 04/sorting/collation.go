@@ -0,0 +1,92 @@
+package sorting
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// With a locale strings are compared by the Unicode Collation Algorithm with the CLDR
+// tailoring of the locale, as golang.org/x/text/collate implements it. It doesn't reorder
+// scripts, so Latin goes before Cyrillic in every locale. IgnoreCase skips the case level.
+//
+// Without a locale strings are compared by the code points of their lower case letters,
+// then lower case goes first. Strings equal on all levels are compared byte by byte,
+// unless IgnoreCase is set.
+type collator struct {
+	// Collators of the locale, a collate.Collator can't be used concurrently
+	pool       *sync.Pool
+	ignoreCase bool
+}
+
+// Locale names like ru, ru_RU, ru_RU.UTF-8 or de-DE are accepted, an empty name means no locale
+func newCollator(name string, ignoreCase bool) (collator, error) {
+	c := collator{ignoreCase: ignoreCase}
+	if name == "" {
+		return c, nil
+	}
+
+	lang, _, _ := strings.Cut(name, ".")
+	tag, err := language.Parse(strings.ReplaceAll(lang, "_", "-"))
+	if err != nil {
+		return c, fmt.Errorf("Unsupported locale %q: %w", name, err)
+	}
+
+	if _, _, confidence := language.NewMatcher(collate.Supported()).Match(tag); confidence == language.No {
+		return c, fmt.Errorf("Unsupported locale %q", name)
+	}
+
+	var options []collate.Option
+	if ignoreCase {
+		options = append(options, collate.IgnoreCase)
+	}
+
+	c.pool = &sync.Pool{New: func() any { return collate.New(tag, options...) }}
+	return c, nil
+}
+
+func (c *collator) compare(a, b string) int {
+	if c.pool != nil {
+		collator := c.pool.Get().(*collate.Collator)
+		defer c.pool.Put(collator)
+
+		if res := collator.CompareString(a, b); res != 0 || c.ignoreCase {
+			return res
+		}
+		return strings.Compare(a, b)
+	}
+
+	origA, origB := a, b
+	tertiary := 0
+
+	for a != "" && b != "" {
+		ra, sizeA := utf8.DecodeRuneInString(a)
+		rb, sizeB := utf8.DecodeRuneInString(b)
+		a, b = a[sizeA:], b[sizeB:]
+
+		if res := cmp.Compare(unicode.ToLower(ra), unicode.ToLower(rb)); res != 0 {
+			return res
+		}
+
+		if tertiary == 0 && ra != rb {
+			tertiary = cmp.Compare(boolToInt(!unicode.IsLower(ra)), boolToInt(!unicode.IsLower(rb)))
+		}
+	}
+
+	// One of the strings is a prefix of the other
+	if res := cmp.Compare(len(a), len(b)); res != 0 {
+		return res
+	}
+
+	if c.ignoreCase {
+		return 0
+	}
+
+	return cmp.Or(tertiary, strings.Compare(origA, origB))
+}
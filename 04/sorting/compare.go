@@ -0,0 +1,258 @@
+package sorting
+
+import (
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+type sortKey struct {
+	str string
+	num float64
+	// Time of Time keys
+	time time.Time
+	// Whether num or time could be parsed
+	isNumber bool
+	// Position in the shuffle of Random keys
+	hash uint64
+}
+
+// Item is a line or a record with its keys extracted once, so that they aren't parsed on every comparison
+type Item struct {
+	Line string
+	keys []sortKey
+}
+
+// Comparator orders items by their keys, items with all keys equal are compared as a whole.
+// It is safe for concurrent use once built
+type Comparator struct {
+	keys       []*Key
+	sep        string
+	reverse    bool
+	collation  collator
+	randomSeed uint64
+	timeLayout string
+}
+
+// ComparatorBuilder collects the options of a Comparator, errors are reported by Build
+type ComparatorBuilder struct {
+	keys       []*Key
+	sep        string
+	defaults   Key
+	locale     string
+	ignoreCase bool
+	randomSeed uint64
+	timeLayout string
+}
+
+// NewComparator starts a comparator of whole lines with fields separated by single spaces
+func NewComparator() *ComparatorBuilder {
+	return &ComparatorBuilder{sep: " "}
+}
+
+// Key adds keys, they are tried in the order they are added
+func (b *ComparatorBuilder) Key(keys ...*Key) *ComparatorBuilder {
+	for _, key := range keys {
+		clone := *key
+		b.keys = append(b.keys, &clone)
+	}
+	return b
+}
+
+func (b *ComparatorBuilder) Separator(sep string) *ComparatorBuilder {
+	b.sep = sep
+	return b
+}
+
+// Defaults sets the sorting method and order of keys without their own modifiers,
+// as the global options of sort do. Reverse also reverses the comparison of whole items
+func (b *ComparatorBuilder) Defaults(key Key) *ComparatorBuilder {
+	b.defaults = key
+	return b
+}
+
+// Locale sets the collation rules of strings, a CLDR locale like en or ru, an empty name means code point order
+func (b *ComparatorBuilder) Locale(name string) *ComparatorBuilder {
+	b.locale = name
+	return b
+}
+
+// IgnoreCase makes strings equal up to case equal
+func (b *ComparatorBuilder) IgnoreCase(ignore bool) *ComparatorBuilder {
+	b.ignoreCase = ignore
+	return b
+}
+
+// RandomSeed sets the order of Random keys, 0 means a random seed
+func (b *ComparatorBuilder) RandomSeed(seed uint64) *ComparatorBuilder {
+	b.randomSeed = seed
+	return b
+}
+
+// TimeLayout sets the layout of Time keys as ParseTime takes it, empty means detecting it for every key
+func (b *ComparatorBuilder) TimeLayout(layout string) *ComparatorBuilder {
+	b.timeLayout = layout
+	return b
+}
+
+func (b *ComparatorBuilder) Build() (*Comparator, error) {
+	if b.defaults.methods() > 1 {
+		return nil, errors.New("You can only choose one sorting method")
+	}
+
+	if b.sep == "" {
+		return nil, errors.New("Separator can't be empty")
+	}
+
+	collation, err := newCollator(b.locale, b.ignoreCase)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Comparator{
+		sep:        b.sep,
+		reverse:    b.defaults.Reverse,
+		collation:  collation,
+		randomSeed: b.randomSeed,
+		timeLayout: b.timeLayout,
+	}
+
+	if c.randomSeed == 0 {
+		c.randomSeed = rand.Uint64()
+	}
+
+	for _, key := range b.keys {
+		clone := *key
+		c.keys = append(c.keys, &clone)
+	}
+
+	// Without keys the whole line is the only key
+	if len(c.keys) == 0 {
+		c.keys = []*Key{{StartField: 1}}
+	}
+
+	// As in GNU sort, keys without own modifiers use the global ones
+	for _, key := range c.keys {
+		if !key.hasModifiers() {
+			key.inherit(&b.defaults)
+		}
+	}
+
+	return c, nil
+}
+
+// Numbers go first in ascending order, the rest is compared as strings after them
+func compareNumeric(a, b *sortKey, c *collator) int {
+	switch {
+	case a.isNumber && b.isNumber:
+		if a.num < b.num {
+			return -1
+		} else if a.num > b.num {
+			return 1
+		}
+		return 0
+	case a.isNumber:
+		return -1
+	case b.isNumber:
+		return 1
+	}
+
+	return c.compare(a.str, b.str)
+}
+
+// CompareKeys compares items by their keys only, items with all keys equal are duplicates for Unique
+func (c *Comparator) CompareKeys(a, b *Item) int {
+	for idx, key := range c.keys {
+		res := key.compare(&a.keys[idx], &b.keys[idx], &c.collation)
+		if key.Reverse {
+			res = -res
+		}
+
+		if res != 0 {
+			return res
+		}
+	}
+
+	return 0
+}
+
+// Compare is the order of the sort, items with all keys equal are compared as a whole
+func (c *Comparator) Compare(a, b *Item) int {
+	if res := c.CompareKeys(a, b); res != 0 {
+		return res
+	}
+
+	res := c.collation.compare(a.Line, b.Line)
+	if c.reverse {
+		return -res
+	}
+	return res
+}
+
+// CompareLines is Compare for lines that aren't made into items yet
+func (c *Comparator) CompareLines(a, b string) int {
+	return c.Compare(c.NewItem(a), c.NewItem(b))
+}
+
+func (c *Comparator) newSortKey(str string, key *Key) sortKey {
+	sk := sortKey{str: str}
+
+	if key.Numeric || key.Human {
+		sk.num, sk.isNumber = ParseNumber(strings.TrimSpace(sk.str), key.Human)
+	}
+
+	if key.Month {
+		sk.num, sk.isNumber = ParseMonth(strings.TrimSpace(sk.str))
+	}
+
+	if key.General {
+		sk.num, sk.isNumber = ParseGeneralNumber(strings.TrimSpace(sk.str))
+	}
+
+	if key.Time {
+		sk.time, sk.isNumber = ParseTime(strings.TrimSpace(sk.str), c.timeLayout)
+	}
+
+	if key.Random {
+		sk.hash = randomHash(sk.str, c.randomSeed)
+	}
+
+	return sk
+}
+
+// NewItem extracts the keys of a line
+func (c *Comparator) NewItem(line string) *Item {
+	return c.newRecordItem(line, func(key *Key) string {
+		return key.Extract(line, c.sep)
+	})
+}
+
+func (c *Comparator) newRecordItem(raw string, extract func(key *Key) string) *Item {
+	item := &Item{
+		Line: raw,
+		keys: make([]sortKey, len(c.keys)),
+	}
+
+	for keyIdx, key := range c.keys {
+		item.keys[keyIdx] = c.newSortKey(extract(key), key)
+	}
+
+	return item
+}
+
+func (c *Comparator) hasNamedKeys() bool {
+	for _, key := range c.keys {
+		if key.Name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func boolToInt(b bool) int8 {
+	if b {
+		return 1
+	}
+	return 0
+}
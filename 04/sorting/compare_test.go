@@ -0,0 +1,279 @@
+package sorting
+
+import (
+	"testing"
+)
+
+func mustComparator(t testing.TB, b *ComparatorBuilder) *Comparator {
+	t.Helper()
+	c, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+func TestCompareLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder func() *ComparatorBuilder
+		a, b    string
+		want    int
+	}{
+		{name: "case insensitive", builder: NewComparator, a: "apple", b: "Banana", want: -1},
+		{name: "lower case first", builder: NewComparator, a: "a", b: "A", want: -1},
+		{name: "prefix first", builder: NewComparator, a: "ab", b: "abc", want: -1},
+		{name: "equal", builder: NewComparator, a: "same", b: "same", want: 0},
+		{name: "ignore case", builder: func() *ComparatorBuilder { return NewComparator().IgnoreCase(true) }, a: "A", b: "a", want: 0},
+		{
+			name:    "numeric",
+			builder: func() *ComparatorBuilder { return NewComparator().Defaults(Key{Numeric: true}) },
+			a:       "9", b: "10", want: -1,
+		},
+		{
+			name:    "numbers before text",
+			builder: func() *ComparatorBuilder { return NewComparator().Defaults(Key{Numeric: true}) },
+			a:       "10", b: "abc", want: -1,
+		},
+		{
+			name:    "reverse",
+			builder: func() *ComparatorBuilder { return NewComparator().Defaults(Key{Reverse: true}) },
+			a:       "a", b: "b", want: 1,
+		},
+		{
+			name:    "month",
+			builder: func() *ComparatorBuilder { return NewComparator().Defaults(Key{Month: true}) },
+			a:       "Feb", b: "dec", want: -1,
+		},
+		{
+			name:    "human",
+			builder: func() *ComparatorBuilder { return NewComparator().Defaults(Key{Human: true}) },
+			a:       "900K", b: "1M", want: -1,
+		},
+		{
+			name:    "general nan before numbers",
+			builder: func() *ComparatorBuilder { return NewComparator().Defaults(Key{General: true}) },
+			a:       "nan", b: "-inf", want: -1,
+		},
+		{
+			name:    "general text before nan",
+			builder: func() *ComparatorBuilder { return NewComparator().Defaults(Key{General: true}) },
+			a:       "abc", b: "nan", want: -1,
+		},
+		{
+			name:    "general underflow is a number",
+			builder: func() *ComparatorBuilder { return NewComparator().Defaults(Key{General: true}) },
+			a:       "1e-400", b: "1e-300", want: -1,
+		},
+		{
+			name:    "version",
+			builder: func() *ComparatorBuilder { return NewComparator().Defaults(Key{Version: true}) },
+			a:       "file2", b: "file10", want: -1,
+		},
+		{
+			name: "second key",
+			builder: func() *ComparatorBuilder {
+				return NewComparator().Key(&Key{StartField: 1, EndField: 1}, &Key{StartField: 2, EndField: 2, Numeric: true})
+			},
+			a: "x 10", b: "x 9", want: 1,
+		},
+		{
+			name: "reversed key",
+			builder: func() *ComparatorBuilder {
+				return NewComparator().Key(&Key{StartField: 2, EndField: 2, Numeric: true, Reverse: true})
+			},
+			a: "a 1", b: "b 2", want: 1,
+		},
+		{
+			name: "separator",
+			builder: func() *ComparatorBuilder {
+				return NewComparator().Separator(",").Key(&Key{StartField: 2, EndField: 2})
+			},
+			a: "z,a", b: "a,b", want: -1,
+		},
+		{
+			name:    "time with zones",
+			builder: func() *ComparatorBuilder { return NewComparator().Defaults(Key{Time: true}) },
+			a:       "2024-03-01T10:00:00+03:00", b: "2024-03-01T08:00:00Z", want: -1,
+		},
+		{
+			name:    "time of different layouts",
+			builder: func() *ComparatorBuilder { return NewComparator().Defaults(Key{Time: true}) },
+			a:       "1709280000", b: "Fri, 01 Mar 2024 08:00:01 GMT", want: -1,
+		},
+		{
+			name:    "timestamps before the rest",
+			builder: func() *ComparatorBuilder { return NewComparator().Defaults(Key{Time: true}) },
+			a:       "2999-01-01", b: "0 unknown", want: -1,
+		},
+		{
+			name:    "time layout",
+			builder: func() *ComparatorBuilder { return NewComparator().Defaults(Key{Time: true}).TimeLayout("02.01.2006") },
+			a:       "31.12.2023", b: "01.01.2024", want: -1,
+		},
+		{
+			name:    "ru yo with ye",
+			builder: func() *ComparatorBuilder { return NewComparator().Locale("ru") },
+			a:       "ёж", b: "ель", want: -1,
+		},
+		{
+			name:    "ru short i after i",
+			builder: func() *ComparatorBuilder { return NewComparator().Locale("ru_RU.UTF-8") },
+			a:       "ия", b: "йа", want: -1,
+		},
+		{
+			name:    "de umlaut as the plain letter",
+			builder: func() *ComparatorBuilder { return NewComparator().Locale("de_DE") },
+			a:       "Äpfel", b: "Birne", want: -1,
+		},
+		{
+			name:    "ignore case with locale",
+			builder: func() *ComparatorBuilder { return NewComparator().Locale("en").IgnoreCase(true) },
+			a:       "École", b: "école", want: 0,
+		},
+		{
+			name:    "en latin before cyrillic",
+			builder: func() *ComparatorBuilder { return NewComparator().Locale("en") },
+			a:       "z", b: "а", want: -1,
+		},
+		{
+			name:    "en accents after plain letters",
+			builder: func() *ComparatorBuilder { return NewComparator().Locale("en") },
+			a:       "ecole", b: "École", want: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mustComparator(t, tt.builder())
+			if got := sign(c.CompareLines(tt.a, tt.b)); got != tt.want {
+				t.Errorf("CompareLines(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if got := sign(c.CompareLines(tt.b, tt.a)); got != -tt.want {
+				t.Errorf("CompareLines(%q, %q) = %v, want %v", tt.b, tt.a, got, -tt.want)
+			}
+		})
+	}
+}
+
+func TestComparatorBuildErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *ComparatorBuilder
+	}{
+		{name: "two methods", builder: NewComparator().Defaults(Key{Numeric: true, Month: true})},
+		{name: "time and numeric", builder: NewComparator().Defaults(Key{Time: true, Numeric: true})},
+		{name: "unknown locale", builder: NewComparator().Locale("xx")},
+		{name: "empty separator", builder: NewComparator().Separator("")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.builder.Build(); err == nil {
+				t.Error("Build() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestBuildCopiesKeys(t *testing.T) {
+	key := &Key{StartField: 1}
+	mustComparator(t, NewComparator().Key(key).Defaults(Key{Numeric: true}))
+
+	if key.Numeric {
+		t.Error("Build changed a key of the caller")
+	}
+}
+
+func TestCompareVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "file2", b: "file10", want: -1},
+		{a: "1.2.9", b: "1.2.10", want: -1},
+		{a: "1.0~rc1", b: "1.0", want: -1},
+		{a: "1.0", b: "1.0a", want: -1},
+		{a: "1.0a", b: "1.0+", want: -1},
+		{a: "007", b: "7", want: 0},
+		{a: "a", b: "a", want: 0},
+		{a: "", b: "0", want: 0},
+		{a: "", b: "a", want: -1},
+	}
+
+	for _, tt := range tests {
+		if got := sign(CompareVersion(tt.a, tt.b)); got != tt.want {
+			t.Errorf("CompareVersion(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := sign(CompareVersion(tt.b, tt.a)); got != -tt.want {
+			t.Errorf("CompareVersion(%q, %q) = %v, want %v", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func FuzzCompareVersion(f *testing.F) {
+	f.Add("1.2.9", "1.2.10")
+	f.Add("file~1", "file")
+	f.Add("a00b", "a0b")
+
+	f.Fuzz(func(t *testing.T, a, b string) {
+		if CompareVersion(a, a) != 0 {
+			t.Errorf("CompareVersion(%q, %q) != 0", a, a)
+		}
+		if sign(CompareVersion(a, b)) != -sign(CompareVersion(b, a)) {
+			t.Errorf("CompareVersion(%q, %q) isn't antisymmetric", a, b)
+		}
+	})
+}
+
+// Every mode of the comparator must be a total order, or sorting gives arbitrary results
+func FuzzCompare(f *testing.F) {
+	f.Add("b 10", "a 9", "c 1e3", uint8(0), false)
+	f.Add("Ёлка", "елка", "ЕЛКА", uint8(7), true)
+	f.Add("nan", "inf", "-inf", uint8(4), false)
+	f.Add("1.2.10", "1.2.9", "1.2~rc", uint8(5), false)
+	f.Add("2024-03-01T10:00:00+03:00", "1709280000", "Mar  1 10:00:00", uint8(8), false)
+
+	modes := []Key{
+		{},
+		{Numeric: true},
+		{Month: true},
+		{Human: true},
+		{General: true},
+		{Version: true},
+		{Random: true},
+		{Reverse: true},
+		{Time: true},
+	}
+
+	f.Fuzz(func(t *testing.T, a, b, c string, mode uint8, ru bool) {
+		builder := NewComparator().Defaults(modes[int(mode)%len(modes)]).RandomSeed(1)
+		if ru {
+			builder.Locale("ru")
+		}
+		cmp := mustComparator(t, builder)
+
+		ab, ba := sign(cmp.CompareLines(a, b)), sign(cmp.CompareLines(b, a))
+		if ab != -ba {
+			t.Fatalf("Compare(%q, %q) = %v, Compare(%q, %q) = %v", a, b, ab, b, a, ba)
+		}
+		if cmp.CompareLines(a, a) != 0 {
+			t.Fatalf("Compare(%q, %q) != 0", a, a)
+		}
+
+		bc, ac := sign(cmp.CompareLines(b, c)), sign(cmp.CompareLines(a, c))
+		if ab <= 0 && bc <= 0 && ac > 0 {
+			t.Fatalf("%q <= %q <= %q, but %q > %q", a, b, c, a, c)
+		}
+	})
+}
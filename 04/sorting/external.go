@@ -0,0 +1,183 @@
+package sorting
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+)
+
+// Rough memory taken by an Item besides its strings
+const sortItemOverhead = 64
+
+// Size of a line together with its keys as it is held in memory
+func itemSize(item *Item) int64 {
+	size := int64(len(item.Line) + sortItemOverhead)
+	for _, key := range item.keys {
+		size += int64(len(key.str)) + sortItemOverhead
+	}
+	return size
+}
+
+// Runs merged at once when MergeBatch is 0, as sort does by default
+const DefaultMergeBatch = 16
+
+func (s *Sorter) mergeBatch() int {
+	if s.MergeBatch == 0 {
+		return DefaultMergeBatch
+	}
+	return s.MergeBatch
+}
+
+func writeLine(out *bufio.Writer, item *Item) {
+	out.WriteString(item.Line)
+	out.WriteByte('\n')
+}
+
+// Writes a temporary run file and returns its name, the file is closed afterwards
+func (s *Sorter) writeRun(write func(out *bufio.Writer) error) (string, error) {
+	file, err := os.CreateTemp(s.TempDir, "sort-*")
+	if err != nil {
+		return "", err
+	}
+
+	out := bufio.NewWriter(file)
+	err = errors.Join(write(out), out.Flush(), file.Close())
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	return file.Name(), nil
+}
+
+func (s *Sorter) spillChunk(chunk []*Item) (string, error) {
+	s.SortItems(chunk)
+
+	return s.writeRun(func(out *bufio.Writer) error {
+		for _, item := range chunk {
+			writeLine(out, item)
+		}
+		return nil
+	})
+}
+
+// Merges the run files, only they are open while it runs
+func (s *Sorter) mergeRunFiles(runs []string, write func(item *Item)) error {
+	var files []*os.File
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+
+	inputs := make([]io.Reader, 0, len(runs))
+	for _, run := range runs {
+		file, err := os.Open(run)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+		inputs = append(inputs, file)
+	}
+
+	return mergeRuns(inputs, write, s)
+}
+
+// Merges adjacent runs in batches until at most one batch is left. Equal lines of earlier
+// runs stay first, so the order is still stable
+func (s *Sorter) mergePass(runs []string, created *[]string) ([]string, error) {
+	var merged []string
+	for start := 0; start < len(runs); start += s.mergeBatch() {
+		batch := runs[start:min(start+s.mergeBatch(), len(runs))]
+		if len(batch) == 1 {
+			merged = append(merged, batch[0])
+			continue
+		}
+
+		run, err := s.writeRun(func(out *bufio.Writer) error {
+			return s.mergeRunFiles(batch, func(item *Item) { writeLine(out, item) })
+		})
+		if err != nil {
+			return nil, err
+		}
+		*created = append(*created, run)
+		merged = append(merged, run)
+
+		for _, name := range batch {
+			os.Remove(name)
+		}
+	}
+
+	return merged, nil
+}
+
+// Sorts the inputs in chunks of at most BufferSize bytes which are merged afterwards,
+// the output is the same as of the in-memory sort. At most MergeBatch runs are open at
+// once, more are merged in several passes
+func (s *Sorter) externalSort(w io.Writer, inputs []io.Reader) error {
+	var created []string
+	defer func() {
+		for _, run := range created {
+			os.Remove(run)
+		}
+	}()
+
+	var runs []string
+	var chunk []*Item
+	var chunkSize int64
+	var spillErr error
+
+	err := scanInputs(inputs, func(line string) {
+		if spillErr != nil {
+			return
+		}
+
+		item := s.Comparator.NewItem(s.trim(line))
+		chunk = append(chunk, item)
+		chunkSize += itemSize(item)
+
+		if chunkSize >= s.BufferSize {
+			var run string
+			if run, spillErr = s.spillChunk(chunk); spillErr == nil {
+				created = append(created, run)
+				runs = append(runs, run)
+			}
+			chunk = nil
+			chunkSize = 0
+		}
+	})
+
+	if err := errors.Join(err, spillErr); err != nil {
+		return err
+	}
+
+	// Everything fit into the buffer
+	if len(runs) == 0 {
+		s.SortItems(chunk)
+		return s.writeItems(w, chunk, nil)
+	}
+
+	if len(chunk) != 0 {
+		run, err := s.spillChunk(chunk)
+		if err != nil {
+			return err
+		}
+		created = append(created, run)
+		runs = append(runs, run)
+		chunk = nil
+	}
+
+	for len(runs) > s.mergeBatch() {
+		if runs, err = s.mergePass(runs, &created); err != nil {
+			return err
+		}
+	}
+
+	writer := newSortedWriter(w, s, nil)
+	if err := s.mergeRunFiles(runs, writer.write); err != nil {
+		return err
+	}
+
+	return writer.flush()
+}
@@ -0,0 +1,267 @@
+package sorting
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Formats of the inputs besides plain lines, records are written out verbatim:
+//
+//	csv    RFC 4180, quoted fields may contain commas and line breaks
+//	tsv    fields separated by tabs, no quoting
+//	jsonl  a JSON document per line, keys are JSON paths like .user.age
+const (
+	FormatLines = "lines"
+	FormatCsv   = "csv"
+	FormatTsv   = "tsv"
+	FormatJsonl = "jsonl"
+)
+
+// Fields of a record are joined with it before a key is extracted, it never appears in text
+const unitSep = "\x1f"
+
+func (s *Sorter) checkFormat() error {
+	switch s.format() {
+	case FormatLines:
+		if s.Comparator.hasNamedKeys() {
+			return errors.New("Named keys need the csv, tsv or jsonl format")
+		}
+		if s.Header {
+			return errors.New("Header is only supported by the csv and tsv formats")
+		}
+		return nil
+	case FormatCsv, FormatTsv:
+		if !s.Header && s.Comparator.hasNamedKeys() {
+			return errors.New("Column names need a header")
+		}
+	case FormatJsonl:
+		if s.Header {
+			return errors.New("Header is only supported by the csv and tsv formats")
+		}
+	default:
+		return fmt.Errorf("Unknown format %q, expected one of lines, csv, tsv, jsonl", s.Format)
+	}
+
+	if s.BufferSize != 0 {
+		return fmt.Errorf("Format %v can't be sorted with a buffer size", s.Format)
+	}
+	return nil
+}
+
+// Copy of the comparator with the column names of the header turned into field numbers, the
+// keys of c are left alone so that it can go on sorting inputs with other headers
+func (c *Comparator) withColumns(header []string) (*Comparator, error) {
+	resolved := *c
+	resolved.keys = make([]*Key, len(c.keys))
+	for keyIdx, key := range c.keys {
+		clone := *key
+		resolved.keys[keyIdx] = &clone
+		if key.Name == "" {
+			continue
+		}
+
+		idx := slices.Index(header, key.Name)
+		if idx == -1 {
+			return nil, fmt.Errorf("No column %q in the header", key.Name)
+		}
+		clone.StartField = idx + 1
+		clone.EndField = idx + 1
+	}
+	return &resolved, nil
+}
+
+// Fields are joined with unitSep, so that keys select whole fields whatever they contain
+func (c *Comparator) newFieldsItem(raw string, fields []string) *Item {
+	joined := strings.Join(fields, unitSep)
+	return c.newRecordItem(raw, func(key *Key) string { return key.Extract(joined, unitSep) })
+}
+
+func (s *Sorter) readCsv(data []byte) ([]*Item, *string, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	c := s.Comparator
+	var items []*Item
+	var header *string
+	var start int64
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		end := reader.InputOffset()
+		raw := strings.TrimSuffix(string(data[start:end]), "\n")
+		start = end
+
+		if s.Header && header == nil {
+			header = &raw
+			if c, err = s.Comparator.withColumns(fields); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		items = append(items, c.newFieldsItem(raw, fields))
+	}
+
+	return items, header, nil
+}
+
+func (s *Sorter) readTsv(lines []string) ([]*Item, *string, error) {
+	c := s.Comparator
+	var items []*Item
+	var header *string
+	for idx, line := range lines {
+		fields := strings.Split(line, "\t")
+
+		if idx == 0 && s.Header {
+			header = &lines[0]
+			var err error
+			if c, err = s.Comparator.withColumns(fields); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		items = append(items, c.newFieldsItem(line, fields))
+	}
+
+	return items, header, nil
+}
+
+// Path like .user.tags.0, a number selects an element of an array
+func lookupJsonPath(doc any, path string) (any, bool) {
+	for _, part := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		if part == "" {
+			continue
+		}
+
+		switch node := doc.(type) {
+		case map[string]any:
+			value, ok := node[part]
+			if !ok {
+				return nil, false
+			}
+			doc = value
+		case []any:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			doc = node[idx]
+		default:
+			return nil, false
+		}
+	}
+
+	return doc, true
+}
+
+// Strings and numbers are taken as written, missing values and null are empty
+func jsonKeyString(doc any, path string) string {
+	value, ok := lookupJsonPath(doc, path)
+	if !ok || value == nil {
+		return ""
+	}
+
+	switch value := value.(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	}
+
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+func (s *Sorter) readJsonl(lines []string) ([]*Item, error) {
+	var items []*Item
+	for idx, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		decoder := json.NewDecoder(strings.NewReader(line))
+		decoder.UseNumber()
+
+		var doc any
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("Line %v: %w", idx+1, err)
+		}
+
+		// Keys without a path select fields of the line as in plain lines
+		item := s.Comparator.newRecordItem(line, func(key *Key) string {
+			if key.Name == "" {
+				return key.Extract(line, s.Comparator.sep)
+			}
+			return key.Extract(jsonKeyString(doc, key.Name), unitSep)
+		})
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// Records of an input in the csv or tsv format and its header, if there is one
+func (s *Sorter) readFields(input io.Reader) ([]*Item, *string, error) {
+	if s.format() == FormatCsv {
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, nil, err
+		}
+		return s.readCsv(data)
+	}
+
+	var lines []string
+	if err := scanInputs([]io.Reader{input}, func(line string) { lines = append(lines, line) }); err != nil {
+		return nil, nil, err
+	}
+	return s.readTsv(lines)
+}
+
+// Records of the inputs in the format of the Sorter and the header, if there is one.
+// Every csv or tsv input starts with a header of its own, it must be the same as the first one
+func (s *Sorter) readRecords(inputs []io.Reader) ([]*Item, *string, error) {
+	if s.format() == FormatJsonl {
+		var lines []string
+		if err := scanInputs(inputs, func(line string) { lines = append(lines, line) }); err != nil {
+			return nil, nil, err
+		}
+
+		items, err := s.readJsonl(lines)
+		return items, nil, err
+	}
+
+	var items []*Item
+	var header *string
+	for idx, input := range inputs {
+		inputItems, inputHeader, err := s.readFields(input)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if header == nil {
+			header = inputHeader
+		} else if inputHeader != nil && *inputHeader != *header {
+			return nil, nil, fmt.Errorf("Header %q of input %v differs from the header %q of the first one", *inputHeader, idx+1, *header)
+		}
+
+		items = append(items, inputItems...)
+	}
+
+	return items, header, nil
+}
@@ -0,0 +1,262 @@
+package sorting
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Key is a part of a line to sort by, in the GNU form F[.C][OPTS][,F[.C][OPTS]].
+// Fields and characters count from 1
+type Key struct {
+	StartField int
+	StartChar  int
+	// 0 means up to the end of the line
+	EndField int
+	// 0 means up to the end of EndField
+	EndChar int
+	// Column name of a header or JSON path, fields are set once the column is known
+	Name string
+
+	// Sorting methods, at most one of them may be set
+	Numeric bool
+	Month   bool
+	// Numbers with K, M, G, T or P suffixes
+	Human   bool
+	General bool
+	Version bool
+	Random  bool
+	// Timestamps in the layout of the comparator, see ParseTime
+	Time bool
+
+	Reverse      bool
+	IgnoreBlanks bool
+}
+
+var keySpecPattern = regexp.MustCompile(`^(\d+)(?:\.(\d+))?([a-zA-Z]*)(?:,(\d+)(?:\.(\d+))?([a-zA-Z]*))?$`)
+
+var namedKeyPattern = regexp.MustCompile(`^(.+?)(?::([a-zA-Z]*))?$`)
+
+func (k *Key) String() string {
+	if k.Name != "" {
+		if k.hasModifiers() {
+			return k.Name + ":" + k.modifiers()
+		}
+		return k.Name
+	}
+
+	spec := strconv.Itoa(k.StartField)
+	if k.StartChar != 0 {
+		spec += fmt.Sprintf(".%v", k.StartChar)
+	}
+	if k.EndField != 0 {
+		spec += fmt.Sprintf(",%v", k.EndField)
+		if k.EndChar != 0 {
+			spec += fmt.Sprintf(".%v", k.EndChar)
+		}
+	}
+	return spec + k.modifiers()
+}
+
+func (k *Key) modifiers() string {
+	mods := ""
+	for _, mod := range []struct {
+		set  bool
+		flag string
+	}{{k.IgnoreBlanks, "b"}, {k.General, "g"}, {k.Month, "M"}, {k.Human, "h"}, {k.Numeric, "n"}, {k.Random, "R"}, {k.Reverse, "r"}, {k.Time, "t"}, {k.Version, "V"}} {
+		if mod.set {
+			mods += mod.flag
+		}
+	}
+	return mods
+}
+
+func (k *Key) hasModifiers() bool {
+	return k.modifiers() != ""
+}
+
+func (k *Key) isNumeric() bool {
+	return k.Numeric || k.Month || k.Human
+}
+
+// Number of sorting methods chosen, at most one is allowed
+func (k *Key) methods() int8 {
+	return boolToInt(k.Numeric) + boolToInt(k.Human) + boolToInt(k.Month) +
+		boolToInt(k.General) + boolToInt(k.Version) + boolToInt(k.Random) + boolToInt(k.Time)
+}
+
+// Copies the sorting method and order of other
+func (k *Key) inherit(other *Key) {
+	k.Numeric = other.Numeric
+	k.Month = other.Month
+	k.Human = other.Human
+	k.General = other.General
+	k.Version = other.Version
+	k.Random = other.Random
+	k.Time = other.Time
+	k.Reverse = other.Reverse
+}
+
+func (k *Key) compare(a, b *sortKey, c *collator) int {
+	switch {
+	case k.Version:
+		return cmp.Or(CompareVersion(a.str, b.str), c.compare(a.str, b.str))
+	case k.General:
+		return compareGeneral(a, b, c)
+	case k.Random:
+		return compareRandom(a, b, c)
+	case k.Time:
+		return compareTime(a, b, c)
+	case k.isNumeric():
+		return compareNumeric(a, b, c)
+	}
+	return c.compare(a.str, b.str)
+}
+
+func (k *Key) applyModifiers(mods string) error {
+	for _, mod := range mods {
+		switch mod {
+		case 'b':
+			k.IgnoreBlanks = true
+		case 'n':
+			k.Numeric = true
+		case 'M':
+			k.Month = true
+		case 'h':
+			k.Human = true
+		case 'r':
+			k.Reverse = true
+		case 'g':
+			k.General = true
+		case 'V':
+			k.Version = true
+		case 'R':
+			k.Random = true
+		case 't':
+			k.Time = true
+		default:
+			return fmt.Errorf("Unknown key modifier %q", mod)
+		}
+	}
+
+	if k.methods() > 1 {
+		return fmt.Errorf("Key can only have one sorting method")
+	}
+
+	return nil
+}
+
+// ParseKey parses a key of sort -k, F[.C][OPTS][,F[.C][OPTS]] where OPTS are b, g, h, M, n, R, r, t, V
+func ParseKey(spec string) (*Key, error) {
+	match := keySpecPattern.FindStringSubmatch(spec)
+	if match == nil {
+		return nil, fmt.Errorf("Invalid key %q", spec)
+	}
+
+	key := &Key{}
+	key.StartField, _ = strconv.Atoi(match[1])
+	if match[2] != "" {
+		key.StartChar, _ = strconv.Atoi(match[2])
+		if key.StartChar == 0 {
+			return nil, fmt.Errorf("Invalid key %q: character offset must be positive", spec)
+		}
+	}
+	if match[4] != "" {
+		key.EndField, _ = strconv.Atoi(match[4])
+		if key.EndField == 0 {
+			return nil, fmt.Errorf("Invalid key %q: field number must be positive", spec)
+		}
+	}
+	if match[5] != "" {
+		key.EndChar, _ = strconv.Atoi(match[5])
+	}
+
+	if key.StartField == 0 {
+		return nil, fmt.Errorf("Invalid key %q: field number must be positive", spec)
+	}
+
+	if err := key.applyModifiers(match[3] + match[6]); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// ParseNamedKey parses a key given by column name or JSON path, NAME[:OPTS]
+func ParseNamedKey(spec string) (*Key, error) {
+	match := namedKeyPattern.FindStringSubmatch(spec)
+	if match == nil {
+		return nil, fmt.Errorf("Invalid key %q", spec)
+	}
+
+	key := &Key{Name: match[1], StartField: 1, EndField: 1}
+	if err := key.applyModifiers(match[2]); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// Byte offset of the n-th rune of s, len(s) if s is shorter
+func runeOffset(s string, n int) int {
+	for idx := range s {
+		if n == 0 {
+			return idx
+		}
+		n--
+	}
+	return len(s)
+}
+
+func skipBlanks(s string) int {
+	for idx, r := range s {
+		if !unicode.IsSpace(r) {
+			return idx
+		}
+	}
+	return len(s)
+}
+
+// Extract returns the part of the line selected by the key, fields are separated by sep
+func (k *Key) Extract(line string, sep string) string {
+	fields := strings.Split(line, sep)
+	if k.StartField > len(fields) {
+		return ""
+	}
+
+	// Byte offsets of the fields within the line
+	starts := make([]int, len(fields))
+	offset := 0
+	for idx, field := range fields {
+		starts[idx] = offset
+		offset += len(field) + len(sep)
+	}
+
+	field := fields[k.StartField-1]
+	inField := 0
+	if k.IgnoreBlanks {
+		inField = skipBlanks(field)
+	}
+	if k.StartChar > 0 {
+		inField += runeOffset(field[inField:], k.StartChar-1)
+	}
+	begin := starts[k.StartField-1] + inField
+
+	end := len(line)
+	if k.EndField != 0 && k.EndField <= len(fields) {
+		field = fields[k.EndField-1]
+		end = starts[k.EndField-1] + len(field)
+		if k.EndChar != 0 {
+			end = starts[k.EndField-1] + runeOffset(field, k.EndChar)
+		}
+	}
+
+	if end <= begin {
+		return ""
+	}
+
+	return line[begin:end]
+}
@@ -0,0 +1,138 @@
+package sorting
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Key
+		wantErr bool
+	}{
+		{spec: "1", want: Key{StartField: 1}},
+		{spec: "2,2", want: Key{StartField: 2, EndField: 2}},
+		{spec: "2.3,4.5", want: Key{StartField: 2, StartChar: 3, EndField: 4, EndChar: 5}},
+		{spec: "2n", want: Key{StartField: 2, Numeric: true}},
+		{spec: "1,1nr", want: Key{StartField: 1, EndField: 1, Numeric: true, Reverse: true}},
+		{spec: "3bM,3", want: Key{StartField: 3, EndField: 3, Month: true, IgnoreBlanks: true}},
+		{spec: "2,2t", want: Key{StartField: 2, EndField: 2, Time: true}},
+		{spec: "1tn", wantErr: true},
+		{spec: "1gV", wantErr: true},
+		{spec: "1x", wantErr: true},
+		{spec: "0", wantErr: true},
+		{spec: "1.0", wantErr: true},
+		{spec: "1,0", wantErr: true},
+		{spec: "", wantErr: true},
+		{spec: "a", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			key, err := ParseKey(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseKey(%q) = %+v, want error", tt.spec, key)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseKey(%q) error: %v", tt.spec, err)
+			}
+			if *key != tt.want {
+				t.Errorf("ParseKey(%q) = %+v, want %+v", tt.spec, *key, tt.want)
+			}
+			if key.String() != tt.spec && !strings.ContainsAny(tt.spec, "bM") {
+				t.Errorf("String() = %q, want %q", key.String(), tt.spec)
+			}
+		})
+	}
+}
+
+func TestParseNamedKey(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Key
+		wantErr bool
+	}{
+		{spec: "age", want: Key{Name: "age", StartField: 1, EndField: 1}},
+		{spec: "age:nr", want: Key{Name: "age", StartField: 1, EndField: 1, Numeric: true, Reverse: true}},
+		{spec: ".user.age:n", want: Key{Name: ".user.age", StartField: 1, EndField: 1, Numeric: true}},
+		{spec: "age:x", wantErr: true},
+		{spec: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			key, err := ParseNamedKey(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseNamedKey(%q) = %+v, want error", tt.spec, key)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseNamedKey(%q) error: %v", tt.spec, err)
+			}
+			if *key != tt.want {
+				t.Errorf("ParseNamedKey(%q) = %+v, want %+v", tt.spec, *key, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyExtract(t *testing.T) {
+	tests := []struct {
+		spec string
+		line string
+		sep  string
+		want string
+	}{
+		{spec: "1", line: "a b c", sep: " ", want: "a b c"},
+		{spec: "2", line: "a b c", sep: " ", want: "b c"},
+		{spec: "2,2", line: "a b c", sep: " ", want: "b"},
+		{spec: "1,2", line: "a b c", sep: " ", want: "a b"},
+		{spec: "4", line: "a b c", sep: " ", want: ""},
+		{spec: "2,9", line: "a b c", sep: " ", want: "b c"},
+		{spec: "1.2,1.3", line: "abcd e", sep: " ", want: "bc"},
+		{spec: "1.2,1", line: "привет мир", sep: " ", want: "ривет"},
+		{spec: "2.1,2.2", line: "x,ёжик", sep: ",", want: "ёж"},
+		{spec: "2b,2", line: "a:   b:c", sep: ":", want: "b"},
+		{spec: "1.5,1.2", line: "abcdef", sep: " ", want: ""},
+		{spec: "2,2", line: "a::c", sep: ":", want: ""},
+		{spec: "1.9", line: "abc", sep: " ", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec+"/"+tt.line, func(t *testing.T) {
+			key, err := ParseKey(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := key.Extract(tt.line, tt.sep); got != tt.want {
+				t.Errorf("Extract(%q, %q) = %q, want %q", tt.line, tt.sep, got, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzKeyExtract(f *testing.F) {
+	f.Add("a b c", " ", 1, 0, 0, 0, false)
+	f.Add("привет, мир", ",", 2, 3, 2, 1, true)
+	f.Add("x::y", "::", 2, 1, 3, 9, false)
+
+	f.Fuzz(func(t *testing.T, line, sep string, startField, startChar, endField, endChar int, blanks bool) {
+		if sep == "" || startField < 1 || startChar < 0 || endField < 0 || endChar < 0 {
+			t.Skip()
+		}
+
+		key := &Key{StartField: startField, StartChar: startChar, EndField: endField, EndChar: endChar, IgnoreBlanks: blanks}
+		if got := key.Extract(line, sep); !strings.Contains(line, got) {
+			t.Errorf("Extract(%q, %q) = %q is not a part of the line", line, sep, got)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package sorting
+
+import (
+	"bufio"
+	"container/heap"
+	"io"
+)
+
+// Sorted input of a merge
+type sortRun struct {
+	sc   *bufio.Scanner
+	item *Item
+	idx  int
+}
+
+func (r *sortRun) next(s *Sorter) (bool, error) {
+	if !r.sc.Scan() {
+		return false, r.sc.Err()
+	}
+
+	r.item = s.Comparator.NewItem(s.trim(r.sc.Text()))
+	return true, nil
+}
+
+// Equal items come from earlier inputs first, so the merge is as stable as the in-memory sort
+type runHeap struct {
+	runs    []*sortRun
+	compare func(a, b *Item) int
+}
+
+func (h *runHeap) Len() int { return len(h.runs) }
+
+func (h *runHeap) Less(i, j int) bool {
+	if res := h.compare(h.runs[i].item, h.runs[j].item); res != 0 {
+		return res < 0
+	}
+	return h.runs[i].idx < h.runs[j].idx
+}
+
+func (h *runHeap) Swap(i, j int) { h.runs[i], h.runs[j] = h.runs[j], h.runs[i] }
+
+func (h *runHeap) Push(x any) { h.runs = append(h.runs, x.(*sortRun)) }
+
+func (h *runHeap) Pop() any {
+	last := h.runs[len(h.runs)-1]
+	h.runs = h.runs[:len(h.runs)-1]
+	return last
+}
+
+// Streams a k-way merge of sorted inputs
+func mergeRuns(inputs []io.Reader, write func(item *Item), s *Sorter) error {
+	h := &runHeap{compare: s.compare}
+
+	for idx, input := range inputs {
+		run := &sortRun{sc: bufio.NewScanner(input), idx: idx}
+		ok, err := run.next(s)
+		if err != nil {
+			return err
+		}
+		if ok {
+			h.runs = append(h.runs, run)
+		}
+	}
+
+	heap.Init(h)
+	for h.Len() > 0 {
+		run := h.runs[0]
+		write(run.item)
+
+		ok, err := run.next(s)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package sorting
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Weight of the first character of the non-digit part of a version, ~ goes before
+// everything including the end of the part, letters go before other characters
+func versionCharOrder(s string) int {
+	if s == "" {
+		return 0
+	}
+
+	switch c := s[0]; {
+	case c == '~':
+		return -1
+	case isDigit(c):
+		return 0
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		return int(c)
+	default:
+		return int(c) + 256
+	}
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// CompareVersion is the natural version order as in Debian, digit runs are compared as numbers,
+// so file2 < file10 and 1.2.9 < 1.2.10
+func CompareVersion(a, b string) int {
+	for a != "" || b != "" {
+		for (a != "" && !isDigit(a[0])) || (b != "" && !isDigit(b[0])) {
+			// Orders are equal only if both characters are non-digits
+			if res := cmp.Compare(versionCharOrder(a), versionCharOrder(b)); res != 0 {
+				return res
+			}
+			a, b = a[1:], b[1:]
+		}
+
+		numA, numB := digitRun(a), digitRun(b)
+		a, b = a[len(numA):], b[len(numB):]
+
+		numA, numB = strings.TrimLeft(numA, "0"), strings.TrimLeft(numB, "0")
+		if res := cmp.Or(cmp.Compare(len(numA), len(numB)), strings.Compare(numA, numB)); res != 0 {
+			return res
+		}
+	}
+
+	return 0
+}
+
+func digitRun(s string) string {
+	idx := 0
+	for idx < len(s) && isDigit(s[idx]) {
+		idx++
+	}
+	return s[:idx]
+}
+
+// ParseGeneralNumber parses anything strconv.ParseFloat understands, including 1e10, 0x1p-2, inf and nan.
+// Numbers out of range are numbers too, they overflow to infinity and underflow to zero
+func ParseGeneralNumber(s string) (float64, bool) {
+	num, err := strconv.ParseFloat(s, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return 0, false
+	}
+	return num, true
+}
+
+// Non-numbers go first, then NaN, then numbers in ascending order from -inf to +inf
+func compareGeneral(a, b *sortKey, c *collator) int {
+	class := func(k *sortKey) int {
+		switch {
+		case !k.isNumber:
+			return 0
+		case math.IsNaN(k.num):
+			return 1
+		}
+		return 2
+	}
+
+	if res := cmp.Compare(class(a), class(b)); res != 0 {
+		return res
+	}
+
+	if a.isNumber && !math.IsNaN(a.num) {
+		if res := cmp.Compare(a.num, b.num); res != 0 {
+			return res
+		}
+	}
+
+	return c.compare(a.str, b.str)
+}
+
+// Position of the key in a shuffle, equal keys get the same one and stay together
+func randomHash(s string, seed uint64) uint64 {
+	h := fnv.New64a()
+	binary.Write(h, binary.LittleEndian, seed)
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+func compareRandom(a, b *sortKey, c *collator) int {
+	if res := cmp.Compare(a.hash, b.hash); res != 0 {
+		return res
+	}
+	return c.compare(a.str, b.str)
+}
+
+// Timestamps go first in ascending order, the rest is compared as strings after them as with numbers
+func compareTime(a, b *sortKey, c *collator) int {
+	switch {
+	case a.isNumber && b.isNumber:
+		return a.time.Compare(b.time)
+	case a.isNumber:
+		return -1
+	case b.isNumber:
+		return 1
+	}
+
+	return c.compare(a.str, b.str)
+}
@@ -0,0 +1,77 @@
+package sorting
+
+import (
+	"slices"
+	"sync"
+)
+
+// Chunks shorter than that are not worth a goroutine
+const minParallelChunk = 1 << 12
+
+// Sorts data in up to workers chunks concurrently and merges them pairwise,
+// ties are taken from the left chunk, so the result is the same as of the sequential sort
+func parallelSortData(data []*Item, compare func(a, b *Item) int, workers int) {
+	workers = min(workers, len(data)/minParallelChunk)
+	if workers < 2 {
+		slices.SortStableFunc(data, compare)
+		return
+	}
+
+	bounds := make([]int, workers+1)
+	for idx := range bounds {
+		bounds[idx] = len(data) * idx / workers
+	}
+
+	var wg sync.WaitGroup
+	for idx := range workers {
+		wg.Go(func() {
+			slices.SortStableFunc(data[bounds[idx]:bounds[idx+1]], compare)
+		})
+	}
+	wg.Wait()
+
+	buf := make([]*Item, len(data))
+	src, dst := data, buf
+	for len(bounds) > 2 {
+		var merged []int
+		for idx := 0; idx+1 < len(bounds); idx += 2 {
+			if idx+2 >= len(bounds) {
+				// Odd chunk out, it is carried over to the next round as is
+				copy(dst[bounds[idx]:bounds[idx+1]], src[bounds[idx]:bounds[idx+1]])
+				merged = append(merged, bounds[idx])
+				continue
+			}
+
+			lo, mid, hi := bounds[idx], bounds[idx+1], bounds[idx+2]
+			wg.Go(func() {
+				mergeChunks(dst[lo:hi], src[lo:mid], src[mid:hi], compare)
+			})
+			merged = append(merged, lo)
+		}
+		wg.Wait()
+
+		bounds = append(merged, len(data))
+		src, dst = dst, src
+	}
+
+	if &src[0] != &data[0] {
+		copy(data, src)
+	}
+}
+
+func mergeChunks(dst, left, right []*Item, compare func(a, b *Item) int) {
+	idx := 0
+	for len(left) != 0 && len(right) != 0 {
+		if compare(right[0], left[0]) < 0 {
+			dst[idx] = right[0]
+			right = right[1:]
+		} else {
+			dst[idx] = left[0]
+			left = left[1:]
+		}
+		idx++
+	}
+
+	idx += copy(dst[idx:], left)
+	copy(dst[idx:], right)
+}
@@ -0,0 +1,88 @@
+package sorting
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const benchLines = 2_000_000
+
+var benchInput = sync.OnceValue(func() []string {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	words := []string{"apple", "Banana", "cherry", "jan", "Feb", "dec", "10k", "2M"}
+
+	lines := make([]string, benchLines)
+	for idx := range lines {
+		lines[idx] = fmt.Sprintf("%v %v %v", words[rnd.IntN(len(words))], rnd.IntN(1_000_000), strings.Repeat("x", rnd.IntN(8)))
+	}
+	return lines
+})
+
+func benchItems(b *testing.B, c *Comparator) []*Item {
+	items := make([]*Item, benchLines)
+	for idx, line := range benchInput() {
+		items[idx] = c.NewItem(line)
+	}
+	return items
+}
+
+func benchSort(b *testing.B, parallel int, keys ...*Key) {
+	c, err := NewComparator().Key(keys...).Build()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	s := &Sorter{Comparator: c, Parallel: parallel}
+	items := benchItems(b, c)
+
+	data := make([]*Item, len(items))
+	for b.Loop() {
+		b.StopTimer()
+		copy(data, items)
+		b.StartTimer()
+
+		s.SortItems(data)
+	}
+}
+
+func BenchmarkSort(b *testing.B) {
+	for _, parallel := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("lines/parallel=%v", parallel), func(b *testing.B) {
+			benchSort(b, parallel)
+		})
+		b.Run(fmt.Sprintf("numeric/parallel=%v", parallel), func(b *testing.B) {
+			benchSort(b, parallel, &Key{StartField: 2, EndField: 2, Numeric: true})
+		})
+	}
+}
+
+// Several chunks per worker count, odd counts carry a chunk over a merge round. Lines are
+// compared as a whole after the keys, so many equal lines make ties, which must keep the
+// order of the sequential stable sort
+func TestParallelMatchesSequential(t *testing.T) {
+	c := mustComparator(t, NewComparator().Key(&Key{StartField: 1, EndField: 1}))
+
+	rnd := rand.New(rand.NewPCG(3, 4))
+	items := make([]*Item, 10*minParallelChunk+123)
+	for idx := range items {
+		items[idx] = c.NewItem(fmt.Sprintf("%v %v", rnd.IntN(20), rnd.IntN(3)))
+	}
+
+	want := slices.Clone(items)
+	slices.SortStableFunc(want, c.Compare)
+
+	for _, workers := range []int{2, 3, 4, 7, 8} {
+		got := slices.Clone(items)
+		parallelSortData(got, c.Compare, workers)
+
+		for idx := range want {
+			if got[idx] != want[idx] {
+				t.Fatalf("%v workers: item %v is %q, want %q", workers, idx, got[idx].Line, want[idx].Line)
+			}
+		}
+	}
+}
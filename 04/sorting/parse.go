@@ -0,0 +1,236 @@
+package sorting
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ParseNumber parses a number of a Numeric key, with withSuffix K, M, G, T and P multiply it by powers of 1024
+func ParseNumber(s string, withSuffix bool) (float64, bool) {
+	var defaultGetNum = func(s string) (float64, bool) {
+		num, err := strconv.ParseFloat(s, 64)
+		return num, err == nil
+	}
+
+	suffixMap := map[rune]float64{
+		'k': 1 << 10,
+		'm': 1 << 20,
+		'g': 1 << 30,
+		't': 1 << 40,
+		'p': 1 << 50,
+	}
+
+	if num, isNum := defaultGetNum(s); isNum || !withSuffix {
+		return num, isNum
+	}
+
+	if len(s) < 2 {
+		return 0, false
+	}
+
+	num, isNum := defaultGetNum(s[:len(s)-1])
+	if isNum {
+		mult, ok := suffixMap[unicode.ToLower(rune(s[len(s)-1]))]
+		num *= mult
+		isNum = ok
+	}
+
+	return num, isNum
+}
+
+// ParseMonth returns the index of a month from 0 for January, s is a prefix of at least 3 letters of its English name
+func ParseMonth(s string) (float64, bool) {
+	months := []string{
+		"january",
+		"february",
+		"march",
+		"april",
+		"may",
+		"june",
+		"july",
+		"august",
+		"september",
+		"october",
+		"november",
+		"december",
+	}
+
+	if len(s) < 3 {
+		return 0, false
+	}
+
+	for monthIdx := range months {
+		if strings.HasPrefix(months[monthIdx], strings.ToLower(s)) {
+			return float64(monthIdx), true
+		}
+	}
+
+	return 0, false
+}
+
+// ParseSize parses a GNU style buffer size, a plain number is in KiB, b means bytes,
+// K, M, G, T and P are powers of 1024
+func ParseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("Empty buffer size")
+	}
+
+	if num, err := strconv.ParseInt(s, 10, 64); err == nil && num > 0 {
+		return num << 10, nil
+	}
+
+	if last := s[len(s)-1]; last == 'b' || last == 'B' {
+		if num, err := strconv.ParseInt(s[:len(s)-1], 10, 64); err == nil && num > 0 {
+			return num, nil
+		}
+	}
+
+	if num, isNum := ParseNumber(s, true); isNum && num >= 1 {
+		return int64(num), nil
+	}
+
+	return 0, fmt.Errorf("Invalid buffer size %q", s)
+}
+
+// Layouts tried by ParseTime without a layout, fractions of seconds are accepted after the seconds of any of them
+var autoTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"20060102T150405Z0700",
+	"20060102T150405",
+	"20060102T1504",
+	"20060102",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.RFC822Z,
+	time.RFC822,
+	time.ANSIC,
+	time.UnixDate,
+	time.RubyDate,
+	"02/Jan/2006:15:04:05 -0700",
+	time.Stamp,
+}
+
+// TimeLayoutUnix is the layout of ParseTime for seconds since the Unix epoch
+const TimeLayoutUnix = "unix"
+
+// Integer parts of at least that many digits are Unix epochs in milliseconds, microseconds and nanoseconds
+var epochUnits = []struct {
+	digits    int
+	perSecond int64
+}{{19, 1e9}, {16, 1e6}, {13, 1e3}}
+
+// Shorter numbers are not taken for Unix epochs without a layout, they are more likely
+// counts or ids than times before 1973
+const minEpochDigits = 9
+
+// Offsets of zone abbreviations that mean one zone, others are only known if the local zone
+// uses them
+var zoneAbbreviations = map[string]int{
+	"UTC": 0, "UT": 0, "GMT": 0, "Z": 0,
+	"EST": -5 * 3600, "EDT": -4 * 3600, "CST": -6 * 3600, "CDT": -5 * 3600,
+	"MST": -7 * 3600, "MDT": -6 * 3600, "PST": -8 * 3600, "PDT": -7 * 3600,
+	"AKST": -9 * 3600, "AKDT": -8 * 3600, "HST": -10 * 3600,
+	"WET": 0, "WEST": 1 * 3600, "CET": 1 * 3600, "CEST": 2 * 3600, "EET": 2 * 3600, "EEST": 3 * 3600,
+	"MSK": 3 * 3600, "JST": 9 * 3600, "KST": 9 * 3600,
+	"AWST": 8 * 3600, "ACST": 9*3600 + 1800, "ACDT": 10*3600 + 1800, "AEST": 10 * 3600, "AEDT": 11 * 3600,
+	"NZST": 12 * 3600, "NZDT": 13 * 3600,
+}
+
+// ParseTime parses a timestamp of a Go layout, TimeLayoutUnix or, with an empty layout, of any of
+// ISO 8601, RFC 3339, RFC 1123, syslog, the common log format and Unix epochs of at least
+// minEpochDigits digits. Timestamps without a zone are in the local time, syslog ones have no year
+// and are in year 0. Zone abbreviations are resolved by the local zone or zoneAbbreviations,
+// timestamps with other ones aren't parsed
+func ParseTime(s string, layout string) (time.Time, bool) {
+	if layout == TimeLayoutUnix {
+		return parseEpoch(s)
+	}
+
+	if layout != "" {
+		return parseLayout(s, layout)
+	}
+
+	for _, layout := range autoTimeLayouts {
+		if t, ok := parseLayout(s, layout); ok {
+			return t, true
+		}
+	}
+
+	if len(digitRun(strings.TrimPrefix(s, "-"))) < minEpochDigits {
+		return time.Time{}, false
+	}
+	return parseEpoch(s)
+}
+
+// time.ParseInLocation takes zone abbreviations the location doesn't know for UTC, they are
+// looked up in zoneAbbreviations instead
+func parseLayout(s string, layout string) (time.Time, bool) {
+	t, err := time.ParseInLocation(layout, s, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	if !strings.Contains(layout, "MST") {
+		return t, true
+	}
+
+	name, offset := t.Zone()
+	if offset != 0 {
+		return t, true
+	}
+
+	offset, ok := zoneAbbreviations[name]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	zone := time.FixedZone(name, offset)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), zone), true
+}
+
+// Seconds since the Unix epoch with an optional fraction, or milliseconds, microseconds
+// or nanoseconds if there are enough digits for them
+func parseEpoch(s string) (time.Time, bool) {
+	intPart, frac, hasFrac := strings.Cut(s, ".")
+	digits := strings.TrimPrefix(intPart, "-")
+	if digits == "" || digitRun(digits) != digits || digitRun(frac) != frac || (hasFrac && frac == "") {
+		return time.Time{}, false
+	}
+
+	num, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	perSecond := int64(1)
+	for _, unit := range epochUnits {
+		if len(digits) >= unit.digits {
+			perSecond = unit.perSecond
+			break
+		}
+	}
+
+	// Nanoseconds of the fraction of a unit, digits past nanoseconds are dropped
+	fracNanos, _ := strconv.ParseInt((frac + "000000000")[:9], 10, 64)
+	if intPart[0] == '-' {
+		fracNanos = -fracNanos
+	}
+
+	nanos := num%perSecond*(1e9/perSecond) + fracNanos/perSecond
+	return time.Unix(num/perSecond, nanos), true
+}
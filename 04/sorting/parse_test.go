@@ -0,0 +1,168 @@
+package sorting
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		in         string
+		withSuffix bool
+		want       float64
+		wantOk     bool
+	}{
+		{in: "42", want: 42, wantOk: true},
+		{in: "-1.5", want: -1.5, wantOk: true},
+		{in: "1e3", want: 1000, wantOk: true},
+		{in: "2k", wantOk: false},
+		{in: "2k", withSuffix: true, want: 2048, wantOk: true},
+		{in: "1.5M", withSuffix: true, want: 1.5 * (1 << 20), wantOk: true},
+		{in: "3G", withSuffix: true, want: 3 << 30, wantOk: true},
+		{in: "1x", withSuffix: true, wantOk: false},
+		{in: "k", withSuffix: true, wantOk: false},
+		{in: "", withSuffix: true, wantOk: false},
+		{in: "abc", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseNumber(tt.in, tt.withSuffix)
+		if ok != tt.wantOk || (ok && got != tt.want) {
+			t.Errorf("ParseNumber(%q, %v) = %v, %v, want %v, %v", tt.in, tt.withSuffix, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestParseMonth(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   float64
+		wantOk bool
+	}{
+		{in: "jan", want: 0, wantOk: true},
+		{in: "January", want: 0, wantOk: true},
+		{in: "FEB", want: 1, wantOk: true},
+		{in: "dec", want: 11, wantOk: true},
+		{in: "sept", want: 8, wantOk: true},
+		{in: "ja", wantOk: false},
+		{in: "janu4ry", wantOk: false},
+		{in: "foo", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseMonth(tt.in)
+		if ok != tt.wantOk || (ok && got != tt.want) {
+			t.Errorf("ParseMonth(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestParseGeneralNumber(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   float64
+		wantOk bool
+	}{
+		{in: "1e3", want: 1000, wantOk: true},
+		{in: "-inf", want: math.Inf(-1), wantOk: true},
+		{in: "0x1p-2", want: 0.25, wantOk: true},
+		{in: "1e999", want: math.Inf(1), wantOk: true},
+		{in: "1e-400", want: 0, wantOk: true},
+		{in: "-1e-400", want: 0, wantOk: true},
+		{in: "abc", wantOk: false},
+		{in: "1e", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseGeneralNumber(tt.in)
+		if ok != tt.wantOk || (ok && got != tt.want) {
+			t.Errorf("ParseGeneralNumber(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.wantOk)
+		}
+	}
+
+	if got, ok := ParseGeneralNumber("nan"); !ok || !math.IsNaN(got) {
+		t.Errorf("ParseGeneralNumber(nan) = %v, %v, want NaN, true", got, ok)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "10", want: 10 << 10},
+		{in: "100b", want: 100},
+		{in: "16K", want: 16 << 10},
+		{in: "2M", want: 2 << 20},
+		{in: "1G", want: 1 << 30},
+		{in: "", wantErr: true},
+		{in: "0", wantErr: true},
+		{in: "-5", wantErr: true},
+		{in: "1x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSize(%q) = %v, %v, want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	utc := func(year int, month time.Month, day, hour, min, sec, nsec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, nsec, time.UTC)
+	}
+	local := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, time.Local)
+	}
+
+	tests := []struct {
+		in     string
+		layout string
+		want   time.Time
+		wantOk bool
+	}{
+		{in: "2024-03-01T10:00:00Z", want: utc(2024, 3, 1, 10, 0, 0, 0), wantOk: true},
+		{in: "2024-03-01T10:00:00.25+03:00", want: utc(2024, 3, 1, 7, 0, 0, 25e7), wantOk: true},
+		{in: "2024-03-01T10:00:00+0300", want: utc(2024, 3, 1, 7, 0, 0, 0), wantOk: true},
+		{in: "2024-03-01 10:00:00 -0100", want: utc(2024, 3, 1, 11, 0, 0, 0), wantOk: true},
+		{in: "2024-03-01 10:00:00", want: local(2024, 3, 1, 10, 0, 0), wantOk: true},
+		{in: "2024-03-01", want: local(2024, 3, 1, 0, 0, 0), wantOk: true},
+		{in: "Fri, 01 Mar 2024 10:00:00 +0100", want: utc(2024, 3, 1, 9, 0, 0, 0), wantOk: true},
+		{in: "01/Mar/2024:10:00:00 +0000", want: utc(2024, 3, 1, 10, 0, 0, 0), wantOk: true},
+		{in: "Mar  1 10:00:00", want: local(0, 3, 1, 10, 0, 0), wantOk: true},
+		{in: "1709287200", want: utc(2024, 3, 1, 10, 0, 0, 0), wantOk: true},
+		{in: "1709287200.5", want: utc(2024, 3, 1, 10, 0, 0, 5e8), wantOk: true},
+		{in: "1709287200500", want: utc(2024, 3, 1, 10, 0, 0, 5e8), wantOk: true},
+		{in: "1709287200000001", want: utc(2024, 3, 1, 10, 0, 0, 1e3), wantOk: true},
+		{in: "1709287200000000001", want: utc(2024, 3, 1, 10, 0, 0, 1), wantOk: true},
+		{in: "-1.5", layout: TimeLayoutUnix, want: utc(1969, 12, 31, 23, 59, 58, 5e8), wantOk: true},
+		{in: "-1.5", wantOk: false},
+		{in: "12345", wantOk: false},
+		{in: "20260115", want: local(2026, 1, 15, 0, 0, 0), wantOk: true},
+		{in: "20260115T100000Z", want: utc(2026, 1, 15, 10, 0, 0, 0), wantOk: true},
+		{in: "20260115T1000", want: local(2026, 1, 15, 10, 0, 0), wantOk: true},
+		{in: "2026-01-15 10:00:00 PST", want: utc(2026, 1, 15, 18, 0, 0, 0), wantOk: true},
+		{in: "2026-01-15 10:00:00 UTC", want: utc(2026, 1, 15, 10, 0, 0, 0), wantOk: true},
+		{in: "Thu, 15 Jan 2026 10:00:00 EST", want: utc(2026, 1, 15, 15, 0, 0, 0), wantOk: true},
+		{in: "2026-01-15 10:00:00 XYZ", wantOk: false},
+		{in: "2024/03/01", layout: "2006/01/02", want: local(2024, 3, 1, 0, 0, 0), wantOk: true},
+		{in: "2024-03-01", layout: "2006/01/02", wantOk: false},
+		{in: "42", layout: TimeLayoutUnix, want: utc(1970, 1, 1, 0, 0, 42, 0), wantOk: true},
+		{in: "2024-03-01", layout: TimeLayoutUnix, wantOk: false},
+		{in: "1.", wantOk: false},
+		{in: "2024-13-01", wantOk: false},
+		{in: "yesterday", wantOk: false},
+		{in: "", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseTime(tt.in, tt.layout)
+		if ok != tt.wantOk || (ok && !got.Equal(tt.want)) {
+			t.Errorf("ParseTime(%q, %q) = %v, %v, want %v, %v", tt.in, tt.layout, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
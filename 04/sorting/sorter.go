@@ -0,0 +1,339 @@
+package sorting
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Sorter sorts, merges and checks inputs in the order of its Comparator
+type Sorter struct {
+	Comparator *Comparator
+
+	// Format of the inputs, FormatLines if empty
+	Format string
+	// First record of csv or tsv input is a header, it is written out first
+	Header bool
+	// Spaces around lines are dropped, as -b of sort does
+	TrimSpace bool
+
+	// Adjacent lines with equal keys form a group. Unique writes a group as its first line,
+	// Count prefixes it with the number of lines in it as uniq -c, DuplicatesOnly and
+	// UniqueOnly only write groups of more than one line and of one line
+	Unique         bool
+	Count          bool
+	DuplicatesOnly bool
+	UniqueOnly     bool
+
+	// Only the first Top or the last Bottom lines of the output are written, as sort | head
+	// or sort | tail would, groups count as one line. 0 means all of them
+	Top    int
+	Bottom int
+
+	// Number of goroutines sorting at once, 0 and 1 mean sorting sequentially
+	Parallel int
+	// Inputs taking more memory than that are sorted in chunks spilled to TempDir, 0 means no limit
+	BufferSize int64
+	TempDir    string
+	// At most this many spilled chunks are merged at once, 0 means DefaultMergeBatch
+	MergeBatch int
+
+	// Check reports every pair of lines out of order, not only the first one
+	CheckAll bool
+}
+
+// Disorder is a pair of adjacent lines in the wrong order, Line is the number of the second one
+type Disorder struct {
+	Line int
+	Prev string
+	Cur  string
+}
+
+func (s *Sorter) format() string {
+	if s.Format == "" {
+		return FormatLines
+	}
+	return s.Format
+}
+
+// Validate reports options that can't be used together, Sort, Merge and Check call it too
+func (s *Sorter) Validate() error {
+	if s.Comparator == nil {
+		return errors.New("Sorter needs a comparator")
+	}
+
+	if s.DuplicatesOnly && s.UniqueOnly {
+		return errors.New("You can only choose one of duplicates only and unique only")
+	}
+
+	if s.Parallel < 0 {
+		return errors.New("Parallel must not be negative")
+	}
+
+	if s.MergeBatch < 0 || s.MergeBatch == 1 {
+		return errors.New("Merge batch must be at least 2")
+	}
+
+	if s.Top < 0 || s.Bottom < 0 {
+		return errors.New("Top and bottom must not be negative")
+	}
+
+	if s.Top > 0 && s.Bottom > 0 {
+		return errors.New("You can only choose one of top and bottom")
+	}
+
+	return s.checkFormat()
+}
+
+// Order of the sort. Groups are ordered by the keys only, so the stable sort keeps lines with
+// equal keys in the input order and the first line of a group is the first one of the input,
+// as with sort -u
+func (s *Sorter) compare(a, b *Item) int {
+	if s.grouping() {
+		return s.Comparator.CompareKeys(a, b)
+	}
+	return s.Comparator.Compare(a, b)
+}
+
+// SortItems sorts items stably, items of the Comparator of the Sorter only
+func (s *Sorter) SortItems(items []*Item) {
+	if s.Parallel > 1 {
+		parallelSortData(items, s.compare, s.Parallel)
+		return
+	}
+
+	slices.SortStableFunc(items, s.compare)
+}
+
+// Sort reads the inputs one after another and writes them out sorted
+func (s *Sorter) Sort(w io.Writer, inputs ...io.Reader) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	if s.partial() {
+		return s.partialSort(w, inputs)
+	}
+
+	if s.BufferSize != 0 {
+		return s.externalSort(w, inputs)
+	}
+
+	items, header, err := s.readItems(inputs)
+	if err != nil {
+		return err
+	}
+
+	s.SortItems(items)
+	return s.writeItems(w, items, header)
+}
+
+// Merge streams a merge of sorted inputs, only the current line of each input is kept in memory
+func (s *Sorter) Merge(w io.Writer, inputs ...io.Reader) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	if s.format() != FormatLines {
+		return fmt.Errorf("Format %v can't be merged", s.Format)
+	}
+
+	if s.partial() {
+		return errors.New("Top and bottom can't be merged")
+	}
+
+	writer := newSortedWriter(w, s, nil)
+	if err := mergeRuns(inputs, writer.write, s); err != nil {
+		return err
+	}
+
+	return writer.flush()
+}
+
+// Check finds lines out of the order of Sort, with Unique lines with equal keys are out of order too.
+// Lines are streamed and only the previous one is kept, records of csv, tsv and jsonl are read at once
+func (s *Sorter) Check(inputs ...io.Reader) ([]Disorder, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	c := &checker{s: s}
+	if s.format() != FormatLines {
+		items, header, err := s.readRecords(inputs)
+		if err != nil {
+			return nil, err
+		}
+
+		if header != nil {
+			c.line++
+		}
+		for _, item := range items {
+			if c.add(item) {
+				break
+			}
+		}
+		return c.disorders, nil
+	}
+
+	for _, input := range inputs {
+		sc := bufio.NewScanner(input)
+		for sc.Scan() {
+			if c.add(s.Comparator.NewItem(s.trim(sc.Text()))) {
+				return c.disorders, nil
+			}
+		}
+
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.disorders, nil
+}
+
+// Compares every line with the one before it
+type checker struct {
+	s         *Sorter
+	prev      *Item
+	line      int
+	disorders []Disorder
+}
+
+// Reports if the check is done, without CheckAll it is after the first disorder
+func (c *checker) add(item *Item) bool {
+	c.line++
+
+	if c.prev != nil {
+		var outOfOrder bool
+		if c.s.Unique {
+			outOfOrder = c.s.Comparator.CompareKeys(c.prev, item) >= 0
+		} else {
+			outOfOrder = c.s.Comparator.Compare(c.prev, item) > 0
+		}
+
+		if outOfOrder {
+			c.disorders = append(c.disorders, Disorder{Line: c.line, Prev: c.prev.Line, Cur: item.Line})
+		}
+	}
+
+	c.prev = item
+	return len(c.disorders) > 0 && !c.s.CheckAll
+}
+
+// Calls fn for every line of the inputs one after another, an input
+// without a trailing line break doesn't run into the next one
+func scanInputs(inputs []io.Reader, fn func(line string)) error {
+	for _, input := range inputs {
+		sc := bufio.NewScanner(input)
+		for sc.Scan() {
+			fn(sc.Text())
+		}
+
+		if err := sc.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Sorter) trim(line string) string {
+	if s.TrimSpace {
+		return strings.Trim(line, " ")
+	}
+	return line
+}
+
+// Items of the inputs and the header, if there is one
+func (s *Sorter) readItems(inputs []io.Reader) ([]*Item, *string, error) {
+	if s.format() != FormatLines {
+		return s.readRecords(inputs)
+	}
+
+	items := make([]*Item, 0)
+	err := scanInputs(inputs, func(line string) {
+		items = append(items, s.Comparator.NewItem(s.trim(line)))
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return items, nil, nil
+}
+
+func (s *Sorter) writeItems(w io.Writer, items []*Item, header *string) error {
+	writer := newSortedWriter(w, s, header)
+	for _, item := range items {
+		writer.write(item)
+	}
+
+	return writer.flush()
+}
+
+func (s *Sorter) grouping() bool {
+	return s.Unique || s.Count || s.DuplicatesOnly || s.UniqueOnly
+}
+
+// Writes sorted lines, groups of lines with equal keys as the Sorter wants
+type sortedWriter struct {
+	out *bufio.Writer
+	s   *Sorter
+	// First line of the current group and the number of lines in it
+	first *Item
+	count int
+}
+
+func newSortedWriter(stream io.Writer, s *Sorter, header *string) *sortedWriter {
+	w := &sortedWriter{out: bufio.NewWriter(stream), s: s}
+	if header != nil {
+		w.out.WriteString(*header)
+		w.out.WriteByte('\n')
+	}
+	return w
+}
+
+func (w *sortedWriter) write(item *Item) {
+	if !w.s.grouping() {
+		w.writeLine(item, 1)
+		return
+	}
+
+	if w.first != nil && w.s.Comparator.CompareKeys(w.first, item) == 0 {
+		w.count++
+		return
+	}
+
+	w.writeGroup()
+	w.first = item
+	w.count = 1
+}
+
+func (w *sortedWriter) writeGroup() {
+	switch {
+	case w.first == nil:
+	case w.s.DuplicatesOnly && w.count == 1:
+	case w.s.UniqueOnly && w.count > 1:
+	default:
+		w.writeLine(w.first, w.count)
+	}
+}
+
+// Counts are aligned as by uniq -c
+func (w *sortedWriter) writeLine(item *Item, count int) {
+	if w.s.Count {
+		fmt.Fprintf(w.out, "%7d ", count)
+	}
+	w.out.WriteString(item.Line)
+	w.out.WriteByte('\n')
+}
+
+// Writes out the last group and everything buffered
+func (w *sortedWriter) flush() error {
+	w.writeGroup()
+	w.first = nil
+	return w.out.Flush()
+}
@@ -0,0 +1,401 @@
+package sorting
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+)
+
+func sortString(t testing.TB, s *Sorter, input string) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := s.Sort(&out, strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestSorterSort(t *testing.T) {
+	tests := []struct {
+		name    string
+		sorter  func(c *Comparator) *Sorter
+		builder *ComparatorBuilder
+		input   string
+		want    string
+	}{
+		{
+			name:  "lines",
+			input: "b\nc\na\n",
+			want:  "a\nb\nc\n",
+		},
+		{
+			name:  "no trailing line break",
+			input: "b\na",
+			want:  "a\nb\n",
+		},
+		{
+			name:    "unique by keys",
+			builder: NewComparator().Key(&Key{StartField: 1, EndField: 1}),
+			sorter:  func(c *Comparator) *Sorter { return &Sorter{Comparator: c, Unique: true} },
+			input:   "b 1\na 2\nb 0\na 1\n",
+			want:    "a 2\nb 1\n",
+		},
+		{
+			name:    "count keeps the first line of a group",
+			builder: NewComparator().Key(&Key{StartField: 1, EndField: 1}),
+			sorter:  func(c *Comparator) *Sorter { return &Sorter{Comparator: c, Count: true} },
+			input:   "b 1\na 2\nb 0\na 1\n",
+			want:    "      2 a 2\n      2 b 1\n",
+		},
+		{
+			name:   "count",
+			sorter: func(c *Comparator) *Sorter { return &Sorter{Comparator: c, Count: true} },
+			input:  "b\na\nb\n",
+			want:   "      1 a\n      2 b\n",
+		},
+		{
+			name:   "duplicates only",
+			sorter: func(c *Comparator) *Sorter { return &Sorter{Comparator: c, DuplicatesOnly: true} },
+			input:  "b\na\nb\nc\nc\n",
+			want:   "b\nc\n",
+		},
+		{
+			name:   "unique only",
+			sorter: func(c *Comparator) *Sorter { return &Sorter{Comparator: c, UniqueOnly: true} },
+			input:  "b\na\nb\nc\nc\n",
+			want:   "a\n",
+		},
+		{
+			name:   "trim space",
+			sorter: func(c *Comparator) *Sorter { return &Sorter{Comparator: c, TrimSpace: true} },
+			input:  "  b\na  \n",
+			want:   "a\nb\n",
+		},
+		{
+			name:    "csv with header",
+			builder: NewComparator().Key(&Key{Name: "age", StartField: 1, EndField: 1, Numeric: true}),
+			sorter:  func(c *Comparator) *Sorter { return &Sorter{Comparator: c, Format: FormatCsv, Header: true} },
+			input:   "name,age\nbob,30\n\"smith, al\",4\n",
+			want:    "name,age\n\"smith, al\",4\nbob,30\n",
+		},
+		{
+			name:    "tsv by column",
+			builder: NewComparator().Key(&Key{StartField: 2, EndField: 2}),
+			sorter:  func(c *Comparator) *Sorter { return &Sorter{Comparator: c, Format: FormatTsv} },
+			input:   "x y\tb\nz\ta\n",
+			want:    "z\ta\nx y\tb\n",
+		},
+		{
+			name:    "jsonl by path",
+			builder: NewComparator().Key(&Key{Name: ".user.age", StartField: 1, EndField: 1, Numeric: true, Reverse: true}),
+			sorter:  func(c *Comparator) *Sorter { return &Sorter{Comparator: c, Format: FormatJsonl} },
+			input:   "{\"user\":{\"age\":3}}\n{\"user\":{\"age\":10}}\n",
+			want:    "{\"user\":{\"age\":10}}\n{\"user\":{\"age\":3}}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := tt.builder
+			if builder == nil {
+				builder = NewComparator()
+			}
+			c := mustComparator(t, builder)
+
+			s := &Sorter{Comparator: c}
+			if tt.sorter != nil {
+				s = tt.sorter(c)
+			}
+
+			if got := sortString(t, s, tt.input); got != tt.want {
+				t.Errorf("Sort() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Every csv or tsv input has a header, only the first one is written
+func TestSorterSortHeaders(t *testing.T) {
+	c := mustComparator(t, NewComparator().Key(&Key{Name: "age", StartField: 1, EndField: 1, Numeric: true}))
+
+	tests := []struct {
+		name   string
+		sorter *Sorter
+		inputs []string
+		want   string
+	}{
+		{
+			name:   "csv",
+			sorter: &Sorter{Comparator: c, Format: FormatCsv, Header: true},
+			inputs: []string{"name,age\nbob,30\nal,4", "name,age\ncid,12\n", ""},
+			want:   "name,age\nal,4\ncid,12\nbob,30\n",
+		},
+		{
+			name:   "tsv",
+			sorter: &Sorter{Comparator: c, Format: FormatTsv, Header: true},
+			inputs: []string{"", "name\tage\nbob\t30\n", "name\tage\ncid\t12\n"},
+			want:   "name\tage\ncid\t12\nbob\t30\n",
+		},
+		{
+			name:   "top",
+			sorter: &Sorter{Comparator: c, Format: FormatCsv, Header: true, Top: 1},
+			inputs: []string{"name,age\nbob,30\n", "name,age\ncid,12\n"},
+			want:   "name,age\ncid,12\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inputs []io.Reader
+			for _, input := range tt.inputs {
+				inputs = append(inputs, strings.NewReader(input))
+			}
+
+			var out bytes.Buffer
+			if err := tt.sorter.Sort(&out, inputs...); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Sort() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+
+	s := &Sorter{Comparator: c, Format: FormatCsv, Header: true}
+	if err := s.Sort(&bytes.Buffer{}, strings.NewReader("name,age\nbob,30\n"), strings.NewReader("age,name\n12,cid\n")); err == nil {
+		t.Error("Sort() of inputs with different headers succeeded, want error")
+	}
+}
+
+func TestSorterReusesComparatorForHeaders(t *testing.T) {
+	c := mustComparator(t, NewComparator().Key(&Key{Name: "age", StartField: 1, EndField: 1, Numeric: true}))
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "name,age\nbob,30\nal,4\n", want: "name,age\nal,4\nbob,30\n"},
+		{input: "age,name\n30,bob\n4,al\n", want: "age,name\n4,al\n30,bob\n"},
+	}
+
+	// The comparator sorts inputs with the column at different places, also at once
+	var wg sync.WaitGroup
+	for range 4 {
+		for _, tt := range tests {
+			wg.Go(func() {
+				s := &Sorter{Comparator: c, Format: FormatCsv, Header: true}
+				var out bytes.Buffer
+				if err := s.Sort(&out, strings.NewReader(tt.input)); err != nil {
+					t.Error(err)
+					return
+				}
+				if out.String() != tt.want {
+					t.Errorf("Sort() = %q, want %q", out.String(), tt.want)
+				}
+			})
+		}
+	}
+	wg.Wait()
+}
+
+func TestSorterMerge(t *testing.T) {
+	s := &Sorter{Comparator: mustComparator(t, NewComparator().Defaults(Key{Numeric: true})), Unique: true}
+
+	var out bytes.Buffer
+	err := s.Merge(&out, strings.NewReader("1\n3\n5\n"), strings.NewReader("2\n3\n"), strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "1\n2\n3\n5\n"; out.String() != want {
+		t.Errorf("Merge() = %q, want %q", out.String(), want)
+	}
+}
+
+func TestSorterCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorter Sorter
+		input  string
+		want   []Disorder
+	}{
+		{name: "sorted", input: "a\nb\nb\n"},
+		{name: "empty", input: ""},
+		{
+			name:  "first disorder",
+			input: "a\nc\nb\na\n",
+			want:  []Disorder{{Line: 3, Prev: "c", Cur: "b"}},
+		},
+		{
+			name:   "all disorders",
+			sorter: Sorter{CheckAll: true},
+			input:  "a\nc\nb\na\n",
+			want:   []Disorder{{Line: 3, Prev: "c", Cur: "b"}, {Line: 4, Prev: "b", Cur: "a"}},
+		},
+		{
+			name:   "unique",
+			sorter: Sorter{Unique: true},
+			input:  "a\nb\nb\n",
+			want:   []Disorder{{Line: 3, Prev: "b", Cur: "b"}},
+		},
+		{
+			name:   "header is counted",
+			sorter: Sorter{Format: FormatCsv, Header: true},
+			input:  "name\nb\na\n",
+			want:   []Disorder{{Line: 3, Prev: "b", Cur: "a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.sorter
+			s.Comparator = mustComparator(t, NewComparator())
+
+			got, err := s.Check(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Check() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSorterCheckStreams(t *testing.T) {
+	s := &Sorter{Comparator: mustComparator(t, NewComparator())}
+	broken := iotest.ErrReader(errors.New("broken input"))
+
+	// The first disorder is found before the input breaks, nothing after it is read
+	got, err := s.Check(io.MultiReader(strings.NewReader("a\nc\nb\n"), broken))
+	if err != nil || !slices.Equal(got, []Disorder{{Line: 3, Prev: "c", Cur: "b"}}) {
+		t.Errorf("Check() = %+v, %v, want the disorder at line 3", got, err)
+	}
+
+	// Lines are numbered across the inputs
+	got, err = s.Check(strings.NewReader("a\nb\n"), strings.NewReader("c\na\n"))
+	if err != nil || !slices.Equal(got, []Disorder{{Line: 4, Prev: "c", Cur: "a"}}) {
+		t.Errorf("Check() of two inputs = %+v, %v, want the disorder at line 4", got, err)
+	}
+
+	if _, err := s.Check(io.MultiReader(strings.NewReader("a\nb\n"), broken)); err == nil {
+		t.Error("Check() of a broken sorted input succeeds")
+	}
+}
+
+func TestSorterValidate(t *testing.T) {
+	c := mustComparator(t, NewComparator())
+	named := mustComparator(t, NewComparator().Key(&Key{Name: "age", StartField: 1, EndField: 1}))
+
+	tests := []struct {
+		name   string
+		sorter Sorter
+	}{
+		{name: "no comparator", sorter: Sorter{}},
+		{name: "duplicates and unique only", sorter: Sorter{Comparator: c, DuplicatesOnly: true, UniqueOnly: true}},
+		{name: "negative parallel", sorter: Sorter{Comparator: c, Parallel: -1}},
+		{name: "merge batch of one", sorter: Sorter{Comparator: c, MergeBatch: 1}},
+		{name: "unknown format", sorter: Sorter{Comparator: c, Format: "xml"}},
+		{name: "header of lines", sorter: Sorter{Comparator: c, Header: true}},
+		{name: "named keys of lines", sorter: Sorter{Comparator: named}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.sorter.Validate(); err == nil {
+				t.Error("Validate() succeeded, want error")
+			}
+		})
+	}
+
+	s := &Sorter{Comparator: c, Format: FormatCsv}
+	if err := s.Merge(&bytes.Buffer{}); err == nil {
+		t.Error("Merge() of csv succeeded, want error")
+	}
+}
+
+// Runs beyond the merge batch are merged in several passes, stably and without leftover files
+func TestSorterExternalMergePasses(t *testing.T) {
+	c := mustComparator(t, NewComparator().Key(&Key{StartField: 1, EndField: 1, Numeric: true}))
+
+	var input strings.Builder
+	for i := range 3000 {
+		fmt.Fprintf(&input, "%v line %v\n", (i*7919)%50, i)
+	}
+
+	want := sortString(t, &Sorter{Comparator: c}, input.String())
+	for _, batch := range []int{2, 3, 16} {
+		tempDir := t.TempDir()
+		got := sortString(t, &Sorter{Comparator: c, BufferSize: 512, TempDir: tempDir, MergeBatch: batch}, input.String())
+		if got != want {
+			t.Errorf("external sort with merge batch %v differs from the in-memory sort", batch)
+		}
+
+		if entries, err := os.ReadDir(tempDir); err != nil || len(entries) != 0 {
+			t.Errorf("Merge batch %v left %v temporary files, %v", batch, len(entries), err)
+		}
+	}
+}
+
+// In memory, external, parallel and partial sorting must give the same, sorted output
+func FuzzSorter(f *testing.F) {
+	f.Add("b 2\na 10\nc 1\nb 2\n", uint8(0), false, uint8(2))
+	f.Add("Ёж\nеж\nж\n\n  x\n", uint8(3), true, uint8(0))
+	f.Add("1.10\n1.9\n1.9~rc\n", uint8(2), false, uint8(1))
+
+	modes := []Key{
+		{},
+		{Numeric: true},
+		{Version: true},
+		{Reverse: true, IgnoreBlanks: true},
+		{StartField: 2, Human: true},
+	}
+
+	f.Fuzz(func(t *testing.T, input string, mode uint8, unique bool, limit uint8) {
+		key := modes[int(mode)%len(modes)]
+		builder := NewComparator()
+		if key.StartField != 0 {
+			builder.Key(&key)
+		} else {
+			builder.Defaults(key)
+		}
+		c := mustComparator(t, builder)
+
+		want := sortString(t, &Sorter{Comparator: c, Unique: unique}, input)
+		if got := sortString(t, &Sorter{Comparator: c, Unique: unique, BufferSize: 64, TempDir: t.TempDir()}, input); got != want {
+			t.Fatalf("external sort = %q, want %q", got, want)
+		}
+		if got := sortString(t, &Sorter{Comparator: c, Unique: unique, Parallel: 3}, input); got != want {
+			t.Fatalf("parallel sort = %q, want %q", got, want)
+		}
+
+		if limit > 0 {
+			lines := strings.SplitAfter(want, "\n")
+			lines = lines[:len(lines)-1]
+			n := min(int(limit), len(lines))
+
+			if got := sortString(t, &Sorter{Comparator: c, Unique: unique, Top: int(limit)}, input); got != strings.Join(lines[:n], "") {
+				t.Fatalf("top %v = %q, want the head of %q", limit, got, want)
+			}
+			if got := sortString(t, &Sorter{Comparator: c, Unique: unique, Bottom: int(limit)}, input); got != strings.Join(lines[len(lines)-n:], "") {
+				t.Fatalf("bottom %v = %q, want the tail of %q", limit, got, want)
+			}
+		}
+
+		check := &Sorter{Comparator: c, Unique: unique}
+		disorders, err := check.Check(strings.NewReader(want))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(disorders) != 0 {
+			t.Fatalf("output of Sort isn't sorted: %+v", disorders)
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package sorting
+
+import (
+	"container/heap"
+	"io"
+	"slices"
+)
+
+// Item with its position in the input, equal items keep the input order as in the stable sort
+type seqItem struct {
+	item *Item
+	seq  int
+}
+
+// Keeps the first n items of the sorted order, or the last n ones for bottom. The root is
+// the item to drop next: the last kept one for top and the first kept one for bottom
+type boundedHeap struct {
+	items   []seqItem
+	n       int
+	bottom  bool
+	seq     int
+	compare func(a, b *Item) int
+}
+
+func compareSeq(compare func(a, b *Item) int, a, b seqItem) int {
+	if res := compare(a.item, b.item); res != 0 {
+		return res
+	}
+	return a.seq - b.seq
+}
+
+func (h *boundedHeap) compareSeq(a, b seqItem) int {
+	return compareSeq(h.compare, a, b)
+}
+
+func (h *boundedHeap) Len() int { return len(h.items) }
+
+func (h *boundedHeap) Less(i, j int) bool {
+	if h.bottom {
+		return h.compareSeq(h.items[i], h.items[j]) < 0
+	}
+	return h.compareSeq(h.items[i], h.items[j]) > 0
+}
+
+func (h *boundedHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *boundedHeap) Push(x any) { h.items = append(h.items, x.(seqItem)) }
+
+func (h *boundedHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+func (h *boundedHeap) add(item *Item) {
+	x := seqItem{item: item, seq: h.seq}
+	h.seq++
+
+	if len(h.items) < h.n {
+		heap.Push(h, x)
+		return
+	}
+
+	// Later items lose ties, so they only get in past the root if they're strictly before it for
+	// top. For bottom they win ties, since they come after the root in the sorted order
+	res := h.compareSeq(x, h.items[0])
+	if (!h.bottom && res < 0) || (h.bottom && res > 0) {
+		h.items[0] = x
+		heap.Fix(h, 0)
+	}
+}
+
+// Kept items in the sorted order
+func (h *boundedHeap) sorted() []*Item {
+	slices.SortFunc(h.items, h.compareSeq)
+
+	items := make([]*Item, len(h.items))
+	for idx, x := range h.items {
+		items[idx] = x.item
+	}
+	return items
+}
+
+// Lines with equal keys, first is the line written for them
+type lineGroup struct {
+	first seqItem
+	count int
+}
+
+// Groups are merged once there are that many more lines than groups kept by the last merge
+const minGroupBatch = 1024
+
+// Keeps the first n groups of the sorted order, or the last n ones for bottom. New lines are
+// groups of their own until they are sorted and merged with the kept groups, so about 3n
+// lines are held at once. With filter set merged groups aren't cut before all lines are
+// added, as the count of a group decides if it is written and it is only known at the end,
+// so about twice as many lines as there are distinct keys are held
+type boundedGroups struct {
+	groups []lineGroup
+	n      int
+	bottom bool
+	filter func(count int) bool
+	seq    int
+	c      *Comparator
+	// Number of groups after the last merge
+	kept int
+}
+
+func (g *boundedGroups) add(item *Item) {
+	g.groups = append(g.groups, lineGroup{first: seqItem{item: item, seq: g.seq}, count: 1})
+	g.seq++
+
+	if len(g.groups) < 2*max(g.kept, minGroupBatch) {
+		return
+	}
+
+	g.merge()
+	if g.filter == nil {
+		g.cut()
+	}
+	g.kept = len(g.groups)
+}
+
+// Sorts the groups and merges the ones with equal keys, the first line of the merged
+// groups is the first one in the stable order
+func (g *boundedGroups) merge() {
+	slices.SortFunc(g.groups, func(a, b lineGroup) int { return compareSeq(g.c.CompareKeys, a.first, b.first) })
+
+	merged := g.groups[:0]
+	for _, group := range g.groups {
+		if last := len(merged) - 1; last >= 0 && g.c.CompareKeys(merged[last].first.item, group.first.item) == 0 {
+			merged[last].count += group.count
+			continue
+		}
+		merged = append(merged, group)
+	}
+
+	clear(g.groups[len(merged):])
+	g.groups = merged
+}
+
+func (g *boundedGroups) cut() {
+	if len(g.groups) <= g.n {
+		return
+	}
+
+	if g.bottom {
+		g.groups = slices.Delete(g.groups, 0, len(g.groups)-g.n)
+	} else {
+		g.groups = slices.Delete(g.groups, g.n, len(g.groups))
+	}
+}
+
+// Kept groups in the sorted order
+func (g *boundedGroups) sorted() []lineGroup {
+	g.merge()
+	if g.filter != nil {
+		g.groups = slices.DeleteFunc(g.groups, func(group lineGroup) bool { return !g.filter(group.count) })
+	}
+	g.cut()
+	return g.groups
+}
+
+func (s *Sorter) partial() bool {
+	return s.Top > 0 || s.Bottom > 0
+}
+
+func (s *Sorter) limit() (int, bool) {
+	if s.Bottom > 0 {
+		return s.Bottom, true
+	}
+	return s.Top, false
+}
+
+// Items of the inputs one after another, records of csv, tsv and jsonl are read at once as
+// they are for sorting
+func (s *Sorter) addInputs(inputs []io.Reader, add func(item *Item)) (*string, error) {
+	if s.format() != FormatLines {
+		items, header, err := s.readRecords(inputs)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			add(item)
+		}
+		return header, nil
+	}
+
+	return nil, scanInputs(inputs, func(line string) {
+		add(s.Comparator.NewItem(s.trim(line)))
+	})
+}
+
+// Writes the first Top or the last Bottom lines of the sorted inputs, holding only as many
+// lines in memory. With grouping the limit applies to the groups that are written, duplicates
+// only and unique only hold a group for every distinct key until the end
+func (s *Sorter) partialSort(w io.Writer, inputs []io.Reader) error {
+	n, bottom := s.limit()
+
+	if !s.grouping() {
+		h := &boundedHeap{n: n, bottom: bottom, compare: s.compare}
+		header, err := s.addInputs(inputs, h.add)
+		if err != nil {
+			return err
+		}
+		return s.writeItems(w, h.sorted(), header)
+	}
+
+	g := &boundedGroups{n: n, bottom: bottom, c: s.Comparator}
+	switch {
+	case s.DuplicatesOnly:
+		g.filter = func(count int) bool { return count > 1 }
+	case s.UniqueOnly:
+		g.filter = func(count int) bool { return count == 1 }
+	}
+
+	header, err := s.addInputs(inputs, g.add)
+	if err != nil {
+		return err
+	}
+
+	writer := newSortedWriter(w, s, header)
+	for _, group := range g.sorted() {
+		writer.writeLine(group.first.item, group.count)
+	}
+	return writer.flush()
+}
@@ -0,0 +1,141 @@
+package sorting
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSorterTop(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorter Sorter
+		input  string
+		want   string
+	}{
+		{name: "top", sorter: Sorter{Top: 2}, input: "d\nb\nc\na\n", want: "a\nb\n"},
+		{name: "bottom", sorter: Sorter{Bottom: 2}, input: "d\nb\nc\na\n", want: "c\nd\n"},
+		{name: "more than the input", sorter: Sorter{Top: 10}, input: "b\na\n", want: "a\nb\n"},
+		{name: "empty input", sorter: Sorter{Bottom: 3}, input: "", want: ""},
+		{name: "csv keeps the header", sorter: Sorter{Top: 1, Format: FormatCsv, Header: true}, input: "h\nb\na\n", want: "h\na\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.sorter
+			s.Comparator = mustComparator(t, NewComparator())
+
+			if got := sortString(t, &s, tt.input); got != tt.want {
+				t.Errorf("Sort() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Lines with equal keys must come out in the input order, as the stable sort writes them
+func TestSorterTopTies(t *testing.T) {
+	c := mustComparator(t, NewComparator().Key(&Key{StartField: 1, EndField: 1, Numeric: true}).IgnoreCase(true))
+	input := "1 a\n1 A\n0 x\n1 a\n2 y\n1 A\n"
+
+	tests := []struct {
+		sorter Sorter
+		want   string
+	}{
+		{sorter: Sorter{Top: 3}, want: "0 x\n1 a\n1 A\n"},
+		{sorter: Sorter{Bottom: 3}, want: "1 a\n1 A\n2 y\n"},
+	}
+
+	for _, tt := range tests {
+		s := tt.sorter
+		s.Comparator = c
+
+		if got := sortString(t, &s, input); got != tt.want {
+			t.Errorf("Sort() with top %v, bottom %v = %q, want %q", s.Top, s.Bottom, got, tt.want)
+		}
+	}
+}
+
+// Groups count as one line, the output is the one of sort -u | head
+func TestSorterTopGroups(t *testing.T) {
+	c := mustComparator(t, NewComparator().Key(&Key{StartField: 1, EndField: 1}))
+	input := "c 1\na 1\nb 1\na 2\nd 1\nb 2\nc 2\na 3\n"
+
+	tests := []struct {
+		name   string
+		sorter Sorter
+		want   string
+	}{
+		{name: "unique top", sorter: Sorter{Unique: true, Top: 2}, want: "a 1\nb 1\n"},
+		{name: "unique bottom", sorter: Sorter{Unique: true, Bottom: 2}, want: "c 1\nd 1\n"},
+		{name: "count top", sorter: Sorter{Count: true, Top: 1}, want: "      3 a 1\n"},
+		{name: "count bottom", sorter: Sorter{Count: true, Bottom: 2}, want: "      2 c 1\n      1 d 1\n"},
+		{name: "duplicates only top", sorter: Sorter{DuplicatesOnly: true, Top: 3}, want: "a 1\nb 1\nc 1\n"},
+		{name: "duplicates only bottom", sorter: Sorter{DuplicatesOnly: true, Bottom: 1}, want: "c 1\n"},
+		{name: "unique only", sorter: Sorter{UniqueOnly: true, Top: 5}, want: "d 1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.sorter
+			s.Comparator = c
+
+			if got := sortString(t, &s, input); got != tt.want {
+				t.Errorf("Sort() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Groups are merged and cut several times on the way
+func TestSorterTopGroupsLarge(t *testing.T) {
+	c := mustComparator(t, NewComparator().Key(&Key{StartField: 1, EndField: 1, Numeric: true}))
+
+	var input strings.Builder
+	for i := range 20 * minGroupBatch {
+		fmt.Fprintf(&input, "%v %v\n", (i*7919)%3001, i)
+	}
+
+	for _, sorter := range []Sorter{{Unique: true}, {Count: true}, {DuplicatesOnly: true}, {UniqueOnly: true}} {
+		sorter.Comparator = c
+		all := sorter
+		lines := strings.SplitAfter(sortString(t, &all, input.String()), "\n")
+		lines = lines[:len(lines)-1]
+
+		for _, n := range []int{1, 10, 2500} {
+			top, bottom := sorter, sorter
+			top.Top, bottom.Bottom = n, n
+			n = min(n, len(lines))
+
+			if got := sortString(t, &top, input.String()); got != strings.Join(lines[:n], "") {
+				t.Errorf("%+v: top %v isn't the head of the output", sorter, n)
+			}
+			if got := sortString(t, &bottom, input.String()); got != strings.Join(lines[len(lines)-n:], "") {
+				t.Errorf("%+v: bottom %v isn't the tail of the output", sorter, n)
+			}
+		}
+	}
+}
+
+// With a filter groups are merged on the way but not cut, so only distinct keys add up
+func TestBoundedGroupsFilterMemory(t *testing.T) {
+	c := mustComparator(t, NewComparator().Key(&Key{StartField: 1, EndField: 1, Numeric: true}))
+	const keys = 3 * minGroupBatch
+
+	g := &boundedGroups{n: 1, filter: func(count int) bool { return count == 1 }, c: c}
+	for i := range 30 * minGroupBatch {
+		// Key 0 comes once, every other key many times
+		key := 0
+		if i > 0 {
+			key = 1 + i%(keys-1)
+		}
+		g.add(c.NewItem(fmt.Sprintf("%v %v", key, i)))
+
+		if len(g.groups) > 2*keys {
+			t.Fatalf("%v groups held after %v lines of %v keys", len(g.groups), i+1, keys)
+		}
+	}
+
+	if groups := g.sorted(); len(groups) != 1 || groups[0].first.item.Line != "0 0" {
+		t.Errorf("Unique groups = %+v, want only key 0", groups)
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Bump together with a new entry in archiveMigrations whenever the archive layout changes
+const archiveVersion = 2
+
+type StoreDump[T interface{}] struct {
+	FirstFreeIdx int                    `json:"first_free_idx"`
+	Objects      map[int]*T             `json:"objects"`
+	History      map[int][]*Revision[T] `json:"history"`
+}
+
+type Archive struct {
+	Version   int                             `json:"version"`
+	CreatedAt time.Time                       `json:"created_at"`
+	Users     *StoreDump[User]                `json:"users"`
+	Events    map[int]*StoreDump[Event]       `json:"events"`
+	Pages     map[int]*StoreDump[BookingPage] `json:"booking_pages,omitempty"`
+}
+
+// Each migration upgrades a decoded archive from the version it is keyed by to the next one
+var archiveMigrations = map[int]func(map[string]interface{}) error{
+	// Version 2 added booking pages, archives of version 1 written after they appeared have them already
+	1: func(raw map[string]interface{}) error {
+		if _, ok := raw["booking_pages"]; !ok {
+			raw["booking_pages"] = map[string]interface{}{}
+		}
+		return nil
+	},
+}
+
+func (s *Store[T]) dump() *StoreDump[T] {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	dump := &StoreDump[T]{
+		FirstFreeIdx: s.firstFreeIdx,
+		Objects:      make(map[int]*T, len(s.objMap)),
+		History:      make(map[int][]*Revision[T], len(s.history)),
+	}
+
+	for id, obj := range s.objMap {
+		dump.Objects[id] = snapshot(obj)
+	}
+
+	for id, revisions := range s.history {
+		dump.History[id] = append([]*Revision[T](nil), revisions...)
+	}
+
+	return dump
+}
+
+// Replaces the whole content of the store with the dump
+func (s *Store[T]) load(dump *StoreDump[T]) error {
+	objMap := make(map[int]*T, len(dump.Objects))
+	for id, obj := range dump.Objects {
+		if id < 0 || id >= dump.FirstFreeIdx {
+			return fmt.Errorf("Object id %v is out of range", id)
+		}
+		if obj == nil {
+			return fmt.Errorf("Object %v is empty", id)
+		}
+
+		objMap[id] = obj
+		if s.setId != nil {
+			s.setId(obj, id)
+		}
+	}
+
+	history := make(map[int][]*Revision[T], len(dump.History))
+	for id, revisions := range dump.History {
+		history[id] = revisions
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.firstFreeIdx = dump.FirstFreeIdx
+	s.objMap = objMap
+	s.history = history
+
+	return nil
+}
+
+// GET /admin/backup
+func backupStore(userStore *Store[User]) *Archive {
+	archive := &Archive{
+		Version:   archiveVersion,
+		CreatedAt: time.Now().UTC(),
+		Users:     userStore.dump(),
+		Events:    make(map[int]*StoreDump[Event]),
+		Pages:     make(map[int]*StoreDump[BookingPage]),
+	}
+
+	for id, user := range archive.Users.Objects {
+		archive.Events[id] = user.EventStore.dump()
+		archive.Pages[id] = user.BookingPages.dump()
+	}
+
+	return archive
+}
+
+// POST /admin/restore
+func restoreStore(archive *Archive, userStore *Store[User]) error {
+	if archive.Users == nil {
+		return errors.New("Archive has no users")
+	}
+
+	for id, user := range archive.Users.Objects {
+		user.EventStore = NewStore(func(e *Event, id int) { e.Id = id })
+		user.BookingPages = newBookingPageStore()
+
+		if events, ok := archive.Events[id]; ok {
+			if err := user.EventStore.load(events); err != nil {
+				return fmt.Errorf("Events of user %v: %w", id, err)
+			}
+		}
+
+		if pages, ok := archive.Pages[id]; ok {
+			if err := user.BookingPages.load(pages); err != nil {
+				return fmt.Errorf("Booking pages of user %v: %w", id, err)
+			}
+		}
+	}
+
+	return userStore.load(archive.Users)
+}
+
+func migrateArchive(data []byte) (*Archive, error) {
+	raw := make(map[string]interface{})
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	version, ok := raw["version"].(float64)
+	if !ok {
+		return nil, errors.New("Archive has no version")
+	}
+
+	if int(version) > archiveVersion {
+		return nil, fmt.Errorf("Archive version %v is newer than supported %v", int(version), archiveVersion)
+	}
+
+	for current := int(version); current < archiveVersion; current++ {
+		migrate, ok := archiveMigrations[current]
+		if !ok {
+			return nil, fmt.Errorf("Unsupported archive version: %v", current)
+		}
+
+		if err := migrate(raw); err != nil {
+			return nil, fmt.Errorf("Migration from version %v: %w", current, err)
+		}
+		raw["version"] = float64(current + 1)
+	}
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &Archive{}
+	if err := json.Unmarshal(migrated, archive); err != nil {
+		return nil, err
+	}
+
+	return archive, nil
+}
+
+// Admin endpoints stay closed until AdminToken is set in the config
+func AdminMiddleware(token string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			SendError(w, errors.New("Admin endpoints are disabled, set AdminToken in the config"), http.StatusForbidden)
+			return
+		}
+
+		given := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(given, []byte("Bearer "+token)) != 1 {
+			SendError(w, errors.New("Unauthorized"), http.StatusUnauthorized)
+			return
+		}
+
+		handler.ServeHTTP(w, withActor(r, "admin"))
+	})
+}
+
+func HandleBackup(w http.ResponseWriter, r *http.Request, userStore *Store[User]) {
+	archive, err := json.Marshal(backupStore(userStore))
+	if err != nil {
+		SendError(w, err, 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="calendar-backup.json"`)
+	w.Write(archive)
+}
+
+func HandleRestore(w http.ResponseWriter, r *http.Request, userStore *Store[User]) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	archive, err := migrateArchive(body)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	if err := restoreStore(archive, userStore); err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	SendResult(w, "Success")
+}
+
+func adminRequest(cfg *config, addr string, method string, path string, body io.Reader) (*http.Response, error) {
+	if addr == "" {
+		addr = fmt.Sprintf("http://localhost:%v", cfg.Port)
+	}
+
+	if cfg.AdminToken == "" {
+		return nil, errors.New("AdminToken isn't set in the config")
+	}
+
+	req, err := http.NewRequest(method, addr+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.AdminToken)
+
+	return http.DefaultClient.Do(req)
+}
+
+// calendar backup [-addr url] [-o file]
+func runBackup(cfg *config, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	addr := flags.String("addr", "", "Address of the running server (Default is localhost with configured port)")
+	output := flags.String("o", "", "Write archive to file instead of stdout")
+	flags.Parse(args)
+
+	resp, err := adminRequest(cfg, *addr, http.MethodGet, "/admin/backup", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("Server responded with %v: %s", resp.Status, msg)
+	}
+
+	var stream io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		stream = file
+	}
+
+	_, err = io.Copy(stream, resp.Body)
+	return err
+}
+
+// calendar restore [-addr url] file
+func runRestore(cfg *config, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	addr := flags.String("addr", "", "Address of the running server (Default is localhost with configured port)")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return errors.New("Expected archive file")
+	}
+
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	resp, err := adminRequest(cfg, *addr, http.MethodPost, "/admin/restore", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	msg, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Server responded with %v: %s", resp.Status, msg)
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Backup of the store as it comes back from the archive JSON
+func roundTrip(t *testing.T, userStore *Store[User]) (*Archive, *Store[User]) {
+	t.Helper()
+
+	data, err := json.Marshal(backupStore(userStore))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := migrateArchive(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewStore(func(u *User, id int) { u.Id = id })
+	if err := restoreStore(archive, restored); err != nil {
+		t.Fatal(err)
+	}
+	return archive, restored
+}
+
+func TestBackupRoundTrip(t *testing.T) {
+	userStore := NewStore(func(u *User, id int) { u.Id = id })
+	annaIdx := createUser("anna", "Europe/Moscow", "test", userStore)
+	borisIdx := createUser("boris", "", "test", userStore)
+	userStore.delete(borisIdx, "test")
+	cidIdx := createUser("cid", "", "test", userStore)
+
+	at := time.Date(2026, 3, 10, 9, 30, 0, 0, time.UTC)
+	var eventIds []int
+	for _, title := range []string{"Standup", "Lunch", "Retro"} {
+		eventIdx, err := createEvent(annaIdx, &Event{Title: title, EventTime: at, Tags: []string{"team"}}, "test", userStore)
+		if err != nil {
+			t.Fatal(err)
+		}
+		eventIds = append(eventIds, eventIdx)
+	}
+	if err := updateEvent(annaIdx, eventIds[0], &Event{Title: "Late standup", EventTime: at.Add(time.Hour)}, "test", userStore); err != nil {
+		t.Fatal(err)
+	}
+	if err := deleteEvent(annaIdx, eventIds[2], "test", userStore); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := createBookingPage(annaIdx, &BookingPage{
+		Title:           "Office hours",
+		DurationMinutes: 30,
+		WorkingHours:    []WorkingHours{{Weekday: 1, Start: "10:00", End: "12:00"}},
+		HorizonDays:     14,
+	}, "test", userStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, restored := roundTrip(t, userStore)
+	if archive.Version != archiveVersion {
+		t.Errorf("Archive version = %v, want %v", archive.Version, archiveVersion)
+	}
+
+	// Every store comes back the same, ids, counters and history included
+	want, _ := roundTrip(t, userStore)
+	got, _ := roundTrip(t, restored)
+	want.CreatedAt, got.CreatedAt = time.Time{}, time.Time{}
+	wantJson, _ := json.Marshal(want)
+	gotJson, _ := json.Marshal(got)
+	if !bytes.Equal(gotJson, wantJson) {
+		t.Errorf("Backup of the restored store differs from the original one:\n%s\n%s", gotJson, wantJson)
+	}
+
+	if _, err := restored.get(borisIdx); err == nil {
+		t.Errorf("Deleted user %v is restored", borisIdx)
+	}
+	cid, err := restored.get(cidIdx)
+	if err != nil || cid.Name != "cid" {
+		t.Errorf("User %v = %+v, %v, want cid", cidIdx, cid, err)
+	}
+	if anna, _ := restored.get(annaIdx); anna.TimeZone != "Europe/Moscow" {
+		t.Errorf("Time zone of anna = %q", anna.TimeZone)
+	}
+
+	// Counters go on after the restored ids, deleted ones aren't reused
+	if idx := createUser("dan", "", "test", restored); idx != cidIdx+1 {
+		t.Errorf("New user got id %v, want %v", idx, cidIdx+1)
+	}
+	eventIdx, err := createEvent(annaIdx, &Event{Title: "New", EventTime: at}, "test", restored)
+	if err != nil || eventIdx != eventIds[2]+1 {
+		t.Errorf("New event got id %v, %v, want %v", eventIdx, err, eventIds[2]+1)
+	}
+
+	anna, _ := restored.get(annaIdx)
+	event, err := anna.EventStore.get(eventIds[0])
+	if err != nil || event.Title != "Late standup" || event.Id != eventIds[0] {
+		t.Errorf("Event %v = %+v, %v", eventIds[0], event, err)
+	}
+	if history, err := anna.EventStore.getHistory(eventIds[2]); err != nil || len(history) != 2 {
+		t.Errorf("History of the deleted event has %v revisions, %v, want 2", len(history), err)
+	}
+
+	if _, restoredPage, err := findBookingPage(page.Token, restored); err != nil || restoredPage.Title != page.Title {
+		t.Errorf("Booking page %v = %+v, %v", page.Token, restoredPage, err)
+	}
+}
+
+func TestMigrateArchiveV1(t *testing.T) {
+	data, err := os.ReadFile("testdata/archive_v1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := migrateArchive(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archive.Version != archiveVersion || archive.Pages == nil {
+		t.Errorf("Migrated archive has version %v and pages %v", archive.Version, archive.Pages)
+	}
+
+	userStore := NewStore(func(u *User, id int) { u.Id = id })
+	if err := restoreStore(archive, userStore); err != nil {
+		t.Fatal(err)
+	}
+
+	anna, err := userStore.get(0)
+	if err != nil || anna.Name != "anna" {
+		t.Fatalf("User 0 = %+v, %v, want anna", anna, err)
+	}
+	if _, err := userStore.get(1); err == nil {
+		t.Error("User 1 is restored, the archive has none")
+	}
+
+	event, err := anna.EventStore.get(4)
+	if err != nil || event.Title != "Dentist" {
+		t.Errorf("Event 4 = %+v, %v, want Dentist", event, err)
+	}
+	if history, err := anna.EventStore.getHistory(1); err != nil || len(history) != 1 {
+		t.Errorf("History of event 1 = %v, %v, want one revision", history, err)
+	}
+	if pages, err := bookingPages(0, userStore); err != nil || len(pages) != 0 {
+		t.Errorf("Booking pages = %v, %v, want none", pages, err)
+	}
+
+	if idx := createUser("cid", "", "test", userStore); idx != 3 {
+		t.Errorf("New user got id %v, want 3", idx)
+	}
+	if idx, err := createEvent(0, &Event{Title: "New", EventTime: time.Now()}, "test", userStore); err != nil || idx != 5 {
+		t.Errorf("New event got id %v, %v, want 5", idx, err)
+	}
+}
+
+func TestRestoreInvalidArchives(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive string
+		want    string
+	}{
+		{name: "no version", archive: `{"users": {"first_free_idx": 0}}`, want: "no version"},
+		{name: "newer version", archive: `{"version": 99, "users": {"first_free_idx": 0}}`, want: "newer"},
+		{name: "unknown version", archive: `{"version": 0, "users": {"first_free_idx": 0}}`, want: "Unsupported"},
+		{name: "no users", archive: `{"version": 2}`, want: "no users"},
+		{name: "id past the counter", archive: `{"version": 2, "users": {"first_free_idx": 1, "objects": {"1": {"username": "a"}}}}`, want: "out of range"},
+		{name: "negative id", archive: `{"version": 2, "users": {"first_free_idx": 1, "objects": {"-1": {"username": "a"}}}}`, want: "out of range"},
+		{name: "event id past the counter", archive: `{"version": 2, "users": {"first_free_idx": 1, "objects": {"0": {"username": "a"}}}, "events": {"0": {"first_free_idx": 0, "objects": {"0": {"event_title": "x"}}}}}`, want: "out of range"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userStore := NewStore(func(u *User, id int) { u.Id = id })
+			createUser("anna", "", "test", userStore)
+
+			archive, err := migrateArchive([]byte(tt.archive))
+			if err == nil {
+				err = restoreStore(archive, userStore)
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Restore = %v, want an error with %q", err, tt.want)
+			}
+
+			// A failed restore leaves the store as it was
+			if user, err := userStore.get(0); err != nil || user.Name != "anna" {
+				t.Errorf("User 0 after a failed restore = %+v, %v", user, err)
+			}
+		})
+	}
+}
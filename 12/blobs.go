@@ -0,0 +1,211 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var blobIdPattern = regexp.MustCompile("^[0-9a-f]{64}$")
+
+// Types browsers can't run scripts from, blobs of any other type are served as octet-stream
+var safeMimeTypes = map[string]bool{
+	"application/pdf": true,
+	"application/zip": true,
+	"audio/mpeg":      true,
+	"audio/ogg":       true,
+	"image/gif":       true,
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/webp":      true,
+	"text/calendar":   true,
+	"text/csv":        true,
+	"text/plain":      true,
+	"video/mp4":       true,
+	"video/webm":      true,
+}
+
+// Drops parameters other than the charset of text types
+func safeMimeType(mimeType string) string {
+	mediaType, params, err := mime.ParseMediaType(mimeType)
+	if err != nil || !safeMimeTypes[mediaType] {
+		return "application/octet-stream"
+	}
+
+	if charset := params["charset"]; charset != "" && strings.HasPrefix(mediaType, "text/") {
+		return mime.FormatMediaType(mediaType, map[string]string{"charset": charset})
+	}
+	return mediaType
+}
+
+type BlobInfo struct {
+	Id       string `json:"blob_id"`
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+}
+
+// Content addressed store of attachment blobs kept in a local directory,
+// every blob is saved as <id> next to its metadata in <id>.json
+type BlobStore struct {
+	dir     string
+	maxSize int64
+}
+
+func NewBlobStore(dir string, maxSize int64) (*BlobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &BlobStore{dir: dir, maxSize: maxSize}, nil
+}
+
+func (b *BlobStore) path(id string) string {
+	return filepath.Join(b.dir, id)
+}
+
+func (b *BlobStore) put(content io.Reader, name string, mimeType string) (*BlobInfo, error) {
+	tmp, err := os.CreateTemp(b.dir, "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(content, b.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if size > b.maxSize {
+		return nil, errors.New("Blob is too large")
+	}
+
+	info := &BlobInfo{
+		Id:       hex.EncodeToString(hash.Sum(nil)),
+		Name:     name,
+		MimeType: mimeType,
+		Size:     size,
+	}
+
+	meta, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(tmp.Name(), b.path(info.Id)); err != nil {
+		return nil, err
+	}
+
+	// Blobs with equal content share the metadata of the first upload, the name an event shows
+	// is the one of its attachment
+	metaFile, err := os.OpenFile(b.path(info.Id)+".json", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return b.info(info.Id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := metaFile.Write(meta); err != nil {
+		metaFile.Close()
+		os.Remove(metaFile.Name())
+		return nil, err
+	}
+	if err := metaFile.Close(); err != nil {
+		os.Remove(metaFile.Name())
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func (b *BlobStore) info(id string) (*BlobInfo, error) {
+	if !blobIdPattern.MatchString(id) {
+		return nil, errors.New("No such blob")
+	}
+
+	meta, err := os.ReadFile(b.path(id) + ".json")
+	if err != nil {
+		return nil, errors.New("No such blob")
+	}
+
+	info := &BlobInfo{}
+	if err := json.Unmarshal(meta, info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func (b *BlobStore) open(id string) (*os.File, *BlobInfo, error) {
+	info, err := b.info(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(b.path(id))
+	if err != nil {
+		return nil, nil, errors.New("No such blob")
+	}
+
+	return file, info, nil
+}
+
+// POST /blobs
+func HandleUploadBlob(w http.ResponseWriter, r *http.Request, blobStore *BlobStore) {
+	info, err := blobStore.put(r.Body, r.URL.Query().Get("name"), safeMimeType(r.Header.Get("Content-Type")))
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	SendResult(w, info)
+}
+
+// GET /blobs/{id}
+func HandleDownloadBlob(w http.ResponseWriter, r *http.Request, blobStore *BlobStore) {
+	file, info, err := blobStore.open(r.PathValue("id"))
+	if err != nil {
+		SendError(w, err, 404)
+		return
+	}
+	defer file.Close()
+
+	// Blobs are never rendered inline, so an uploaded page can't run scripts on this origin.
+	// Blobs stored before the allow-list get it applied here
+	w.Header().Set("Content-Type", safeMimeType(info.MimeType))
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+
+	disposition := "attachment"
+	if info.Name != "" {
+		if formatted := mime.FormatMediaType("attachment", map[string]string{"filename": info.Name}); formatted != "" {
+			disposition = formatted
+		}
+	}
+	w.Header().Set("Content-Disposition", disposition)
+
+	io.Copy(w, file)
+}
+
+func BlobStoreWrapper(fn func(http.ResponseWriter, *http.Request, *BlobStore), blobStore *BlobStore) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fn(w, r, blobStore)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDownloadBlobHeaders(t *testing.T) {
+	blobStore, err := NewBlobStore(t.TempDir(), 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		mimeType    string
+		name        string
+		wantType    string
+		disposition string
+	}{
+		{mimeType: "text/html", name: "page.html", wantType: "application/octet-stream", disposition: `attachment; filename=page.html`},
+		{mimeType: "image/svg+xml", wantType: "application/octet-stream", disposition: "attachment"},
+		{mimeType: "image/png", wantType: "image/png", disposition: "attachment"},
+		{mimeType: "text/plain; charset=utf-8; x=1", wantType: "text/plain; charset=utf-8", disposition: "attachment"},
+		{mimeType: "", wantType: "application/octet-stream", disposition: "attachment"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mimeType, func(t *testing.T) {
+			upload := httptest.NewRequest(http.MethodPost, "/blobs?name="+tt.name, strings.NewReader("<script>alert(1)</script>"+tt.mimeType))
+			upload.Header.Set("Content-Type", tt.mimeType)
+			uploaded := httptest.NewRecorder()
+			HandleUploadBlob(uploaded, upload, blobStore)
+
+			var result struct {
+				Result BlobInfo `json:"result"`
+			}
+			if err := json.Unmarshal(uploaded.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			info := result.Result
+
+			req := httptest.NewRequest(http.MethodGet, "/blobs/"+info.Id, nil)
+			req.SetPathValue("id", info.Id)
+			rec := httptest.NewRecorder()
+			HandleDownloadBlob(rec, req, blobStore)
+
+			header := rec.Result().Header
+			if got := header.Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if got := header.Get("Content-Disposition"); got != tt.disposition {
+				t.Errorf("Content-Disposition = %q, want %q", got, tt.disposition)
+			}
+			if got := header.Get("X-Content-Type-Options"); got != "nosniff" {
+				t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+			}
+		})
+	}
+}
+
+func TestPutBlobKeepsFirstMetadata(t *testing.T) {
+	blobStore, err := NewBlobStore(t.TempDir(), 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := blobStore.put(strings.NewReader("minutes"), "notes.txt", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := blobStore.put(strings.NewReader("minutes"), "evil.html", "application/octet-stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *second != *first {
+		t.Errorf("Second upload = %+v, want the first metadata %+v", second, first)
+	}
+
+	file, info, err := blobStore.open(first.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if *info != *first {
+		t.Errorf("Stored metadata = %+v, want %+v", info, first)
+	}
+	if content, err := io.ReadAll(file); err != nil || string(content) != "minutes" {
+		t.Errorf("Content = %q, %v", content, err)
+	}
+
+	other, err := blobStore.put(strings.NewReader("agenda"), "agenda.txt", "text/plain")
+	if err != nil || other.Id == first.Id || other.Name != "agenda.txt" {
+		t.Errorf("Upload of other content = %+v, %v", other, err)
+	}
+}
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+)
+
+var (
+	ErrSlotTaken         = errors.New("Slot is not available")
+	ErrNotASlot          = errors.New("Start is not a slot of the booking page")
+	ErrNoSuchBookingPage = errors.New("No such booking page")
+)
+
+type WorkingHours struct {
+	Weekday time.Weekday `json:"weekday"`
+	Start   string       `json:"start"`
+	End     string       `json:"end"`
+}
+
+type BookingPage struct {
+	Id              int            `json:"page_id"`
+	Token           string         `json:"token"`
+	Title           string         `json:"title"`
+	DurationMinutes int            `json:"duration_minutes"`
+	BufferMinutes   int            `json:"buffer_minutes"`
+	WorkingHours    []WorkingHours `json:"working_hours"`
+	TimeZone        string         `json:"time_zone"`
+	HorizonDays     int            `json:"horizon_days"`
+	MaxPerDay       int            `json:"max_per_day"`
+}
+
+func (p *BookingPage) clone() *BookingPage {
+	copied := *p
+	copied.WorkingHours = slices.Clone(p.WorkingHours)
+	return &copied
+}
+
+type Slot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+type bookingRequest struct {
+	Start time.Time `json:"start"`
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+}
+
+func newBookingPageStore() *Store[BookingPage] {
+	return NewStore(func(p *BookingPage, id int) { p.Id = id })
+}
+
+// Offset of "HH:MM" from the start of the day
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid time of day: %v", s)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Wall clock time of the day, adding the offset to midnight is off by the shift on days of DST changes
+func atClock(year int, month time.Month, day int, clock time.Duration, loc *time.Location) time.Time {
+	return time.Date(year, month, day, int(clock/time.Hour), int(clock%time.Hour/time.Minute), 0, 0, loc)
+}
+
+func (p *BookingPage) validate() error {
+	if p.Title == "" {
+		return errors.New("Missing title")
+	}
+
+	if p.DurationMinutes <= 0 {
+		return errors.New("Duration must be positive")
+	}
+
+	if p.BufferMinutes < 0 || p.MaxPerDay < 0 {
+		return errors.New("Buffer and max per day can't be negative")
+	}
+
+	if p.HorizonDays <= 0 {
+		return errors.New("Horizon must be positive")
+	}
+
+	if _, err := time.LoadLocation(p.TimeZone); err != nil {
+		return fmt.Errorf("Unknown time zone: %v", p.TimeZone)
+	}
+
+	for _, hours := range p.WorkingHours {
+		if hours.Weekday < time.Sunday || hours.Weekday > time.Saturday {
+			return fmt.Errorf("Invalid weekday: %v", int(hours.Weekday))
+		}
+
+		start, err := parseClock(hours.Start)
+		if err != nil {
+			return err
+		}
+
+		end, err := parseClock(hours.End)
+		if err != nil {
+			return err
+		}
+
+		if start >= end {
+			return fmt.Errorf("Working hours %v-%v are empty", hours.Start, hours.End)
+		}
+	}
+
+	return nil
+}
+
+// Tag put on every event booked through the page
+func (p *BookingPage) tag() string {
+	return fmt.Sprintf("booking-page-%v", p.Id)
+}
+
+func (p *BookingPage) duration() time.Duration {
+	return time.Duration(p.DurationMinutes) * time.Minute
+}
+
+func (p *BookingPage) buffer() time.Duration {
+	return time.Duration(p.BufferMinutes) * time.Minute
+}
+
+// Events without duration occupy only their starting instant
+func eventEnd(ev *Event) time.Time {
+	return ev.EventTime.Add(time.Duration(ev.DurationMinutes) * time.Minute)
+}
+
+func (p *BookingPage) isFree(slot Slot, events []*Event) bool {
+	start := slot.Start.Add(-p.buffer())
+	end := slot.End.Add(p.buffer())
+
+	for _, ev := range events {
+		evEnd := eventEnd(ev)
+		if ev.EventTime.Equal(evEnd) {
+			if !ev.EventTime.Before(start) && ev.EventTime.Before(end) {
+				return false
+			}
+			continue
+		}
+
+		if ev.EventTime.Before(end) && evEnd.After(start) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *BookingPage) bookedBetween(start time.Time, end time.Time, events []*Event) int {
+	count := 0
+	for _, ev := range events {
+		if ev.hasAnyTag([]string{p.tag()}) && !ev.EventTime.Before(start) && ev.EventTime.Before(end) {
+			count++
+		}
+	}
+	return count
+}
+
+// Slots starting after now within the horizon that don't collide with the events.
+// Days off of the calendar have no slots, working days moved onto a weekend get the hours of a Monday
+func (p *BookingPage) openSlots(now time.Time, events []*Event, calendar workingCalendar) ([]Slot, error) {
+	loc, err := time.LoadLocation(p.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	now = now.In(loc)
+	year, month, day := now.Date()
+	slots := make([]Slot, 0)
+
+	for offset := range p.HorizonDays {
+		y, m, d := year, month, day+offset
+		dayStart := time.Date(y, m, d, 0, 0, 0, 0, loc)
+		dayEnd := time.Date(year, month, day+offset+1, 0, 0, 0, 0, loc)
+
+		if calendar.isDayOff(dayStart) {
+			continue
+		}
+
+		weekday := dayStart.Weekday()
+		if calendar.isMovedWorkday(dayStart) && (weekday == time.Saturday || weekday == time.Sunday) {
+			weekday = time.Monday
+		}
+
+		booked := p.bookedBetween(dayStart, dayEnd, events)
+
+		for _, hours := range p.WorkingHours {
+			if hours.Weekday != weekday {
+				continue
+			}
+
+			from, err := parseClock(hours.Start)
+			if err != nil {
+				return nil, err
+			}
+
+			to, err := parseClock(hours.End)
+			if err != nil {
+				return nil, err
+			}
+
+			windowEnd := atClock(y, m, d, to, loc)
+			for start := atClock(y, m, d, from, loc); !start.Add(p.duration()).After(windowEnd); start = start.Add(p.duration()) {
+				if p.MaxPerDay != 0 && booked >= p.MaxPerDay {
+					break
+				}
+
+				slot := Slot{Start: start, End: start.Add(p.duration())}
+				if start.After(now) && p.isFree(slot, events) {
+					slots = append(slots, slot)
+				}
+			}
+		}
+	}
+
+	return slots, nil
+}
+
+// Whether the start is a slot of the page if no events were booked
+func (p *BookingPage) offers(start time.Time, now time.Time, calendar workingCalendar) bool {
+	slots, err := p.openSlots(now, nil, calendar)
+	if err != nil {
+		return false
+	}
+
+	for _, slot := range slots {
+		if slot.Start.Equal(start) {
+			return true
+		}
+	}
+	return false
+}
+
+func generateToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+func findBookingPage(token string, userStore *Store[User]) (*User, *BookingPage, error) {
+	var owner *User
+	var page *BookingPage
+
+	userStore.iterate(func(user *User) {
+		user.BookingPages.iterate(func(p *BookingPage) {
+			if p.Token == token {
+				owner = user
+				page = p
+			}
+		})
+	})
+
+	if page == nil {
+		return nil, nil, ErrNoSuchBookingPage
+	}
+
+	return owner, page, nil
+}
+
+// POST /create_booking_page
+func createBookingPage(userIdx int, page *BookingPage, actor string, userStore *Store[User]) (*BookingPage, error) {
+	user, err := userStore.get(userIdx)
+	if err != nil {
+		return nil, err
+	}
+
+	if page.TimeZone == "" {
+		page.TimeZone = user.TimeZone
+	}
+	if page.TimeZone == "" {
+		page.TimeZone = "UTC"
+	}
+
+	if err := page.validate(); err != nil {
+		return nil, err
+	}
+
+	if page.Token, err = generateToken(); err != nil {
+		return nil, err
+	}
+
+	user.BookingPages.add(page, actor)
+	return page, nil
+}
+
+// GET /booking_pages
+func bookingPages(userIdx int, userStore *Store[User]) ([]*BookingPage, error) {
+	user, err := userStore.get(userIdx)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make([]*BookingPage, 0)
+	user.BookingPages.iterate(func(p *BookingPage) {
+		pages = append(pages, p)
+	})
+
+	return pages, nil
+}
+
+// GET /book/{token}/slots
+func bookingSlots(token string, now time.Time, userStore *Store[User], overlays *OverlayStore) ([]Slot, error) {
+	user, page, err := findBookingPage(token, userStore)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*Event
+	user.EventStore.iterate(func(ev *Event) {
+		events = append(events, ev)
+	})
+
+	return page.openSlots(now, events, overlays.calendar(user.Overlays))
+}
+
+// POST /book/{token}
+func book(token string, req *bookingRequest, now time.Time, userStore *Store[User], overlays *OverlayStore) (*Event, error) {
+	user, page, err := findBookingPage(token, userStore)
+	if err != nil {
+		return nil, err
+	}
+	calendar := overlays.calendar(user.Overlays)
+
+	event := &Event{
+		Id:              -1,
+		Title:           fmt.Sprintf("%v: %v", page.Title, req.Name),
+		EventTime:       req.Start,
+		DurationMinutes: page.DurationMinutes,
+		Description:     req.Email,
+		Tags:            []string{page.tag()},
+	}
+
+	// The slot is checked while the event store is locked, so concurrent
+	// bookings of the same slot can't both succeed
+	_, err = user.EventStore.addIf(event, "booking:"+req.Name, func(existing []*Event) error {
+		slots, err := page.openSlots(now, existing, calendar)
+		if err != nil {
+			return err
+		}
+
+		for _, slot := range slots {
+			if slot.Start.Equal(req.Start) {
+				return nil
+			}
+		}
+
+		// Tells a taken slot from a start the page never offers
+		if !page.offers(req.Start, now, calendar) {
+			return ErrNotASlot
+		}
+		return ErrSlotTaken
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func HandleCreateBookingPage(w http.ResponseWriter, r *http.Request, userStore *Store[User]) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	userIdx, err := parseUserIdx(body)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	page := &BookingPage{}
+	if err := json.Unmarshal(body, page); err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	page, err = createBookingPage(userIdx, page, getActor(r, userIdx), userStore)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	SendResult(w, page)
+}
+
+func HandleBookingPages(w http.ResponseWriter, r *http.Request, userStore *Store[User]) {
+	userIdx, err := parseUserIdxQuery(r)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	pages, err := bookingPages(userIdx, userStore)
+	if err != nil {
+		SendError(w, err, 404)
+		return
+	}
+
+	SendPage(w, r, pages, bookingPageOrder)
+}
+
+func HandleBookingSlots(w http.ResponseWriter, r *http.Request, userStore *Store[User], overlays *OverlayStore) {
+	slots, err := bookingSlots(r.PathValue("token"), time.Now(), userStore, overlays)
+	if errors.Is(err, ErrNoSuchBookingPage) {
+		SendError(w, err, 404)
+		return
+	}
+	if err != nil {
+		SendError(w, err, 500)
+		return
+	}
+
+	slotRefs := make([]*Slot, len(slots))
+	for idx := range slots {
+		slotRefs[idx] = &slots[idx]
+	}
+
+	SendPage(w, r, slotRefs, slotOrder)
+}
+
+func HandleBook(w http.ResponseWriter, r *http.Request, userStore *Store[User], overlays *OverlayStore) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	req := &bookingRequest{}
+	if err := json.Unmarshal(body, req); err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	if req.Name == "" {
+		SendError(w, errors.New("Missing name"), 400)
+		return
+	}
+
+	if req.Start.IsZero() {
+		SendError(w, errors.New("Missing start"), 400)
+		return
+	}
+
+	event, err := book(r.PathValue("token"), req, time.Now(), userStore, overlays)
+	switch {
+	case errors.Is(err, ErrSlotTaken):
+		SendError(w, err, http.StatusConflict)
+		return
+	case errors.Is(err, ErrNotASlot):
+		SendError(w, err, 400)
+		return
+	case errors.Is(err, ErrNoSuchBookingPage):
+		SendError(w, err, 404)
+		return
+	case err != nil:
+		SendError(w, err, 500)
+		return
+	}
+
+	SendResult(w, event)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestBookingPage(t *testing.T, page *BookingPage) (*Store[User], *BookingPage) {
+	t.Helper()
+
+	userStore := NewStore(func(u *User, id int) { u.Id = id })
+	userIdx := createUser("anna", "", "test", userStore)
+
+	page, err := createBookingPage(userIdx, page, "test", userStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return userStore, page
+}
+
+func TestOpenSlotsOnDstChange(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	// Clocks go forward from 02:00 to 03:00 on 2026-03-29 and back from 03:00 to 02:00 on 2026-10-25, both Sundays
+	tests := []struct {
+		now  time.Time
+		want []time.Time
+	}{
+		{
+			now:  time.Date(2026, 3, 28, 12, 0, 0, 0, loc),
+			want: []time.Time{time.Date(2026, 3, 29, 9, 0, 0, 0, loc), time.Date(2026, 3, 29, 10, 0, 0, 0, loc)},
+		},
+		{
+			now:  time.Date(2026, 10, 24, 12, 0, 0, 0, loc),
+			want: []time.Time{time.Date(2026, 10, 25, 9, 0, 0, 0, loc), time.Date(2026, 10, 25, 10, 0, 0, 0, loc)},
+		},
+	}
+
+	page := &BookingPage{
+		Title:           "Consultation",
+		DurationMinutes: 60,
+		WorkingHours:    []WorkingHours{{Weekday: time.Sunday, Start: "09:00", End: "11:00"}},
+		TimeZone:        "Europe/Berlin",
+		HorizonDays:     2,
+	}
+
+	for _, tt := range tests {
+		slots, err := page.openSlots(tt.now, nil, workingCalendar{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(slots) != len(tt.want) {
+			t.Fatalf("openSlots(%v) = %v, want %v", tt.now, slots, tt.want)
+		}
+		for idx, slot := range slots {
+			if !slot.Start.Equal(tt.want[idx]) {
+				t.Errorf("openSlots(%v)[%v] starts at %v, want %v", tt.now, idx, slot.Start.In(loc), tt.want[idx])
+			}
+		}
+	}
+}
+
+func TestBookErrors(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	userStore, page := newTestBookingPage(t, &BookingPage{
+		Title:           "Consultation",
+		DurationMinutes: 30,
+		WorkingHours:    []WorkingHours{{Weekday: time.Monday, Start: "09:00", End: "10:00"}},
+		TimeZone:        "UTC",
+		HorizonDays:     1,
+	})
+	overlays := &OverlayStore{}
+	slot := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	if _, err := book(page.Token, &bookingRequest{Start: slot, Name: "boris"}, now, userStore, overlays); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		start time.Time
+		want  error
+	}{
+		{name: "taken", token: page.Token, start: slot, want: ErrSlotTaken},
+		{name: "not a slot", token: page.Token, start: slot.Add(10 * time.Minute), want: ErrNotASlot},
+		{name: "out of hours", token: page.Token, start: slot.Add(-time.Hour), want: ErrNotASlot},
+		{name: "no page", token: "unknown", start: slot, want: ErrNoSuchBookingPage},
+	}
+
+	for _, tt := range tests {
+		_, err := book(tt.token, &bookingRequest{Start: tt.start, Name: "vera"}, now, userStore, overlays)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%v: book() = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestConcurrentBooking(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	userStore, page := newTestBookingPage(t, &BookingPage{
+		Title:           "Consultation",
+		DurationMinutes: 30,
+		WorkingHours:    []WorkingHours{{Weekday: time.Monday, Start: "09:00", End: "17:00"}},
+		TimeZone:        "UTC",
+		HorizonDays:     1,
+	})
+	overlays := &OverlayStore{}
+	slot := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	const attempts = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := book(page.Token, &bookingRequest{Start: slot, Name: "guest"}, now, userStore, overlays)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	booked := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			booked++
+		case !errors.Is(err, ErrSlotTaken):
+			t.Errorf("book() = %v, want %v", err, ErrSlotTaken)
+		}
+	}
+
+	if booked != 1 {
+		t.Errorf("%v bookings of the same slot succeeded, want 1", booked)
+	}
+
+	user, _ := userStore.get(0)
+	if count := user.EventStore.revisionCount(); count != 1 {
+		t.Errorf("Event store has %v revisions, want 1", count)
+	}
+}
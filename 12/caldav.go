@@ -0,0 +1,769 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	davNs        = "DAV:"
+	calDavNs     = "urn:ietf:params:xml:ns:caldav"
+	calServerNs  = "http://calendarserver.org/ns/"
+	calDavPrefix = "/caldav/"
+	icsMimeType  = "text/calendar; charset=utf-8"
+)
+
+type xmlElem struct {
+	XMLName xml.Name
+}
+
+type propList struct {
+	Names []xmlElem `xml:",any"`
+}
+
+type propfindRequest struct {
+	XMLName  xml.Name  `xml:"DAV: propfind"`
+	AllProp  *struct{} `xml:"DAV: allprop"`
+	PropName *struct{} `xml:"DAV: propname"`
+	Prop     *propList `xml:"DAV: prop"`
+}
+
+type timeRange struct {
+	Start string `xml:"start,attr"`
+	End   string `xml:"end,attr"`
+}
+
+type compFilter struct {
+	Name        string       `xml:"name,attr"`
+	TimeRange   *timeRange   `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	CompFilters []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+type reportRequest struct {
+	XMLName xml.Name
+	Prop    *propList   `xml:"DAV: prop"`
+	Hrefs   []string    `xml:"DAV: href"`
+	Filter  *compFilter `xml:"urn:ietf:params:xml:ns:caldav filter>comp-filter"`
+}
+
+type rawProp struct {
+	XMLName xml.Name
+	Inner   string `xml:",innerxml"`
+}
+
+type propstat struct {
+	Props  []rawProp `xml:"D:prop>any"`
+	Status string    `xml:"D:status"`
+}
+
+type davResponse struct {
+	Href      string     `xml:"D:href"`
+	Propstats []propstat `xml:"D:propstat,omitempty"`
+	Status    string     `xml:"D:status,omitempty"`
+}
+
+type multistatus struct {
+	XMLName   xml.Name      `xml:"D:multistatus"`
+	XmlnsD    string        `xml:"xmlns:D,attr"`
+	XmlnsC    string        `xml:"xmlns:C,attr"`
+	XmlnsCS   string        `xml:"xmlns:CS,attr"`
+	Responses []davResponse `xml:"D:response"`
+}
+
+// Serves the users' event stores as CalDAV calendar collections:
+//
+//	/caldav/                     calendar of the authenticated user
+//	/caldav/{user_id}/           calendar of the user
+//	/caldav/{user_id}/{name}.ics single event
+//
+// Clients authenticate with HTTP Basic, the user id as the name and the token of
+// POST /admin/caldav_token as the password, and only see their own calendar.
+// Resource names, UIDs and etags are kept in the event store, so they stay in step
+// with changes made through the other APIs
+type CalDAVHandler struct {
+	userStore *Store[User]
+}
+
+func NewCalDAVHandler(userStore *Store[User]) *CalDAVHandler {
+	return &CalDAVHandler{userStore: userStore}
+}
+
+var (
+	errPrecondition   = errors.New("Precondition failed")
+	errResourceExists = errors.New("Calendar object already exists")
+)
+
+// Names of events created through the other APIs, CalDAV clients can't create such ones
+var defaultNamePattern = regexp.MustCompile(`^(\d+)\.ics$`)
+
+func escapeXml(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func hrefXml(href string) string {
+	return "<D:href>" + escapeXml(href) + "</D:href>"
+}
+
+func calendarHref(userIdx int) string {
+	return fmt.Sprintf("%v%v/", calDavPrefix, userIdx)
+}
+
+// Name under which the event is exposed
+func calDavName(event *Event) string {
+	if event.CalDavName != "" {
+		return event.CalDavName
+	}
+	return fmt.Sprintf("%v.ics", event.Id)
+}
+
+func calDavUid(userIdx int, event *Event) string {
+	if event.Uid != "" {
+		return event.Uid
+	}
+	return fmt.Sprintf("event-%v-%v@calendar", userIdx, event.Id)
+}
+
+// Events updated through the other APIs keep the UID and the resource name they have in CalDAV
+func (e *Event) keepCalDavIdentity(old *Event) {
+	if e.Uid == "" {
+		e.Uid = old.Uid
+	}
+	e.CalDavName = old.CalDavName
+}
+
+// Looks up the event published under the given resource name
+func eventByName(user *User, name string) (*Event, bool) {
+	if match := defaultNamePattern.FindStringSubmatch(name); match != nil {
+		eventIdx, _ := strconv.Atoi(match[1])
+		event, err := user.EventStore.get(eventIdx)
+		return event, err == nil && event.CalDavName == ""
+	}
+
+	var found *Event
+	user.EventStore.iterate(func(ev *Event) {
+		if ev.CalDavName == name {
+			found = ev
+		}
+	})
+	return found, found != nil
+}
+
+func formatEtag(eventIdx int, version int) string {
+	return fmt.Sprintf(`"%v-%v"`, eventIdx, version)
+}
+
+func getEtag(user *User, eventIdx int) string {
+	return formatEtag(eventIdx, user.EventStore.version(eventIdx))
+}
+
+func getCtag(user *User) string {
+	return strconv.Itoa(user.EventStore.revisionCount())
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (u *User) checkCalDavToken(token string) bool {
+	return u.CalDavTokenHash != "" &&
+		subtle.ConstantTimeCompare([]byte(u.CalDavTokenHash), []byte(hashToken(token))) == 1
+}
+
+// Returns the user the request is authenticated as, -1 if the credentials don't match any
+func (h *CalDAVHandler) authenticate(r *http.Request) int {
+	name, token, ok := r.BasicAuth()
+	if !ok {
+		return -1
+	}
+
+	userIdx, err := strconv.Atoi(name)
+	if err != nil {
+		return -1
+	}
+
+	user, err := h.userStore.get(userIdx)
+	if err != nil || !user.checkCalDavToken(token) {
+		return -1
+	}
+
+	return userIdx
+}
+
+func (h *CalDAVHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/.well-known/caldav" {
+		http.Redirect(w, r, calDavPrefix, http.StatusMovedPermanently)
+		return
+	}
+
+	if r.Method == http.MethodOptions {
+		w.Header().Set("DAV", "1, 3, calendar-access")
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	authIdx := h.authenticate(r)
+	if authIdx == -1 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="calendar", charset="UTF-8"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	r = withActor(r, fmt.Sprintf("user:%v", authIdx))
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, calDavPrefix), "/")
+	var segments []string
+	if path != "" {
+		segments = strings.Split(path, "/")
+	}
+
+	if len(segments) > 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	userIdx := -1
+	var user *User
+	if len(segments) > 0 {
+		var err error
+		if userIdx, err = strconv.Atoi(segments[0]); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		if userIdx != authIdx {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		if user, err = h.userStore.get(userIdx); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	switch r.Method {
+	case "PROPFIND":
+		h.handlePropfind(w, r, authIdx, userIdx, user, segments)
+	case "REPORT":
+		if len(segments) != 1 {
+			http.Error(w, "REPORT is supported on calendar collections only", http.StatusMethodNotAllowed)
+			return
+		}
+		h.handleReport(w, r, userIdx, user)
+	case http.MethodGet, http.MethodHead:
+		if len(segments) != 2 {
+			http.Error(w, "Not a calendar object", http.StatusMethodNotAllowed)
+			return
+		}
+		h.handleGet(w, r, userIdx, user, segments[1])
+	case http.MethodPut:
+		if len(segments) != 2 {
+			http.Error(w, "Not a calendar object", http.StatusMethodNotAllowed)
+			return
+		}
+		h.handlePut(w, r, userIdx, user, segments[1])
+	case http.MethodDelete:
+		if len(segments) != 2 {
+			http.Error(w, "Not a calendar object", http.StatusMethodNotAllowed)
+			return
+		}
+		h.handleDelete(w, r, userIdx, user, segments[1])
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *CalDAVHandler) rootProps() map[xml.Name]string {
+	return map[xml.Name]string{
+		{Space: davNs, Local: "resourcetype"}: "<D:collection/>",
+		{Space: davNs, Local: "displayname"}:  "Calendars",
+	}
+}
+
+func (h *CalDAVHandler) calendarProps(userIdx int, user *User) map[xml.Name]string {
+	href := hrefXml(calendarHref(userIdx))
+
+	return map[xml.Name]string{
+		{Space: davNs, Local: "resourcetype"}:                        "<D:collection/><C:calendar/>",
+		{Space: davNs, Local: "displayname"}:                         escapeXml(user.Name),
+		{Space: davNs, Local: "owner"}:                               href,
+		{Space: davNs, Local: "current-user-principal"}:              href,
+		{Space: davNs, Local: "principal-URL"}:                       href,
+		{Space: davNs, Local: "getetag"}:                             escapeXml(`"` + getCtag(user) + `"`),
+		{Space: davNs, Local: "current-user-privilege-set"}:          "<D:privilege><D:read/></D:privilege><D:privilege><D:write/></D:privilege>",
+		{Space: davNs, Local: "supported-report-set"}:                "<D:supported-report><D:report><C:calendar-query/></D:report></D:supported-report><D:supported-report><D:report><C:calendar-multiget/></D:report></D:supported-report>",
+		{Space: calDavNs, Local: "calendar-home-set"}:                href,
+		{Space: calDavNs, Local: "calendar-description"}:             escapeXml("Events of " + user.Name),
+		{Space: calDavNs, Local: "supported-calendar-component-set"}: `<C:comp name="VEVENT"/>`,
+		{Space: calDavNs, Local: "supported-calendar-data"}:          `<C:calendar-data content-type="text/calendar" version="2.0"/>`,
+		{Space: calServerNs, Local: "getctag"}:                       getCtag(user),
+	}
+}
+
+func (h *CalDAVHandler) eventProps(userIdx int, user *User, event *Event, withData bool) map[xml.Name]string {
+	data := eventToIcs(event, calDavUid(userIdx, event))
+	props := map[xml.Name]string{
+		{Space: davNs, Local: "resourcetype"}:     "",
+		{Space: davNs, Local: "getetag"}:          escapeXml(getEtag(user, event.Id)),
+		{Space: davNs, Local: "getcontenttype"}:   icsMimeType + "; component=vevent",
+		{Space: davNs, Local: "displayname"}:      escapeXml(event.Title),
+		{Space: davNs, Local: "getlastmodified"}:  lastModified(user, event.Id).Format(http.TimeFormat),
+		{Space: davNs, Local: "getcontentlength"}: strconv.Itoa(len(data)),
+	}
+
+	if withData {
+		props[xml.Name{Space: calDavNs, Local: "calendar-data"}] = escapeXml(data)
+	}
+
+	return props
+}
+
+func lastModified(user *User, eventIdx int) time.Time {
+	revisions, err := user.EventStore.getHistory(eventIdx)
+	if err != nil || len(revisions) == 0 {
+		return time.Now().UTC()
+	}
+	return revisions[len(revisions)-1].Timestamp.UTC()
+}
+
+// Splits the available properties into found and missing ones according to the request
+func buildPropstats(available map[xml.Name]string, requested []xmlElem) []propstat {
+	found := propstat{Status: "HTTP/1.1 200 OK"}
+	missing := propstat{Status: "HTTP/1.1 404 Not Found"}
+
+	if requested == nil {
+		for name, value := range available {
+			found.Props = append(found.Props, rawProp{XMLName: name, Inner: value})
+		}
+	} else {
+		for _, elem := range requested {
+			if value, ok := available[elem.XMLName]; ok {
+				found.Props = append(found.Props, rawProp{XMLName: elem.XMLName, Inner: value})
+			} else {
+				missing.Props = append(missing.Props, rawProp{XMLName: elem.XMLName})
+			}
+		}
+	}
+
+	byName := func(a, b rawProp) int {
+		return strings.Compare(a.XMLName.Space+a.XMLName.Local, b.XMLName.Space+b.XMLName.Local)
+	}
+	slices.SortFunc(found.Props, byName)
+
+	var res []propstat
+	if len(found.Props) != 0 {
+		res = append(res, found)
+	}
+	if len(missing.Props) != 0 {
+		res = append(res, missing)
+	}
+	return res
+}
+
+func sendMultistatus(w http.ResponseWriter, responses []davResponse) {
+	body, err := xml.Marshal(multistatus{
+		XmlnsD:    davNs,
+		XmlnsC:    calDavNs,
+		XmlnsCS:   calServerNs,
+		Responses: responses,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
+
+func readXmlBody(r *http.Request, v interface{}) (bool, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return false, err
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return false, nil
+	}
+
+	return true, xml.Unmarshal(body, v)
+}
+
+func (h *CalDAVHandler) sortedEvents(user *User) ([]int, map[int]*Event) {
+	events := make(map[int]*Event)
+	user.EventStore.iterate(func(ev *Event) {
+		events[ev.Id] = ev
+	})
+
+	ids := make([]int, 0, len(events))
+	for id := range events {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	return ids, events
+}
+
+func (h *CalDAVHandler) handlePropfind(w http.ResponseWriter, r *http.Request, authIdx int, userIdx int, user *User, segments []string) {
+	req := propfindRequest{}
+	hasBody, err := readXmlBody(r, &req)
+	if err != nil {
+		http.Error(w, "Malformed PROPFIND body", http.StatusBadRequest)
+		return
+	}
+
+	var requested []xmlElem
+	if hasBody && req.Prop != nil {
+		requested = req.Prop.Names
+	}
+
+	depth := r.Header.Get("Depth")
+	var responses []davResponse
+
+	switch len(segments) {
+	case 0:
+		responses = append(responses, davResponse{Href: calDavPrefix, Propstats: buildPropstats(h.rootProps(), requested)})
+
+		// Only the calendar of the authenticated user is listed
+		if depth != "0" {
+			if authUser, err := h.userStore.get(authIdx); err == nil {
+				responses = append(responses, davResponse{
+					Href:      calendarHref(authIdx),
+					Propstats: buildPropstats(h.calendarProps(authIdx, authUser), requested),
+				})
+			}
+		}
+
+	case 1:
+		responses = append(responses, davResponse{
+			Href:      calendarHref(userIdx),
+			Propstats: buildPropstats(h.calendarProps(userIdx, user), requested),
+		})
+
+		if depth != "0" {
+			ids, events := h.sortedEvents(user)
+			for _, id := range ids {
+				responses = append(responses, davResponse{
+					Href:      calendarHref(userIdx) + calDavName(events[id]),
+					Propstats: buildPropstats(h.eventProps(userIdx, user, events[id], false), requested),
+				})
+			}
+		}
+
+	case 2:
+		event, ok := eventByName(user, segments[1])
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		responses = append(responses, davResponse{
+			Href:      calendarHref(userIdx) + calDavName(event),
+			Propstats: buildPropstats(h.eventProps(userIdx, user, event, false), requested),
+		})
+	}
+
+	sendMultistatus(w, responses)
+}
+
+// Checks the event against a VCALENDAR comp-filter, only VEVENT time ranges are considered
+func matchesFilter(filter *compFilter, event *Event) (bool, error) {
+	if filter == nil {
+		return true, nil
+	}
+
+	if filter.Name != "" && filter.Name != "VCALENDAR" && filter.Name != "VEVENT" {
+		return false, nil
+	}
+
+	if filter.TimeRange != nil {
+		if filter.TimeRange.Start != "" {
+			start, err := parseIcsTime(&icsProperty{Value: filter.TimeRange.Start})
+			if err != nil {
+				return false, err
+			}
+			if event.EventTime.Before(start) {
+				return false, nil
+			}
+		}
+
+		if filter.TimeRange.End != "" {
+			end, err := parseIcsTime(&icsProperty{Value: filter.TimeRange.End})
+			if err != nil {
+				return false, err
+			}
+			if !event.EventTime.Before(end) {
+				return false, nil
+			}
+		}
+	}
+
+	for idx := range filter.CompFilters {
+		ok, err := matchesFilter(&filter.CompFilters[idx], event)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (h *CalDAVHandler) handleReport(w http.ResponseWriter, r *http.Request, userIdx int, user *User) {
+	req := reportRequest{}
+	if _, err := readXmlBody(r, &req); err != nil {
+		http.Error(w, "Malformed REPORT body", http.StatusBadRequest)
+		return
+	}
+
+	var requested []xmlElem
+	if req.Prop != nil {
+		requested = req.Prop.Names
+	}
+
+	ids, events := h.sortedEvents(user)
+	var responses []davResponse
+
+	switch req.XMLName {
+	case xml.Name{Space: calDavNs, Local: "calendar-query"}:
+		for _, id := range ids {
+			ok, err := matchesFilter(req.Filter, events[id])
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if !ok {
+				continue
+			}
+
+			responses = append(responses, davResponse{
+				Href:      calendarHref(userIdx) + calDavName(events[id]),
+				Propstats: buildPropstats(h.eventProps(userIdx, user, events[id], true), requested),
+			})
+		}
+
+	case xml.Name{Space: calDavNs, Local: "calendar-multiget"}:
+		for _, href := range req.Hrefs {
+			href = strings.TrimSpace(href)
+			name := href[strings.LastIndex(href, "/")+1:]
+
+			event, ok := eventByName(user, name)
+			if !ok || !strings.HasPrefix(href, calendarHref(userIdx)) {
+				responses = append(responses, davResponse{Href: href, Status: "HTTP/1.1 404 Not Found"})
+				continue
+			}
+
+			responses = append(responses, davResponse{
+				Href:      href,
+				Propstats: buildPropstats(h.eventProps(userIdx, user, event, true), requested),
+			})
+		}
+
+	default:
+		http.Error(w, "Unsupported report", http.StatusForbidden)
+		return
+	}
+
+	sendMultistatus(w, responses)
+}
+
+func (h *CalDAVHandler) handleGet(w http.ResponseWriter, r *http.Request, userIdx int, user *User, name string) {
+	found, ok := eventByName(user, name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The event and its version are read together, so the etag matches the content
+	event, version, err := user.EventStore.getVersioned(found.Id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := eventToIcs(event, calDavUid(userIdx, event))
+
+	w.Header().Set("Content-Type", icsMimeType)
+	w.Header().Set("ETag", formatEtag(event.Id, version))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method != http.MethodHead {
+		io.WriteString(w, data)
+	}
+}
+
+func checkPreconditions(r *http.Request, exists bool, etag string) error {
+	if match := r.Header.Get("If-Match"); match != "" {
+		if !exists || (match != "*" && match != etag) {
+			return fmt.Errorf("If-Match: %w", errPrecondition)
+		}
+	}
+
+	if noneMatch := r.Header.Get("If-None-Match"); noneMatch != "" {
+		if exists && (noneMatch == "*" || noneMatch == etag) {
+			return fmt.Errorf("If-None-Match: %w", errPrecondition)
+		}
+	}
+
+	return nil
+}
+
+func sendStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errPrecondition), errors.Is(err, errResourceExists):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+	case errors.Is(err, ErrNoSuchObj):
+		http.NotFound(w, r)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Preconditions are checked under the store lock together with the change, so concurrent
+// clients with the same etag can't both succeed
+func (h *CalDAVHandler) handlePut(w http.ResponseWriter, r *http.Request, userIdx int, user *User, name string) {
+	if !strings.HasSuffix(name, ".ics") {
+		http.Error(w, "Calendar objects must have the .ics extension", http.StatusForbidden)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	event, uid, err := icsToEvent(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	event.Uid = uid
+
+	actor := getActor(r, userIdx)
+
+	if existing, ok := eventByName(user, name); ok {
+		version, err := user.EventStore.updateFunc(existing.Id, actor, func(old *Event, version int) (*Event, error) {
+			if err := checkPreconditions(r, true, formatEtag(old.Id, version)); err != nil {
+				return nil, err
+			}
+
+			event.keepCalDavIdentity(old)
+			return event, nil
+		})
+		if err != nil {
+			sendStoreError(w, r, err)
+			return
+		}
+
+		w.Header().Set("ETag", formatEtag(existing.Id, version))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if defaultNamePattern.MatchString(name) {
+		http.Error(w, "Names of the form N.ics are reserved for events of the other APIs", http.StatusForbidden)
+		return
+	}
+
+	if err := checkPreconditions(r, false, ""); err != nil {
+		sendStoreError(w, r, err)
+		return
+	}
+
+	event.CalDavName = name
+	eventIdx, err := user.EventStore.addIf(event, actor, func(events []*Event) error {
+		for _, ev := range events {
+			if ev.CalDavName == name {
+				return errResourceExists
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		sendStoreError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", formatEtag(eventIdx, 1))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *CalDAVHandler) handleDelete(w http.ResponseWriter, r *http.Request, userIdx int, user *User, name string) {
+	event, exists := eventByName(user, name)
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	err := user.EventStore.deleteIf(event.Id, getActor(r, userIdx), func(old *Event, version int) error {
+		return checkPreconditions(r, true, formatEtag(old.Id, version))
+	})
+	if err != nil {
+		sendStoreError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func issueCalDavToken(userIdx int, actor string, userStore *Store[User]) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = userStore.updateFunc(userIdx, actor, func(user *User, _ int) (*User, error) {
+		user.CalDavTokenHash = hashToken(token)
+		return user, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// POST /admin/caldav_token, the new password replaces the previous one of the user
+func HandleCalDavToken(w http.ResponseWriter, r *http.Request, userStore *Store[User]) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	userIdx, err := parseUserIdx(body)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	token, err := issueCalDavToken(userIdx, getActor(r, -1), userStore)
+	if errors.Is(err, ErrNoSuchObj) {
+		SendError(w, ErrNoSuchUser, 404)
+		return
+	}
+	if err != nil {
+		SendError(w, err, 500)
+		return
+	}
+
+	SendResult(w, token)
+}
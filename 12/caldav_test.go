@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testIcs = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//test//EN
+BEGIN:VEVENT
+UID:lunch-1@client
+DTSTART:20260310T120000Z
+DTEND:20260310T130000Z
+SUMMARY:%v
+END:VEVENT
+END:VCALENDAR
+`
+
+type calDavClient struct {
+	t      *testing.T
+	url    string
+	userId int
+	token  string
+}
+
+func (c *calDavClient) do(method string, path string, body string, headers map[string]string) (*http.Response, string) {
+	c.t.Helper()
+
+	req, err := http.NewRequest(method, c.url+path, strings.NewReader(body))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if c.token != "" {
+		req.SetBasicAuth(strconv.Itoa(c.userId), c.token)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+func (c *calDavClient) expect(method string, path string, body string, headers map[string]string, status int) (*http.Response, string) {
+	c.t.Helper()
+
+	resp, data := c.do(method, path, body, headers)
+	if resp.StatusCode != status {
+		c.t.Fatalf("%v %v = %v, want %v: %v", method, path, resp.StatusCode, status, data)
+	}
+	return resp, data
+}
+
+var ctagPattern = regexp.MustCompile(`<getctag xmlns="http://calendarserver.org/ns/">([^<]*)</getctag>`)
+
+func (c *calDavClient) ctag(path string) string {
+	c.t.Helper()
+
+	_, data := c.expect("PROPFIND", path, "", map[string]string{"Depth": "0"}, http.StatusMultiStatus)
+	match := ctagPattern.FindStringSubmatch(data)
+	if match == nil {
+		c.t.Fatalf("No ctag in %v", data)
+	}
+	return match[1]
+}
+
+func TestCalDAVHandler(t *testing.T) {
+	userStore := NewStore(func(u *User, id int) { u.Id = id })
+	userIdx := createUser("anna", "", "test", userStore)
+	otherIdx := createUser("boris", "", "test", userStore)
+
+	token, err := issueCalDavToken(userIdx, "test", userStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(NewCalDAVHandler(userStore))
+	defer server.Close()
+
+	calendar := calendarHref(userIdx)
+	resource := calendar + "lunch.ics"
+
+	anonymous := &calDavClient{t: t, url: server.URL, userId: userIdx}
+	anonymous.expect("PROPFIND", calendar, "", nil, http.StatusUnauthorized)
+
+	wrongToken := &calDavClient{t: t, url: server.URL, userId: userIdx, token: "wrong"}
+	wrongToken.expect("PROPFIND", calendar, "", nil, http.StatusUnauthorized)
+
+	c := &calDavClient{t: t, url: server.URL, userId: userIdx, token: token}
+	c.expect("PROPFIND", calendarHref(otherIdx), "", nil, http.StatusForbidden)
+	c.expect("PUT", calendarHref(otherIdx)+"lunch.ics", testIcs, nil, http.StatusForbidden)
+
+	_, data := c.expect("PROPFIND", calDavPrefix, "", map[string]string{"Depth": "1"}, http.StatusMultiStatus)
+	if !strings.Contains(data, calendar) || strings.Contains(data, calendarHref(otherIdx)) {
+		t.Errorf("PROPFIND of the root lists other calendars than the own one: %v", data)
+	}
+
+	ctag := c.ctag(calendar)
+
+	// Create
+	resp, _ := c.expect("PUT", resource, fmt.Sprintf(testIcs, "Lunch"), map[string]string{"If-None-Match": "*"}, http.StatusCreated)
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("PUT returned no ETag")
+	}
+	if newCtag := c.ctag(calendar); newCtag == ctag {
+		t.Errorf("ctag %v didn't change after PUT", ctag)
+	} else {
+		ctag = newCtag
+	}
+
+	c.expect("PUT", resource, fmt.Sprintf(testIcs, "Lunch"), map[string]string{"If-None-Match": "*"}, http.StatusPreconditionFailed)
+	c.expect("PUT", calendar+"7.ics", fmt.Sprintf(testIcs, "Lunch"), nil, http.StatusForbidden)
+
+	resp, data = c.expect("GET", resource, "", nil, http.StatusOK)
+	if resp.Header.Get("ETag") != etag {
+		t.Errorf("GET ETag = %v, want %v", resp.Header.Get("ETag"), etag)
+	}
+	if !strings.Contains(data, "UID:lunch-1@client") || !strings.Contains(data, "SUMMARY:Lunch") {
+		t.Errorf("GET lost the UID or the title: %v", data)
+	}
+
+	_, data = c.expect("PROPFIND", calendar, "", map[string]string{"Depth": "1"}, http.StatusMultiStatus)
+	if !strings.Contains(data, resource) || !strings.Contains(data, escapeXml(etag)) {
+		t.Errorf("PROPFIND doesn't list %v with %v: %v", resource, etag, data)
+	}
+
+	// calendar-query
+	query := `<?xml version="1.0" encoding="utf-8"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/><C:calendar-data/></D:prop>
+  <C:filter>
+    <C:comp-filter name="VCALENDAR">
+      <C:comp-filter name="VEVENT">
+        <C:time-range start="%v" end="%v"/>
+      </C:comp-filter>
+    </C:comp-filter>
+  </C:filter>
+</C:calendar-query>`
+	_, data = c.expect("REPORT", calendar, fmt.Sprintf(query, "20260310T000000Z", "20260311T000000Z"), map[string]string{"Depth": "1"}, http.StatusMultiStatus)
+	if !strings.Contains(data, resource) || !strings.Contains(data, "SUMMARY:Lunch") {
+		t.Errorf("calendar-query doesn't return the event: %v", data)
+	}
+
+	_, data = c.expect("REPORT", calendar, fmt.Sprintf(query, "20260401T000000Z", "20260402T000000Z"), map[string]string{"Depth": "1"}, http.StatusMultiStatus)
+	if strings.Contains(data, resource) {
+		t.Errorf("calendar-query returns an event out of the range: %v", data)
+	}
+
+	// Update
+	resp, _ = c.expect("PUT", resource, fmt.Sprintf(testIcs, "Long lunch"), map[string]string{"If-Match": etag}, http.StatusNoContent)
+	newEtag := resp.Header.Get("ETag")
+	if newEtag == "" || newEtag == etag {
+		t.Errorf("ETag after update = %v, was %v", newEtag, etag)
+	}
+	c.expect("PUT", resource, fmt.Sprintf(testIcs, "Stale"), map[string]string{"If-Match": etag}, http.StatusPreconditionFailed)
+	etag = newEtag
+
+	if newCtag := c.ctag(calendar); newCtag == ctag {
+		t.Errorf("ctag %v didn't change after update", ctag)
+	} else {
+		ctag = newCtag
+	}
+
+	// Changes through the other APIs keep the name and the UID and change the etag
+	user, _ := userStore.get(userIdx)
+	var eventIdx int
+	user.EventStore.iterate(func(e *Event) { eventIdx = e.Id })
+	event := &Event{Title: "Team lunch", EventTime: time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC), DurationMinutes: 60}
+	if err := updateEvent(userIdx, eventIdx, event, "test", userStore); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, data = c.expect("GET", resource, "", nil, http.StatusOK)
+	if resp.Header.Get("ETag") == etag {
+		t.Errorf("ETag %v didn't change after update_event", etag)
+	}
+	if !strings.Contains(data, "UID:lunch-1@client") || !strings.Contains(data, "SUMMARY:Team lunch") {
+		t.Errorf("update_event lost the UID or the change: %v", data)
+	}
+	etag = resp.Header.Get("ETag")
+
+	if newCtag := c.ctag(calendar); newCtag == ctag {
+		t.Errorf("ctag %v didn't change after update_event", ctag)
+	} else {
+		ctag = newCtag
+	}
+
+	// Delete
+	c.expect("DELETE", resource, "", map[string]string{"If-Match": `"0-0"`}, http.StatusPreconditionFailed)
+	c.expect("DELETE", resource, "", map[string]string{"If-Match": etag}, http.StatusNoContent)
+	c.expect("GET", resource, "", nil, http.StatusNotFound)
+	c.expect("DELETE", resource, "", nil, http.StatusNotFound)
+
+	if newCtag := c.ctag(calendar); newCtag == ctag {
+		t.Errorf("ctag %v didn't change after DELETE", ctag)
+	}
+}
+
+func TestCalDAVDefaultNames(t *testing.T) {
+	userStore := NewStore(func(u *User, id int) { u.Id = id })
+	userIdx := createUser("anna", "", "test", userStore)
+	token, err := issueCalDavToken(userIdx, "test", userStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := &Event{Title: "Standup", EventTime: time.Date(2026, 3, 10, 9, 30, 0, 0, time.UTC), DurationMinutes: 15}
+	eventIdx, err := createEvent(userIdx, event, "test", userStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(NewCalDAVHandler(userStore))
+	defer server.Close()
+
+	c := &calDavClient{t: t, url: server.URL, userId: userIdx, token: token}
+	resource := calendarHref(userIdx) + strconv.Itoa(eventIdx) + ".ics"
+
+	resp, data := c.expect("GET", resource, "", nil, http.StatusOK)
+	if !strings.Contains(data, "SUMMARY:Standup") {
+		t.Errorf("GET %v = %v", resource, data)
+	}
+
+	c.expect("PUT", resource, fmt.Sprintf(testIcs, "Late standup"), map[string]string{"If-Match": resp.Header.Get("ETag")}, http.StatusNoContent)
+
+	updated, err := userStore.get(userIdx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := updated.EventStore.get(eventIdx); got.Title != "Late standup" || got.CalDavName != "" {
+		t.Errorf("Event after PUT = %+v, want the title changed and no name", got)
+	}
+}
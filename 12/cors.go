@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type corsConfig struct {
+	// Exact origins like "https://app.example.com", "*" allows any origin
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// How long browsers may cache a preflight response, in seconds
+	MaxAge int
+}
+
+var defaultCorsMethods = []string{"GET", "HEAD", "POST", "PUT", "DELETE"}
+
+var defaultCorsHeaders = []string{"Content-Type", "Authorization", "If-Match", "If-None-Match"}
+
+// Any origin with credentials would let every site make requests with the user's cookies
+func (c *corsConfig) validate() error {
+	if c.AllowCredentials && slices.Contains(c.AllowedOrigins, "*") {
+		return errors.New("Cors: AllowedOrigins \"*\" can't be combined with AllowCredentials, list the origins instead")
+	}
+	return nil
+}
+
+func (c *corsConfig) originAllowed(origin string) bool {
+	return slices.Contains(c.AllowedOrigins, "*") || slices.Contains(c.AllowedOrigins, origin)
+}
+
+func (c *corsConfig) headersAllowed(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+
+		if !slices.ContainsFunc(c.AllowedHeaders, func(allowed string) bool {
+			return allowed == "*" || strings.EqualFold(allowed, header)
+		}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Wraps the whole mux, so preflight requests are answered before they reach
+// the validation of a route, which rejects methods the route doesn't declare
+func CorsMiddleware(cfg *corsConfig, mux *http.ServeMux) http.Handler {
+	if cfg == nil {
+		return mux
+	}
+
+	if len(cfg.AllowedMethods) == 0 {
+		cfg.AllowedMethods = defaultCorsMethods
+	}
+	if len(cfg.AllowedHeaders) == 0 {
+		cfg.AllowedHeaders = defaultCorsHeaders
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		// OPTIONS without the request method header is a plain request, e.g. of a CalDAV client
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		w.Header().Add("Vary", "Origin")
+		if !cfg.originAllowed(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		// validate rejects the wildcard together with credentials
+		if slices.Contains(cfg.AllowedOrigins, "*") {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+
+		if cfg.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if len(cfg.ExposedHeaders) != 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposedHeaders, ", "))
+			}
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+		if _, pattern := mux.Handler(r); pattern == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		method := r.Header.Get("Access-Control-Request-Method")
+		requestedHeaders := r.Header.Get("Access-Control-Request-Headers")
+		if !slices.Contains(cfg.AllowedMethods, method) || !cfg.headersAllowed(requestedHeaders) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(cfg.AllowedMethods, ", "))
+		if slices.Contains(cfg.AllowedHeaders, "*") {
+			w.Header().Set("Access-Control-Allow-Headers", requestedHeaders)
+		} else {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+		}
+
+		if cfg.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCorsConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     corsConfig
+		wantErr bool
+	}{
+		{name: "any origin", cfg: corsConfig{AllowedOrigins: []string{"*"}}},
+		{name: "listed origins with credentials", cfg: corsConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true}},
+		{name: "any origin with credentials", cfg: corsConfig{AllowedOrigins: []string{"https://app.example.com", "*"}, AllowCredentials: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.cfg.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%v: validate() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCorsMiddlewareOrigins(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name        string
+		cfg         *corsConfig
+		origin      string
+		allowOrigin string
+		credentials string
+	}{
+		{name: "wildcard", cfg: &corsConfig{AllowedOrigins: []string{"*"}}, origin: "https://evil.example", allowOrigin: "*"},
+		{
+			name:        "listed with credentials",
+			cfg:         &corsConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true},
+			origin:      "https://app.example.com",
+			allowOrigin: "https://app.example.com",
+			credentials: "true",
+		},
+		{
+			name:   "not listed",
+			cfg:    &corsConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true},
+			origin: "https://evil.example",
+		},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/events", nil)
+		req.Header.Set("Origin", tt.origin)
+		rec := httptest.NewRecorder()
+		CorsMiddleware(tt.cfg, mux).ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+			t.Errorf("%v: Access-Control-Allow-Origin = %q, want %q", tt.name, got, tt.allowOrigin)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != tt.credentials {
+			t.Errorf("%v: Access-Control-Allow-Credentials = %q, want %q", tt.name, got, tt.credentials)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const grpcPrefix = "/calendar.v1.Calendar/"
+
+// Status codes from https://grpc.github.io/grpc/core/md_doc_statuscodes.html
+const (
+	grpcOk                = 0
+	grpcInvalidArgument   = 3
+	grpcNotFound          = 5
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+)
+
+// Frames larger than that are rejected instead of being read into memory
+const grpcMaxMessageSize = 4 << 20
+
+type GrpcError struct {
+	Code    int
+	Message string
+}
+
+func (e *GrpcError) Error() string {
+	return e.Message
+}
+
+func grpcStatus(code int, err error) error {
+	return &GrpcError{Code: code, Message: err.Error()}
+}
+
+// Errors of the business logic are mapped onto status codes
+func toGrpcError(err error) *GrpcError {
+	var grpcErr *GrpcError
+	if errors.As(err, &grpcErr) {
+		return grpcErr
+	}
+
+	if errors.Is(err, ErrNoSuchObj) || errors.Is(err, ErrNoSuchUser) {
+		return &GrpcError{Code: grpcNotFound, Message: err.Error()}
+	}
+
+	return &GrpcError{Code: grpcInternal, Message: err.Error()}
+}
+
+type grpcUnary func(req *protoRequest) ([]byte, error)
+
+type grpcStream func(r *http.Request, req *protoRequest, send func([]byte) error) error
+
+type grpcMethod struct {
+	fields map[int]string
+	unary  grpcUnary
+	stream grpcStream
+}
+
+// Implements calendar.proto on top of the same business logic as the HTTP handlers
+type GrpcServer struct {
+	userStore *Store[User]
+	methods   map[string]*grpcMethod
+}
+
+func NewGrpcServer(userStore *Store[User]) *GrpcServer {
+	s := &GrpcServer{userStore: userStore}
+
+	idFields := map[int]string{1: "user_id", 2: "event_id"}
+	eventFields := map[int]string{1: "user_id", 2: "event"}
+	rangeFields := map[int]string{1: "user_id", 2: "date", 3: "tags"}
+
+	s.methods = map[string]*grpcMethod{
+		"CreateUser":     {fields: map[int]string{1: "username"}, unary: s.createUser},
+		"CreateEvent":    {fields: eventFields, unary: s.createEvent},
+		"UpdateEvent":    {fields: eventFields, unary: s.updateEvent},
+		"DeleteEvent":    {fields: idFields, unary: s.deleteEvent},
+		"GetEvent":       {fields: idFields, unary: s.getEvent},
+		"EventsForDay":   {fields: rangeFields, unary: s.eventsInRange(eventsForDay)},
+		"EventsForWeek":  {fields: rangeFields, unary: s.eventsInRange(eventsForWeek)},
+		"EventsForMonth": {fields: rangeFields, unary: s.eventsInRange(eventsForMonth)},
+		"WatchEvents":    {fields: map[int]string{1: "user_id"}, stream: s.watchEvents},
+	}
+
+	return s
+}
+
+func (s *GrpcServer) createUser(req *protoRequest) ([]byte, error) {
+	if req.Username == "" {
+		return nil, grpcStatus(grpcInvalidArgument, errors.New("Missing username"))
+	}
+
+	e := protoEncoder{}
+	e.int64(1, int64(createUser(req.Username, "", "grpc", s.userStore)))
+	return e.buf, nil
+}
+
+func (s *GrpcServer) checkEvent(req *protoRequest, needId bool) error {
+	if req.Event == nil {
+		return grpcStatus(grpcInvalidArgument, errors.New("Missing event"))
+	}
+
+	if !needId {
+		req.Event.Id = -1
+	}
+
+	if err := req.Event.validate(needId); err != nil {
+		return grpcStatus(grpcInvalidArgument, err)
+	}
+
+	return nil
+}
+
+func (s *GrpcServer) createEvent(req *protoRequest) ([]byte, error) {
+	if err := s.checkEvent(req, false); err != nil {
+		return nil, err
+	}
+
+	idx, err := createEvent(req.UserId, req.Event, grpcActor(req.UserId), s.userStore)
+	if err != nil {
+		return nil, err
+	}
+
+	e := protoEncoder{}
+	e.int64(1, int64(idx))
+	return e.buf, nil
+}
+
+func (s *GrpcServer) updateEvent(req *protoRequest) ([]byte, error) {
+	if err := s.checkEvent(req, true); err != nil {
+		return nil, err
+	}
+
+	return nil, updateEvent(req.UserId, req.Event.Id, req.Event, grpcActor(req.UserId), s.userStore)
+}
+
+func (s *GrpcServer) deleteEvent(req *protoRequest) ([]byte, error) {
+	return nil, deleteEvent(req.UserId, req.EventId, grpcActor(req.UserId), s.userStore)
+}
+
+func (s *GrpcServer) getEvent(req *protoRequest) ([]byte, error) {
+	user, err := s.userStore.get(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := user.EventStore.get(req.EventId)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeEvent(event), nil
+}
+
+func (s *GrpcServer) eventsInRange(query func(int, time.Time, *Store[User]) ([]*Event, error)) grpcUnary {
+	return func(req *protoRequest) ([]byte, error) {
+		date, err := time.Parse(time.DateOnly, req.Date)
+		if err != nil {
+			return nil, grpcStatus(grpcInvalidArgument, fmt.Errorf("Invalid date: %v", req.Date))
+		}
+
+		events, err := query(req.UserId, date, s.userStore)
+		if err != nil {
+			return nil, err
+		}
+
+		// Same order as the default of the HTTP list endpoints
+		events, _, err = eventOrder.paginate(filterByTags(events, req.Tags), &pageQuery{})
+		if err != nil {
+			return nil, err
+		}
+
+		return encodeEventList(events), nil
+	}
+}
+
+func (s *GrpcServer) watchEvents(r *http.Request, req *protoRequest, send func([]byte) error) error {
+	user, err := s.userStore.get(req.UserId)
+	if err != nil {
+		return err
+	}
+
+	changes, stop := user.EventStore.watch()
+	defer stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case change, ok := <-changes:
+			if !ok {
+				return grpcStatus(grpcResourceExhausted, errors.New("Watcher fell behind"))
+			}
+
+			if err := send(encodeEventChange(change)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func grpcActor(userIdx int) string {
+	return fmt.Sprintf("grpc:user:%v", userIdx)
+}
+
+func readGrpcFrame(body io.Reader) ([]byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(body, header); err != nil {
+		return nil, grpcStatus(grpcInvalidArgument, errors.New("Missing request message"))
+	}
+
+	if header[0] != 0 {
+		return nil, grpcStatus(grpcUnimplemented, errors.New("Compressed messages are not supported"))
+	}
+
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > grpcMaxMessageSize {
+		return nil, grpcStatus(grpcResourceExhausted, errors.New("Request message is too large"))
+	}
+
+	message := make([]byte, size)
+	if _, err := io.ReadFull(body, message); err != nil {
+		return nil, grpcStatus(grpcInvalidArgument, errors.New("Truncated request message"))
+	}
+
+	return message, nil
+}
+
+func writeGrpcFrame(w http.ResponseWriter, message []byte) error {
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+
+	if _, err := w.Write(append(frame, message...)); err != nil {
+		return err
+	}
+
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	return nil
+}
+
+// grpc-message is percent encoded as the protocol requires
+func encodeGrpcMessage(msg string) string {
+	var builder strings.Builder
+	for _, b := range []byte(msg) {
+		if b < 0x20 || b > 0x7e || b == '%' {
+			fmt.Fprintf(&builder, "%%%02X", b)
+			continue
+		}
+		builder.WriteByte(b)
+	}
+	return builder.String()
+}
+
+func (s *GrpcServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ProtoMajor != 2 {
+		http.Error(w, "gRPC requires POST over HTTP/2", http.StatusHTTPVersionNotSupported)
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/grpc" && contentType != "application/grpc+proto" {
+		http.Error(w, "Unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+
+	err := s.serve(w, r)
+
+	status := grpcOk
+	message := ""
+	if err != nil {
+		grpcErr := toGrpcError(err)
+		status = grpcErr.Code
+		message = grpcErr.Message
+	}
+
+	w.Header().Set("Grpc-Status", strconv.Itoa(status))
+	if message != "" {
+		w.Header().Set("Grpc-Message", encodeGrpcMessage(message))
+	}
+}
+
+func (s *GrpcServer) serve(w http.ResponseWriter, r *http.Request) error {
+	method, ok := s.methods[strings.TrimPrefix(r.URL.Path, grpcPrefix)]
+	if !ok {
+		return grpcStatus(grpcUnimplemented, fmt.Errorf("Unknown method %v", r.URL.Path))
+	}
+
+	message, err := readGrpcFrame(r.Body)
+	if err != nil {
+		return err
+	}
+
+	req, err := decodeRequest(message, method.fields)
+	if err != nil {
+		return grpcStatus(grpcInvalidArgument, err)
+	}
+
+	if method.stream != nil {
+		return method.stream(r, req, func(msg []byte) error {
+			return writeGrpcFrame(w, msg)
+		})
+	}
+
+	resp, err := method.unary(req)
+	if err != nil {
+		return err
+	}
+
+	return writeGrpcFrame(w, resp)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type grpcResponse struct {
+	frames  [][]byte
+	status  string
+	message string
+}
+
+func readGrpcResponseFrame(body io.Reader) ([]byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(body, header); err != nil {
+		return nil, err
+	}
+
+	message := make([]byte, binary.BigEndian.Uint32(header[1:]))
+	if _, err := io.ReadFull(body, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func grpcFrame(flags byte, message []byte) []byte {
+	frame := make([]byte, 5, 5+len(message))
+	frame[0] = flags
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+func startGrpc(ctx context.Context, server *httptest.Server, method string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+grpcPrefix+method, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%v: status %v over HTTP/%v", method, resp.StatusCode, resp.ProtoMajor)
+	}
+	return resp, nil
+}
+
+func callGrpc(t *testing.T, server *httptest.Server, method string, body []byte) *grpcResponse {
+	t.Helper()
+
+	resp, err := startGrpc(context.Background(), server, method, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	res := &grpcResponse{}
+	for {
+		frame, err := readGrpcResponseFrame(resp.Body)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.frames = append(res.frames, frame)
+	}
+
+	res.status = resp.Trailer.Get("Grpc-Status")
+	res.message = resp.Trailer.Get("Grpc-Message")
+	return res
+}
+
+func newGrpcTestServer(t *testing.T) (*httptest.Server, *Store[User]) {
+	userStore := NewStore(func(u *User, id int) { u.Id = id })
+
+	server := httptest.NewUnstartedServer(NewGrpcServer(userStore))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server, userStore
+}
+
+func TestGrpcUnary(t *testing.T) {
+	server, userStore := newGrpcTestServer(t)
+
+	username := protoEncoder{}
+	username.string(1, "anna")
+	resp := callGrpc(t, server, "CreateUser", grpcFrame(0, username.buf))
+	if resp.status != "0" || len(resp.frames) != 1 {
+		t.Fatalf("CreateUser = %+v", resp)
+	}
+	if _, err := userStore.get(0); err != nil {
+		t.Fatal(err)
+	}
+
+	event := &Event{Title: "Standup", EventTime: protoTime("2026-03-10T09:30:00Z"), DurationMinutes: 15}
+	create := protoEncoder{}
+	create.message(2, encodeEvent(event))
+	resp = callGrpc(t, server, "CreateEvent", grpcFrame(0, create.buf))
+	if resp.status != "0" || len(resp.frames) != 1 {
+		t.Fatalf("CreateEvent = %+v", resp)
+	}
+
+	get := protoEncoder{}
+	resp = callGrpc(t, server, "GetEvent", grpcFrame(0, get.buf))
+	if resp.status != "0" || len(resp.frames) != 1 {
+		t.Fatalf("GetEvent = %+v", resp)
+	}
+	got, err := decodeEvent(resp.frames[0])
+	if err != nil || got.Title != event.Title || !got.EventTime.Equal(event.EventTime) {
+		t.Errorf("GetEvent = %+v, %v, want %+v", got, err, event)
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		body    []byte
+		status  string
+		message string
+	}{
+		{name: "missing event", method: "GetEvent", body: grpcFrame(0, []byte{0x10, 0x07}), status: "5", message: "No such obj"},
+		{name: "unknown method", method: "Unknown", body: grpcFrame(0, nil), status: "12"},
+		{name: "compressed", method: "GetEvent", body: grpcFrame(1, nil), status: "12"},
+		{name: "truncated frame", method: "GetEvent", body: grpcFrame(0, []byte{0x10})[:4], status: "3"},
+		{name: "malformed message", method: "GetEvent", body: grpcFrame(0, []byte{0x10}), status: "3"},
+		{name: "invalid date", method: "EventsForDay", body: grpcFrame(0, []byte{0x12, 0x01, 'x'}), status: "3"},
+		{name: "invalid event", method: "CreateEvent", body: grpcFrame(0, []byte{0x12, 0x00}), status: "3"},
+	}
+
+	for _, tt := range tests {
+		resp := callGrpc(t, server, tt.method, tt.body)
+		if resp.status != tt.status || len(resp.frames) != 0 {
+			t.Errorf("%v: status %v with %v frames, want %v", tt.name, resp.status, len(resp.frames), tt.status)
+		}
+		if tt.message != "" && resp.message != tt.message {
+			t.Errorf("%v: message %q, want %q", tt.name, resp.message, tt.message)
+		}
+	}
+}
+
+type protoChange struct {
+	EventId   int64
+	Version   int64
+	Operation int64
+	Before    *Event
+	After     *Event
+}
+
+func decodeChange(t *testing.T, data []byte) *protoChange {
+	t.Helper()
+
+	change := &protoChange{}
+	err := decodeProto(data, func(f *protoField) error {
+		var err error
+		switch f.Number {
+		case 1:
+			change.EventId, err = f.int64()
+		case 2:
+			change.Version, err = f.int64()
+		case 5:
+			change.Operation, err = f.int64()
+		case 6:
+			change.Before, err = decodeEvent(f.Bytes)
+		case 7:
+			change.After, err = decodeEvent(f.Bytes)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return change
+}
+
+func TestGrpcWatchEvents(t *testing.T) {
+	server, userStore := newGrpcTestServer(t)
+	userIdx := createUser("anna", "", "test", userStore)
+	user, _ := userStore.get(userIdx)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watch := protoEncoder{}
+	watch.int64(1, int64(userIdx))
+	type started struct {
+		resp *http.Response
+		err  error
+	}
+	startedCh := make(chan started, 1)
+	go func() {
+		resp, err := startGrpc(ctx, server, "WatchEvents", grpcFrame(0, watch.buf))
+		startedCh <- started{resp: resp, err: err}
+	}()
+
+	// Changes made before the watcher is registered are not streamed
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		user.EventStore.mutex.RLock()
+		watching := len(user.EventStore.watchers) > 0
+		user.EventStore.mutex.RUnlock()
+
+		if watching {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("WatchEvents didn't start watching")
+		}
+	}
+
+	event := &Event{Id: -1, Title: "Standup", EventTime: protoTime("2026-03-10T09:30:00Z")}
+	eventIdx, err := createEvent(userIdx, event, "test", userStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved := &Event{Title: "Standup", EventTime: protoTime("2026-03-10T09:45:00Z")}
+	if err := updateEvent(userIdx, eventIdx, moved, "test", userStore); err != nil {
+		t.Fatal(err)
+	}
+	if err := deleteEvent(userIdx, eventIdx, "test", userStore); err != nil {
+		t.Fatal(err)
+	}
+
+	start := <-startedCh
+	if start.err != nil {
+		t.Fatal(start.err)
+	}
+	resp := start.resp
+	defer resp.Body.Close()
+
+	want := []struct {
+		operation int64
+		before    *time.Time
+		after     *time.Time
+	}{
+		{operation: protoOperations[OpCreate], after: &event.EventTime},
+		{operation: protoOperations[OpUpdate], before: &event.EventTime, after: &moved.EventTime},
+		{operation: protoOperations[OpDelete], before: &moved.EventTime},
+	}
+
+	for idx, w := range want {
+		frame, err := readGrpcResponseFrame(resp.Body)
+		if err != nil {
+			t.Fatalf("Change %v: %v", idx, err)
+		}
+
+		change := decodeChange(t, frame)
+		if change.EventId != int64(eventIdx) || change.Version != int64(idx+1) || change.Operation != w.operation {
+			t.Errorf("Change %v = %+v, want version %v and operation %v", idx, change, idx+1, w.operation)
+		}
+		if (change.Before == nil) != (w.before == nil) || (w.before != nil && !change.Before.EventTime.Equal(*w.before)) {
+			t.Errorf("Change %v before = %+v, want time %v", idx, change.Before, w.before)
+		}
+		if (change.After == nil) != (w.after == nil) || (w.after != nil && !change.After.EventTime.Equal(*w.after)) {
+			t.Errorf("Change %v after = %+v, want time %v", idx, change.After, w.after)
+		}
+	}
+
+	cancel()
+	if _, err := readGrpcResponseFrame(resp.Body); err == nil {
+		t.Error("WatchEvents streamed more changes than were made")
+	}
+}
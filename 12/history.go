@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	OpCreate  = "create"
+	OpUpdate  = "update"
+	OpDelete  = "delete"
+	OpRestore = "restore"
+)
+
+type Revision[T interface{}] struct {
+	Version   int       `json:"version"`
+	Actor     string    `json:"actor"`
+	Timestamp time.Time `json:"timestamp"`
+	Operation string    `json:"operation"`
+	Before    *T        `json:"before"`
+	After     *T        `json:"after"`
+}
+
+type Change[T interface{}] struct {
+	Id       int
+	Revision *Revision[T]
+}
+
+// Number of changes a watcher may lag behind before it is dropped
+const watcherBuffer = 64
+
+// Objects holding slices copy them too, so that revisions share nothing with the stored object
+type cloner[T interface{}] interface {
+	clone() *T
+}
+
+func snapshot[T interface{}](obj *T) *T {
+	if obj == nil {
+		return nil
+	}
+
+	if c, ok := any(obj).(cloner[T]); ok {
+		return c.clone()
+	}
+
+	copied := *obj
+	return &copied
+}
+
+// Must be called with the store mutex held for writing
+func (s *Store[T]) record(id int, actor string, operation string, before *T, after *T) {
+	revision := &Revision[T]{
+		Version:   len(s.history[id]) + 1,
+		Actor:     actor,
+		Timestamp: time.Now(),
+		Operation: operation,
+		Before:    snapshot(before),
+		After:     snapshot(after),
+	}
+	s.history[id] = append(s.history[id], revision)
+
+	for watcherId, watcher := range s.watchers {
+		select {
+		case watcher <- &Change[T]{Id: id, Revision: revision}:
+		default:
+			close(watcher)
+			delete(s.watchers, watcherId)
+		}
+	}
+}
+
+// Returns a channel receiving every following change of the store and a
+// function to stop watching. A watcher that falls behind has its channel closed
+func (s *Store[T]) watch() (<-chan *Change[T], func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	watcherId := s.nextWatcher
+	s.nextWatcher++
+
+	watcher := make(chan *Change[T], watcherBuffer)
+	s.watchers[watcherId] = watcher
+
+	return watcher, func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		if _, ok := s.watchers[watcherId]; ok {
+			close(watcher)
+			delete(s.watchers, watcherId)
+		}
+	}
+}
+
+func (s *Store[T]) getHistory(id int) ([]*Revision[T], error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	revisions, ok := s.history[id]
+	if !ok {
+		return nil, ErrNoSuchObj
+	}
+
+	res := make([]*Revision[T], len(revisions))
+	copy(res, revisions)
+	return res, nil
+}
+
+func (s *Store[T]) restore(id int, version int, actor string) (*T, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	revisions, ok := s.history[id]
+	if !ok {
+		return nil, ErrNoSuchObj
+	}
+
+	if version < 1 || version > len(revisions) {
+		return nil, fmt.Errorf("No such version: %v", version)
+	}
+
+	target := revisions[version-1].After
+	if target == nil {
+		return nil, fmt.Errorf("Object was deleted in version %v", version)
+	}
+
+	restored := snapshot(target)
+	if s.setId != nil {
+		s.setId(restored, id)
+	}
+
+	before := s.objMap[id]
+	s.objMap[id] = restored
+	s.record(id, actor, OpRestore, before, restored)
+
+	return restored, nil
+}
+
+// GET /events/{id}/history
+func eventHistory(userIdx int, eventIdx int, userStore *Store[User]) ([]*Revision[Event], error) {
+	user, err := userStore.get(userIdx)
+	if err != nil {
+		return nil, err
+	}
+
+	return user.EventStore.getHistory(eventIdx)
+}
+
+// POST /events/{id}/restore
+func restoreEvent(userIdx int, eventIdx int, version int, actor string, userStore *Store[User]) (*Event, error) {
+	user, err := userStore.get(userIdx)
+	if err != nil {
+		return nil, err
+	}
+
+	return user.EventStore.restore(eventIdx, version, actor)
+}
+
+type actorKey struct{}
+
+// Request authenticated as the actor, for the middlewares checking credentials
+func withActor(r *http.Request, actor string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), actorKey{}, actor))
+}
+
+// Changes are recorded under the actor the request is authenticated as, otherwise under the
+// user it acts for. Nothing the client sends names the actor, so the history can't be forged
+func getActor(r *http.Request, userIdx int) string {
+	if actor, ok := r.Context().Value(actorKey{}).(string); ok {
+		return actor
+	}
+
+	if userIdx == -1 {
+		return "anonymous"
+	}
+
+	return fmt.Sprintf("user:%v", userIdx)
+}
+
+func parseVersion(body []byte) (int, error) {
+	req := struct {
+		Version int `json:"version"`
+	}{Version: -1}
+
+	err := json.Unmarshal(body, &req)
+	if err != nil {
+		return -1, err
+	}
+	if req.Version == -1 {
+		return -1, errors.New("Missing version")
+	}
+
+	return req.Version, nil
+}
+
+func HandleEventHistory(w http.ResponseWriter, r *http.Request, userStore *Store[User]) {
+	userIdx, err := parseUserIdxQuery(r)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	eventIdx, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	revisions, err := eventHistory(userIdx, eventIdx, userStore)
+	if err != nil {
+		SendError(w, err, 404)
+		return
+	}
+
+	SendPage(w, r, revisions, revisionOrder)
+}
+
+func HandleRestoreEvent(w http.ResponseWriter, r *http.Request, userStore *Store[User]) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	userIdx, err := parseUserIdx(body)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	version, err := parseVersion(body)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	eventIdx, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	event, err := restoreEvent(userIdx, eventIdx, version, getActor(r, userIdx), userStore)
+	if err != nil {
+		SendError(w, err, 500)
+		return
+	}
+
+	SendResult(w, event)
+}
+
+// Number of revisions recorded for the object, 0 if it was never stored
+func (s *Store[T]) version(id int) int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return len(s.history[id])
+}
+
+// Total number of revisions in the store, grows with every mutation
+func (s *Store[T]) revisionCount() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	count := 0
+	for _, revisions := range s.history {
+		count += len(revisions)
+	}
+
+	return count
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newHistoryTestStore(t *testing.T) (*Store[User], int, int) {
+	t.Helper()
+
+	userStore := NewStore(func(u *User, id int) { u.Id = id })
+	userIdx := createUser("anna", "", "test", userStore)
+
+	at := time.Date(2026, 3, 10, 9, 30, 0, 0, time.UTC)
+	eventIdx, err := createEvent(userIdx, &Event{
+		Title:       "Standup",
+		EventTime:   at,
+		Tags:        []string{"team"},
+		Attachments: []*Attachment{{Name: "notes.txt", MimeType: "text/plain", Size: 5, BlobId: "abc"}},
+	}, "user:0", userStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := updateEvent(userIdx, eventIdx, &Event{Title: "Late standup", EventTime: at.Add(time.Hour)}, "user:0", userStore); err != nil {
+		t.Fatal(err)
+	}
+
+	return userStore, userIdx, eventIdx
+}
+
+func TestEventHistory(t *testing.T) {
+	userStore, userIdx, eventIdx := newHistoryTestStore(t)
+	if err := deleteEvent(userIdx, eventIdx, "user:0", userStore); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := eventHistory(userIdx, eventIdx, userStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var operations []string
+	for idx, revision := range history {
+		if revision.Version != idx+1 {
+			t.Errorf("Revision %v has version %v", idx, revision.Version)
+		}
+		operations = append(operations, revision.Operation)
+	}
+	if want := []string{OpCreate, OpUpdate, OpDelete}; !slices.Equal(operations, want) {
+		t.Fatalf("Operations = %v, want %v", operations, want)
+	}
+
+	if history[0].Before != nil || history[0].After.Title != "Standup" {
+		t.Errorf("Create = %+v, %+v", history[0].Before, history[0].After)
+	}
+	if history[1].Before.Title != "Standup" || history[1].After.Title != "Late standup" {
+		t.Errorf("Update = %+v, %+v", history[1].Before, history[1].After)
+	}
+	if history[2].Before.Title != "Late standup" || history[2].After != nil {
+		t.Errorf("Delete = %+v, %+v", history[2].Before, history[2].After)
+	}
+
+	if _, err := eventHistory(userIdx, 42, userStore); err == nil {
+		t.Error("History of an unknown event succeeds")
+	}
+}
+
+func TestHistorySnapshotsAreCopies(t *testing.T) {
+	userStore, userIdx, eventIdx := newHistoryTestStore(t)
+	user, _ := userStore.get(userIdx)
+
+	history, _ := eventHistory(userIdx, eventIdx, userStore)
+	created := history[0].After
+
+	// Changes of a restored event don't reach the revision it was restored from
+	restored, err := restoreEvent(userIdx, eventIdx, 1, "user:0", userStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored.Tags[0] = "changed"
+	restored.Attachments[0].Name = "changed.txt"
+
+	if created.Tags[0] != "team" || created.Attachments[0].Name != "notes.txt" {
+		t.Errorf("Revision 1 changed with the stored event: %+v, %+v", created.Tags, created.Attachments[0])
+	}
+
+	history, _ = user.EventStore.getHistory(eventIdx)
+	if after := history[2].After; after.Tags[0] != "team" || after.Attachments[0].Name != "notes.txt" {
+		t.Errorf("Restore revision changed with the stored event: %+v, %+v", after.Tags, after.Attachments[0])
+	}
+}
+
+func TestRestoreEvent(t *testing.T) {
+	userStore, userIdx, eventIdx := newHistoryTestStore(t)
+	user, _ := userStore.get(userIdx)
+
+	event, err := restoreEvent(userIdx, eventIdx, 1, "user:0", userStore)
+	if err != nil || event.Title != "Standup" || event.Id != eventIdx {
+		t.Fatalf("Restore of version 1 = %+v, %v", event, err)
+	}
+	if stored, _ := user.EventStore.get(eventIdx); stored.Title != "Standup" || !slices.Equal(stored.Tags, []string{"team"}) {
+		t.Errorf("Stored event after the restore = %+v", stored)
+	}
+
+	history, _ := user.EventStore.getHistory(eventIdx)
+	last := history[len(history)-1]
+	if last.Operation != OpRestore || last.Version != 3 || last.Before.Title != "Late standup" || last.After.Title != "Standup" {
+		t.Errorf("Restore revision = %+v", last)
+	}
+
+	if _, err := restoreEvent(userIdx, eventIdx, 0, "user:0", userStore); err == nil {
+		t.Error("Restore of version 0 succeeds")
+	}
+	if _, err := restoreEvent(userIdx, eventIdx, 9, "user:0", userStore); err == nil {
+		t.Error("Restore of a missing version succeeds")
+	}
+	if _, err := restoreEvent(userIdx, 42, 1, "user:0", userStore); err == nil {
+		t.Error("Restore of an unknown event succeeds")
+	}
+}
+
+func TestRestoreDeletedEvent(t *testing.T) {
+	userStore, userIdx, eventIdx := newHistoryTestStore(t)
+	user, _ := userStore.get(userIdx)
+
+	if err := deleteEvent(userIdx, eventIdx, "user:0", userStore); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := restoreEvent(userIdx, eventIdx, 3, "user:0", userStore); err == nil {
+		t.Error("Restore of the deletion succeeds")
+	}
+
+	event, err := restoreEvent(userIdx, eventIdx, 2, "user:0", userStore)
+	if err != nil || event.Title != "Late standup" {
+		t.Fatalf("Restore of version 2 = %+v, %v", event, err)
+	}
+	if stored, err := user.EventStore.get(eventIdx); err != nil || stored.Title != "Late standup" || stored.Id != eventIdx {
+		t.Errorf("Deleted event isn't back: %+v, %v", stored, err)
+	}
+
+	history, _ := user.EventStore.getHistory(eventIdx)
+	if last := history[len(history)-1]; last.Operation != OpRestore || last.Before != nil {
+		t.Errorf("Restore revision of a deleted event = %+v", last)
+	}
+}
+
+func TestRestoreEventActor(t *testing.T) {
+	userStore, userIdx, eventIdx := newHistoryTestStore(t)
+
+	r := httptest.NewRequest(http.MethodPost, "/events/"+strconv.Itoa(eventIdx)+"/restore", strings.NewReader(`{"user_id": 0, "version": 1}`))
+	r.SetPathValue("id", strconv.Itoa(eventIdx))
+	r.Header.Set("X-Actor", "mallory")
+	w := httptest.NewRecorder()
+	HandleRestoreEvent(w, r, userStore)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %v: %v", w.Code, w.Body)
+	}
+
+	var report struct {
+		Result *Event `json:"result"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil || report.Result.Title != "Standup" {
+		t.Errorf("Restored event = %v, %v", w.Body, err)
+	}
+
+	history, _ := eventHistory(userIdx, eventIdx, userStore)
+	if actor := history[len(history)-1].Actor; actor != "user:0" {
+		t.Errorf("Restore is recorded for %q, want the user of the request", actor)
+	}
+}
+
+func TestGetActor(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/create_event", nil)
+	r.Header.Set("X-Actor", "mallory")
+
+	if actor := getActor(r, 3); actor != "user:3" {
+		t.Errorf("Actor = %q, want user:3", actor)
+	}
+	if actor := getActor(r, -1); actor != "anonymous" {
+		t.Errorf("Actor without a user = %q, want anonymous", actor)
+	}
+	if actor := getActor(withActor(r, "admin"), 3); actor != "admin" {
+		t.Errorf("Actor of an authenticated request = %q, want admin", actor)
+	}
+
+	var actor string
+	handler := AdminMiddleware("secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor = getActor(r, -1)
+	}))
+	r.Header.Set("Authorization", "Bearer secret")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if actor != "admin" {
+		t.Errorf("Actor of an admin request = %q, want admin", actor)
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const icsDateTimeLayout = "20060102T150405"
+const icsDateLayout = "20060102"
+
+type icsProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+type icsComponent struct {
+	Name       string
+	Properties []*icsProperty
+	Children   []*icsComponent
+}
+
+func (c *icsComponent) get(name string) *icsProperty {
+	for _, prop := range c.Properties {
+		if prop.Name == name {
+			return prop
+		}
+	}
+	return nil
+}
+
+func (c *icsComponent) find(name string) []*icsComponent {
+	var res []*icsComponent
+	for _, child := range c.Children {
+		if child.Name == name {
+			res = append(res, child)
+		}
+		res = append(res, child.find(name)...)
+	}
+	return res
+}
+
+func escapeIcsText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+func unescapeIcsText(s string) string {
+	return strings.NewReplacer(
+		`\\`, `\`,
+		`\;`, ";",
+		`\,`, ",",
+		`\n`, "\n",
+		`\N`, "\n",
+	).Replace(s)
+}
+
+// Splits a comma separated list of text values, escaped commas are kept
+func splitIcsList(value string) []string {
+	var res []string
+
+	start := 0
+	for idx := 0; idx < len(value); idx++ {
+		switch value[idx] {
+		case '\\':
+			idx++
+		case ',':
+			res = append(res, unescapeIcsText(value[start:idx]))
+			start = idx + 1
+		}
+	}
+
+	return append(res, unescapeIcsText(value[start:]))
+}
+
+// Lines longer than 75 octets are folded as required by RFC 5545
+func foldIcsLine(line string) string {
+	const limit = 75
+
+	var builder strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > limit {
+			builder.WriteString("\r\n ")
+			width = 1
+		}
+		builder.WriteRune(r)
+		width += size
+	}
+	builder.WriteString("\r\n")
+
+	return builder.String()
+}
+
+func formatIcsTime(t time.Time) string {
+	return t.UTC().Format(icsDateTimeLayout) + "Z"
+}
+
+func parseIcsTime(prop *icsProperty) (time.Time, error) {
+	value := prop.Value
+
+	if prop.Params["VALUE"] == "DATE" || len(value) == len(icsDateLayout) {
+		return time.Parse(icsDateLayout, value)
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(icsDateTimeLayout, strings.TrimSuffix(value, "Z"))
+	}
+
+	loc := time.UTC
+	if tzid, ok := prop.Params["TZID"]; ok {
+		var err error
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return time.Time{}, fmt.Errorf("Unknown time zone: %v", tzid)
+		}
+	}
+
+	return time.ParseInLocation(icsDateTimeLayout, value, loc)
+}
+
+func parseIcsLine(line string) (*icsProperty, error) {
+	inQuotes := false
+	colon := -1
+	for idx, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		}
+		if r == ':' && !inQuotes {
+			colon = idx
+			break
+		}
+	}
+
+	if colon == -1 {
+		return nil, fmt.Errorf("Malformed line: %v", line)
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	prop := &icsProperty{
+		Name:   strings.ToUpper(parts[0]),
+		Params: make(map[string]string),
+		Value:  line[colon+1:],
+	}
+
+	for _, param := range parts[1:] {
+		name, value, found := strings.Cut(param, "=")
+		if !found {
+			continue
+		}
+		prop.Params[strings.ToUpper(name)] = strings.Trim(value, `"`)
+	}
+
+	return prop, nil
+}
+
+func parseIcs(data string) (*icsComponent, error) {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\n ", "")
+	data = strings.ReplaceAll(data, "\n\t", "")
+
+	root := &icsComponent{}
+	stack := []*icsComponent{root}
+
+	for _, line := range strings.Split(data, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		prop, err := parseIcsLine(line)
+		if err != nil {
+			return nil, err
+		}
+
+		current := stack[len(stack)-1]
+		switch prop.Name {
+		case "BEGIN":
+			child := &icsComponent{Name: strings.ToUpper(prop.Value)}
+			current.Children = append(current.Children, child)
+			stack = append(stack, child)
+		case "END":
+			if len(stack) == 1 || current.Name != strings.ToUpper(prop.Value) {
+				return nil, fmt.Errorf("Unexpected END:%v", prop.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			current.Properties = append(current.Properties, prop)
+		}
+	}
+
+	if len(stack) != 1 {
+		return nil, errors.New("Unterminated component")
+	}
+
+	return root, nil
+}
+
+func eventToIcs(event *Event, uid string) string {
+	var builder strings.Builder
+
+	builder.WriteString(foldIcsLine("BEGIN:VCALENDAR"))
+	builder.WriteString(foldIcsLine("VERSION:2.0"))
+	builder.WriteString(foldIcsLine("PRODID:-//L2_go//Calendar//EN"))
+	builder.WriteString(foldIcsLine("BEGIN:VEVENT"))
+	builder.WriteString(foldIcsLine("UID:" + uid))
+	builder.WriteString(foldIcsLine("DTSTAMP:" + formatIcsTime(time.Now())))
+	builder.WriteString(foldIcsLine("DTSTART:" + formatIcsTime(event.EventTime)))
+	if event.DurationMinutes != 0 {
+		builder.WriteString(foldIcsLine("DTEND:" + formatIcsTime(eventEnd(event))))
+	}
+	builder.WriteString(foldIcsLine("SUMMARY:" + escapeIcsText(event.Title)))
+	if event.Description != "" {
+		builder.WriteString(foldIcsLine("DESCRIPTION:" + escapeIcsText(event.Description)))
+	}
+	if event.Location != "" {
+		builder.WriteString(foldIcsLine("LOCATION:" + escapeIcsText(event.Location)))
+	}
+	if len(event.Tags) != 0 {
+		escaped := make([]string, len(event.Tags))
+		for idx := range event.Tags {
+			escaped[idx] = escapeIcsText(event.Tags[idx])
+		}
+		builder.WriteString(foldIcsLine("CATEGORIES:" + strings.Join(escaped, ",")))
+	}
+	if event.Priority != 0 {
+		builder.WriteString(foldIcsLine(fmt.Sprintf("PRIORITY:%v", event.Priority)))
+	}
+	if event.Url != "" {
+		builder.WriteString(foldIcsLine("URL:" + event.Url))
+	}
+	builder.WriteString(foldIcsLine("END:VEVENT"))
+	builder.WriteString(foldIcsLine("END:VCALENDAR"))
+
+	return builder.String()
+}
+
+// Returns the event described by the first VEVENT of the calendar and its UID
+func icsToEvent(data string) (*Event, string, error) {
+	root, err := parseIcs(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	vevents := root.find("VEVENT")
+	if len(vevents) == 0 {
+		return nil, "", errors.New("No VEVENT in calendar data")
+	}
+	vevent := vevents[0]
+
+	event := &Event{Id: -1}
+
+	if summary := vevent.get("SUMMARY"); summary != nil {
+		event.Title = unescapeIcsText(summary.Value)
+	}
+	if event.Title == "" {
+		return nil, "", errors.New("Missing title")
+	}
+
+	dtstart := vevent.get("DTSTART")
+	if dtstart == nil {
+		return nil, "", errors.New("Missing time")
+	}
+	if event.EventTime, err = parseIcsTime(dtstart); err != nil {
+		return nil, "", err
+	}
+
+	if prop := vevent.get("DTEND"); prop != nil {
+		end, err := parseIcsTime(prop)
+		if err != nil {
+			return nil, "", err
+		}
+		if end.Before(event.EventTime) {
+			return nil, "", errors.New("DTEND is before DTSTART")
+		}
+		event.DurationMinutes = int(end.Sub(event.EventTime) / time.Minute)
+	}
+
+	if prop := vevent.get("DESCRIPTION"); prop != nil {
+		event.Description = unescapeIcsText(prop.Value)
+	}
+	if prop := vevent.get("LOCATION"); prop != nil {
+		event.Location = unescapeIcsText(prop.Value)
+	}
+	if prop := vevent.get("URL"); prop != nil {
+		event.Url = prop.Value
+	}
+	if prop := vevent.get("PRIORITY"); prop != nil {
+		if event.Priority, err = strconv.Atoi(prop.Value); err != nil || event.Priority < 0 || event.Priority > 9 {
+			return nil, "", fmt.Errorf("Invalid priority: %v", prop.Value)
+		}
+	}
+	for _, prop := range vevent.Properties {
+		if prop.Name == "CATEGORIES" {
+			event.Tags = append(event.Tags, splitIcsList(prop.Value)...)
+		}
+	}
+
+	uid := ""
+	if prop := vevent.get("UID"); prop != nil {
+		uid = prop.Value
+	}
+
+	return event, uid, nil
+}
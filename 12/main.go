@@ -8,30 +8,50 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+var (
+	ErrNoSuchObj  = errors.New("No such obj")
+	ErrNoSuchUser = errors.New("No such user")
+)
+
 type ErrorReport struct {
-	ErrorString string `json:"error"`
+	ErrorString string        `json:"error"`
+	Field       string        `json:"field,omitempty"`
+	Details     []ErrorReport `json:"details,omitempty"`
 }
 
 type ResultReport struct {
 	Result interface{} `json:"result"`
+	Page   *PageInfo   `json:"page,omitempty"`
+	// Days of the subscribed overlays within the queried range
+	Overlays []*OverlayDay `json:"overlays,omitempty"`
 }
 
 type User struct {
-	Id         int           `json:"user_id"`
-	Name       string        `json:"username"`
-	EventStore *Store[Event] `json:"-"`
+	Id           int                 `json:"user_id"`
+	Name         string              `json:"username"`
+	EventStore   *Store[Event]       `json:"-"`
+	BookingPages *Store[BookingPage] `json:"-"`
+	// Names of the subscribed overlays
+	Overlays []string `json:"overlays,omitempty"`
+	// SHA-256 of the CalDAV password, see POST /admin/caldav_token
+	CalDavTokenHash string `json:"caldav_token_hash,omitempty"`
+	// IANA name used when a request gives no time zone, UTC if empty
+	TimeZone string `json:"time_zone,omitempty"`
 }
 
 func NewUser(username string) *User {
 	return &User{
-		Id:         -1,
-		Name:       username,
-		EventStore: NewStore[Event](),
+		Id:           -1,
+		Name:         username,
+		EventStore:   NewStore(func(e *Event, id int) { e.Id = id }),
+		BookingPages: newBookingPageStore(),
 	}
 }
 
@@ -39,10 +59,77 @@ func (u *User) toJson() ([]byte, error) {
 	return json.Marshal(u)
 }
 
+// The stores are shared, they keep their own history
+func (u *User) clone() *User {
+	copied := *u
+	copied.Overlays = slices.Clone(u.Overlays)
+	return &copied
+}
+
+type Attachment struct {
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+	BlobId   string `json:"blob_id"`
+}
+
 type Event struct {
-	Id        int       `json:"event_id"`
-	Title     string    `json:"event_title"`
-	EventTime time.Time `json:"event_time"`
+	Id              int           `json:"event_id"`
+	Title           string        `json:"event_title"`
+	EventTime       time.Time     `json:"event_time"`
+	DurationMinutes int           `json:"duration_minutes,omitempty"`
+	Description     string        `json:"description,omitempty"`
+	Location        string        `json:"location,omitempty"`
+	Tags            []string      `json:"tags,omitempty"`
+	Priority        int           `json:"priority,omitempty"`
+	Url             string        `json:"url,omitempty"`
+	Attachments     []*Attachment `json:"attachments,omitempty"`
+	// UID and resource name of events put by CalDAV clients, the name is given by the server only
+	Uid        string `json:"uid,omitempty"`
+	CalDavName string `json:"caldav_name,omitempty"`
+}
+
+func (e *Event) clone() *Event {
+	copied := *e
+	copied.Tags = slices.Clone(e.Tags)
+	if e.Attachments != nil {
+		copied.Attachments = make([]*Attachment, len(e.Attachments))
+		for idx, attachment := range e.Attachments {
+			attachmentCopy := *attachment
+			copied.Attachments[idx] = &attachmentCopy
+		}
+	}
+	return &copied
+}
+
+func (e *Event) validate(needId bool) error {
+	if e.Title == "" {
+		return errors.New("Missing title")
+	}
+
+	if e.EventTime.IsZero() {
+		return errors.New("Missing time")
+	}
+
+	if needId && e.Id == -1 {
+		return errors.New("Missing id")
+	}
+
+	return nil
+}
+
+func (e *Event) hasAnyTag(tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+
+	for _, tag := range e.Tags {
+		if slices.Contains(tags, tag) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (e *Event) toJson() ([]byte, error) {
@@ -52,26 +139,63 @@ func (e *Event) toJson() ([]byte, error) {
 type Store[T interface{}] struct {
 	firstFreeIdx int
 	objMap       map[int]*T
+	history      map[int][]*Revision[T]
+	watchers     map[int]chan *Change[T]
+	nextWatcher  int
+	setId        func(*T, int)
 	mutex        sync.RWMutex
 }
 
-func NewStore[T interface{}]() *Store[T] {
+func NewStore[T interface{}](setId func(*T, int)) *Store[T] {
 	return &Store[T]{
 		firstFreeIdx: 0,
 		objMap:       make(map[int]*T),
+		history:      make(map[int][]*Revision[T]),
+		watchers:     make(map[int]chan *Change[T]),
+		setId:        setId,
 	}
 }
 
-func (s *Store[T]) add(obj *T) int {
+func (s *Store[T]) add(obj *T, actor string) int {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	idx := s.firstFreeIdx
+	if s.setId != nil {
+		s.setId(obj, idx)
+	}
 	s.objMap[idx] = obj
+	s.record(idx, actor, OpCreate, nil, obj)
 
 	s.firstFreeIdx++
 	return idx
 }
 
+// Adds the object only if check accepts the current content of the store,
+// both happen under the same lock
+func (s *Store[T]) addIf(obj *T, actor string, check func([]*T) error) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	existing := make([]*T, 0, len(s.objMap))
+	for _, val := range s.objMap {
+		existing = append(existing, val)
+	}
+
+	if err := check(existing); err != nil {
+		return -1, err
+	}
+
+	idx := s.firstFreeIdx
+	if s.setId != nil {
+		s.setId(obj, idx)
+	}
+	s.objMap[idx] = obj
+	s.record(idx, actor, OpCreate, nil, obj)
+
+	s.firstFreeIdx++
+	return idx, nil
+}
+
 func (s *Store[T]) get(id int) (*T, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
@@ -80,7 +204,19 @@ func (s *Store[T]) get(id int) (*T, error) {
 		return val, nil
 	}
 
-	return nil, errors.New("No such obj")
+	return nil, ErrNoSuchObj
+}
+
+// Returns the object together with its version, see version
+func (s *Store[T]) getVersioned(id int) (*T, int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if val, ok := s.objMap[id]; ok {
+		return val, len(s.history[id]), nil
+	}
+
+	return nil, 0, ErrNoSuchObj
 }
 
 func (s *Store[T]) iterate(apply func(*T)) {
@@ -92,63 +228,145 @@ func (s *Store[T]) iterate(apply func(*T)) {
 	}
 }
 
-func (s *Store[T]) update(id int, newObj *T) error {
+func (s *Store[T]) update(id int, newObj *T, actor string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	if _, ok := s.objMap[id]; ok {
+	if oldObj, ok := s.objMap[id]; ok {
+		if s.setId != nil {
+			s.setId(newObj, id)
+		}
 		s.objMap[id] = newObj
+		s.record(id, actor, OpUpdate, oldObj, newObj)
 		return nil
 	}
 
-	return errors.New("No such obj")
+	return ErrNoSuchObj
+}
+
+// Replaces the object with the result of change, which gets a copy of the current object and
+// its version. Both happen under the same lock, so concurrent changes aren't lost.
+// Returns the new version
+func (s *Store[T]) updateFunc(id int, actor string, change func(old *T, version int) (*T, error)) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	oldObj, ok := s.objMap[id]
+	if !ok {
+		return 0, ErrNoSuchObj
+	}
+
+	newObj, err := change(snapshot(oldObj), len(s.history[id]))
+	if err != nil {
+		return 0, err
+	}
+
+	if s.setId != nil {
+		s.setId(newObj, id)
+	}
+	s.objMap[id] = newObj
+	s.record(id, actor, OpUpdate, oldObj, newObj)
+
+	return len(s.history[id]), nil
+}
+
+// Deletes the object only if check accepts it and its version, both happen under the same lock
+func (s *Store[T]) deleteIf(id int, actor string, check func(obj *T, version int) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	oldObj, ok := s.objMap[id]
+	if !ok {
+		return ErrNoSuchObj
+	}
+
+	if err := check(oldObj, len(s.history[id])); err != nil {
+		return err
+	}
+
+	delete(s.objMap, id)
+	s.record(id, actor, OpDelete, oldObj, nil)
+	return nil
 }
 
-func (s *Store[T]) delete(id int) error {
+func (s *Store[T]) delete(id int, actor string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	if _, ok := s.objMap[id]; ok {
+	if oldObj, ok := s.objMap[id]; ok {
 		delete(s.objMap, id)
+		s.record(id, actor, OpDelete, oldObj, nil)
 		return nil
 	}
 
-	return errors.New("No such obj")
+	return ErrNoSuchObj
+}
+
+// POST /create_user
+func createUser(username string, timeZone string, actor string, userStore *Store[User]) int {
+	user := NewUser(username)
+	user.TimeZone = timeZone
+	return userStore.add(user, actor)
+}
+
+// POST /set_time_zone
+func setTimeZone(userIdx int, timeZone string, actor string, userStore *Store[User]) error {
+	_, err := userStore.updateFunc(userIdx, actor, func(user *User, _ int) (*User, error) {
+		user.TimeZone = timeZone
+		return user, nil
+	})
+	if errors.Is(err, ErrNoSuchObj) {
+		return ErrNoSuchUser
+	}
+	return err
+}
+
+// Location of the time zone of the request, or else of the user's one, or else UTC
+func userLocation(timeZone string, userIdx int, userStore *Store[User]) (*time.Location, error) {
+	if timeZone == "" {
+		if user, err := userStore.get(userIdx); err == nil {
+			timeZone = user.TimeZone
+		}
+	}
+
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("Unknown time zone: %v", timeZone)
+	}
+	return loc, nil
 }
 
 // POST /create_event
-func createEvent(userIdx int, event *Event, userStore *Store[User]) (int, error) {
+func createEvent(userIdx int, event *Event, actor string, userStore *Store[User]) (int, error) {
 	user, err := userStore.get(userIdx)
 	if err != nil {
 		return -1, err
 	}
 
-	idx := user.EventStore.add(event)
-	event.Id = idx
+	idx := user.EventStore.add(event, actor)
 	return idx, nil
 }
 
 // POST /update_event
-func updateEvent(userIdx int, eventIdx int, newEvent *Event, userStore *Store[User]) error {
+func updateEvent(userIdx int, eventIdx int, newEvent *Event, actor string, userStore *Store[User]) error {
 	user, err := userStore.get(userIdx)
 	if err != nil {
 		return err
 	}
 
-	err = user.EventStore.update(eventIdx, newEvent)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	_, err = user.EventStore.updateFunc(eventIdx, actor, func(old *Event, _ int) (*Event, error) {
+		newEvent.keepCalDavIdentity(old)
+		return newEvent, nil
+	})
+	return err
 }
 
 // POST /delete_event
-func deleteEvent(userIdx int, eventIdx int, userStore *Store[User]) error {
+func deleteEvent(userIdx int, eventIdx int, actor string, userStore *Store[User]) error {
 	user, err := userStore.get(userIdx)
 	if err != nil {
 		return err
 	}
 
-	err = user.EventStore.delete(eventIdx)
+	err = user.EventStore.delete(eventIdx, actor)
 	if err != nil {
 		return err
 	}
@@ -168,41 +386,90 @@ func getEventsInTimeFrame(start time.Time, end time.Time, eventStore *Store[Even
 	return res
 }
 
+func filterByTags(events []*Event, tags []string) []*Event {
+	var res []*Event
+
+	for _, ev := range events {
+		if ev.hasAnyTag(tags) {
+			res = append(res, ev)
+		}
+	}
+
+	return res
+}
+
+// Comma separated list of tags, events having any of them are selected
+func parseTags(r *http.Request) []string {
+	var tags []string
+
+	for _, tag := range strings.Split(r.URL.Query().Get("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+func dayRange(date time.Time) (time.Time, time.Time) {
+	return date, date.AddDate(0, 0, 1)
+}
+
+func weekRange(date time.Time) (time.Time, time.Time) {
+	date = date.AddDate(0, 0, -((int(date.Weekday()) + 6) % 7))
+	year, month, day := date.Date()
+
+	start := time.Date(year, month, day, 0, 0, 0, 0, date.Location())
+	return start, start.AddDate(0, 0, 7)
+}
+
+func monthRange(date time.Time) (time.Time, time.Time) {
+	year, month, _ := date.Date()
+
+	start := time.Date(year, month, 0, 0, 0, 0, 0, date.Location())
+	return start, start.AddDate(0, 1, 0)
+}
+
 // GET /events_for_day
 func eventsForDay(userIdx int, date time.Time, userStore *Store[User]) ([]*Event, error) {
 	if user, err := userStore.get(userIdx); err == nil {
-		end := date.AddDate(0, 0, 1)
+		start, end := dayRange(date)
 
-		return getEventsInTimeFrame(date, end, user.EventStore), nil
+		return getEventsInTimeFrame(start, end, user.EventStore), nil
 	}
-	return nil, errors.New("No such user")
+	return nil, ErrNoSuchUser
 }
 
 // GET /events_for_week
 func eventsForWeek(userIdx int, date time.Time, userStore *Store[User]) ([]*Event, error) {
 	if user, err := userStore.get(userIdx); err == nil {
-		date = date.AddDate(0, 0, -((int(date.Weekday()) + 6) % 7))
-		year, month, day := date.Date()
-
-		start := time.Date(year, month, day, 0, 0, 0, 0, date.Location())
-		end := start.AddDate(0, 0, 7)
+		start, end := weekRange(date)
 
 		return getEventsInTimeFrame(start, end, user.EventStore), nil
 	}
-	return nil, errors.New("No such user")
+	return nil, ErrNoSuchUser
 }
 
 // GET /events_for_month
 func eventsForMonth(userIdx int, date time.Time, userStore *Store[User]) ([]*Event, error) {
 	if user, err := userStore.get(userIdx); err == nil {
-		year, month, _ := date.Date()
-
-		start := time.Date(year, month, 0, 0, 0, 0, 0, date.Location())
-		end := start.AddDate(0, 1, 0)
+		start, end := monthRange(date)
 
 		return getEventsInTimeFrame(start, end, user.EventStore), nil
 	}
-	return nil, errors.New("No such user")
+	return nil, ErrNoSuchUser
+}
+
+// Events of the range together with the days of the overlays the user is subscribed to
+func sendRangeEvents(w http.ResponseWriter, r *http.Request, userIdx int, events []*Event, start time.Time, end time.Time,
+	userStore *Store[User], overlays *OverlayStore) {
+	calendar, err := userCalendar(userIdx, userStore, overlays)
+	if err != nil {
+		SendError(w, err, 500)
+		return
+	}
+
+	sendPage(w, r, filterByTags(events, parseTags(r)), eventOrder, &ResultReport{Overlays: calendar.between(start, end)})
 }
 
 func SendError(w http.ResponseWriter, err error, errorCode int) {
@@ -242,17 +509,12 @@ func parseEvent(body []byte, needId bool) (*Event, error) {
 		return nil, err
 	}
 
-	if event.Title == "" {
-		return nil, errors.New("Missing title")
-	}
-
-	if event.EventTime.IsZero() {
-		return nil, errors.New("Missing time")
+	if err := event.validate(needId); err != nil {
+		return nil, err
 	}
 
-	if needId && event.Id == -1 {
-		return nil, errors.New("Missing id")
-	}
+	// Only PUT over CalDAV names events
+	event.CalDavName = ""
 
 	return &event, nil
 }
@@ -270,6 +532,19 @@ func parseUsername(body []byte) (string, error) {
 	return user.Name, nil
 }
 
+// The time zone is optional, an empty one is returned if it's missing
+func parseTimeZone(body []byte) (string, error) {
+	user := User{}
+	if err := json.Unmarshal(body, &user); err != nil {
+		return "", err
+	}
+	if _, err := time.LoadLocation(user.TimeZone); err != nil {
+		return "", fmt.Errorf("Unknown time zone: %v", user.TimeZone)
+	}
+
+	return user.TimeZone, nil
+}
+
 func parseUserIdx(body []byte) (int, error) {
 	user := User{Id: -1}
 	err := json.Unmarshal(body, &user)
@@ -283,6 +558,14 @@ func parseUserIdx(body []byte) (int, error) {
 	return user.Id, nil
 }
 
+func parseUserIdxQuery(r *http.Request) (int, error) {
+	if !r.URL.Query().Has("user_id") {
+		return -1, errors.New("Missing user id")
+	}
+
+	return strconv.Atoi(r.URL.Query().Get("user_id"))
+}
+
 func HandleCreateEvent(w http.ResponseWriter, r *http.Request, userStore *Store[User]) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -302,7 +585,7 @@ func HandleCreateEvent(w http.ResponseWriter, r *http.Request, userStore *Store[
 		return
 	}
 
-	idx, err := createEvent(userIdx, event, userStore)
+	idx, err := createEvent(userIdx, event, getActor(r, userIdx), userStore)
 	if err != nil {
 		SendError(w, err, 500)
 		return
@@ -330,7 +613,7 @@ func HandleUpdateEvent(w http.ResponseWriter, r *http.Request, userStore *Store[
 		return
 	}
 
-	if err := updateEvent(userIdx, event.Id, event, userStore); err != nil {
+	if err := updateEvent(userIdx, event.Id, event, getActor(r, userIdx), userStore); err != nil {
 		SendError(w, err, 500)
 		return
 	}
@@ -358,7 +641,7 @@ func HandleDeleteEvent(w http.ResponseWriter, r *http.Request, userStore *Store[
 		return
 	}
 
-	if err := deleteEvent(userIdx, eventId, userStore); err != nil {
+	if err := deleteEvent(userIdx, eventId, getActor(r, userIdx), userStore); err != nil {
 		SendResult(w, "Success")
 		return
 	}
@@ -366,7 +649,7 @@ func HandleDeleteEvent(w http.ResponseWriter, r *http.Request, userStore *Store[
 	SendError(w, errors.New("No such event"), 500)
 }
 
-func HandleEvnetsForTheDay(w http.ResponseWriter, r *http.Request, userStore *Store[User]) {
+func HandleEvnetsForTheDay(w http.ResponseWriter, r *http.Request, userStore *Store[User], overlays *OverlayStore) {
 	if !r.URL.Query().Has("user_id") {
 		SendError(w, errors.New("Missing user id"), 400)
 		return
@@ -397,10 +680,11 @@ func HandleEvnetsForTheDay(w http.ResponseWriter, r *http.Request, userStore *St
 		return
 	}
 
-	SendResult(w, events)
+	start, end := dayRange(date)
+	sendRangeEvents(w, r, userIdx, events, start, end, userStore, overlays)
 }
 
-func HandleEvnetsWeek(w http.ResponseWriter, r *http.Request, userStore *Store[User]) {
+func HandleEvnetsWeek(w http.ResponseWriter, r *http.Request, userStore *Store[User], overlays *OverlayStore) {
 	if !r.URL.Query().Has("user_id") {
 		SendError(w, errors.New("Missing user id"), 400)
 		return
@@ -431,10 +715,11 @@ func HandleEvnetsWeek(w http.ResponseWriter, r *http.Request, userStore *Store[U
 		return
 	}
 
-	SendResult(w, events)
+	start, end := weekRange(date)
+	sendRangeEvents(w, r, userIdx, events, start, end, userStore, overlays)
 }
 
-func HandleEvnetsMonth(w http.ResponseWriter, r *http.Request, userStore *Store[User]) {
+func HandleEvnetsMonth(w http.ResponseWriter, r *http.Request, userStore *Store[User], overlays *OverlayStore) {
 	if !r.URL.Query().Has("user_id") {
 		SendError(w, errors.New("Missing user id"), 400)
 		return
@@ -465,7 +750,8 @@ func HandleEvnetsMonth(w http.ResponseWriter, r *http.Request, userStore *Store[
 		return
 	}
 
-	SendResult(w, events)
+	start, end := monthRange(date)
+	sendRangeEvents(w, r, userIdx, events, start, end, userStore, overlays)
 }
 
 func HandleCreateUser(w http.ResponseWriter, r *http.Request, userStore *Store[User]) {
@@ -481,12 +767,44 @@ func HandleCreateUser(w http.ResponseWriter, r *http.Request, userStore *Store[U
 		return
 	}
 
-	user := NewUser(username)
-	idx := userStore.add(user)
+	timeZone, err := parseTimeZone(body)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	idx := createUser(username, timeZone, getActor(r, -1), userStore)
 
 	SendResult(w, idx)
 }
 
+func HandleSetTimeZone(w http.ResponseWriter, r *http.Request, userStore *Store[User]) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	userIdx, err := parseUserIdx(body)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	timeZone, err := parseTimeZone(body)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	if err := setTimeZone(userIdx, timeZone, getActor(r, userIdx), userStore); err != nil {
+		SendError(w, err, 404)
+		return
+	}
+
+	SendResult(w, "Success")
+}
+
 func StorageWrapper(fn func(http.ResponseWriter, *http.Request, *Store[User]), userStore *Store[User]) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		fn(w, r, userStore)
@@ -500,31 +818,117 @@ func LoggerMiddleware(handler http.Handler) http.Handler {
 	})
 }
 
-func runServer(port int) {
-	userStore := NewStore[User]()
+func runServer(cfg *config) {
+	userStore := NewStore(func(u *User, id int) { u.Id = id })
 
 	createUserHandler := http.HandlerFunc(StorageWrapper(HandleCreateUser, userStore))
+	setTimeZoneHandler := http.HandlerFunc(StorageWrapper(HandleSetTimeZone, userStore))
 	createEventHandler := http.HandlerFunc(StorageWrapper(HandleCreateEvent, userStore))
 	updateEventHandler := http.HandlerFunc(StorageWrapper(HandleUpdateEvent, userStore))
 	deleteEventHandler := http.HandlerFunc(StorageWrapper(HandleDeleteEvent, userStore))
-	dayEventsHandler := http.HandlerFunc(StorageWrapper(HandleEvnetsForTheDay, userStore))
-	weekEventsHandler := http.HandlerFunc(StorageWrapper(HandleEvnetsWeek, userStore))
-	monthEventsHandler := http.HandlerFunc(StorageWrapper(HandleEvnetsMonth, userStore))
-
-	http.Handle("/create_user", LoggerMiddleware(createUserHandler))
-	http.Handle("/create_event", LoggerMiddleware(createEventHandler))
-	http.Handle("/update_event", LoggerMiddleware(updateEventHandler))
-	http.Handle("/delete_event", LoggerMiddleware(deleteEventHandler))
-	http.Handle("/events_for_day", LoggerMiddleware(dayEventsHandler))
-	http.Handle("/events_for_week", LoggerMiddleware(weekEventsHandler))
-	http.Handle("/events_for_month", LoggerMiddleware(monthEventsHandler))
-
-	err := http.ListenAndServe(fmt.Sprintf(":%v", port), nil)
+	eventHistoryHandler := http.HandlerFunc(StorageWrapper(HandleEventHistory, userStore))
+	restoreEventHandler := http.HandlerFunc(StorageWrapper(HandleRestoreEvent, userStore))
+	blobStore, err := NewBlobStore(cfg.BlobDir, cfg.MaxBlobSize)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	overlays, err := LoadOverlays(cfg.OverlayDir)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+
+	dayEventsHandler := http.HandlerFunc(OverlayWrapper(HandleEvnetsForTheDay, userStore, overlays))
+	weekEventsHandler := http.HandlerFunc(OverlayWrapper(HandleEvnetsWeek, userStore, overlays))
+	monthEventsHandler := http.HandlerFunc(OverlayWrapper(HandleEvnetsMonth, userStore, overlays))
+	overlaysHandler := http.HandlerFunc(OverlayWrapper(HandleOverlays, userStore, overlays))
+	overlayHandler := http.HandlerFunc(OverlayWrapper(HandleOverlay, userStore, overlays))
+	subscribeOverlayHandler := http.HandlerFunc(OverlayWrapper(HandleSubscribeOverlay, userStore, overlays))
+	unsubscribeOverlayHandler := http.HandlerFunc(OverlayWrapper(HandleUnsubscribeOverlay, userStore, overlays))
+	uploadBlobHandler := http.HandlerFunc(BlobStoreWrapper(HandleUploadBlob, blobStore))
+	downloadBlobHandler := http.HandlerFunc(BlobStoreWrapper(HandleDownloadBlob, blobStore))
+	createBookingPageHandler := http.HandlerFunc(StorageWrapper(HandleCreateBookingPage, userStore))
+	bookingPagesHandler := http.HandlerFunc(StorageWrapper(HandleBookingPages, userStore))
+	bookingSlotsHandler := http.HandlerFunc(OverlayWrapper(HandleBookingSlots, userStore, overlays))
+	bookHandler := http.HandlerFunc(OverlayWrapper(HandleBook, userStore, overlays))
+	agendaViewHandler := http.HandlerFunc(StorageWrapper(HandleAgendaView, userStore))
+	monthViewHandler := http.HandlerFunc(StorageWrapper(HandleMonthView, userStore))
+	weekViewHandler := http.HandlerFunc(StorageWrapper(HandleWeekView, userStore))
+	gridViewHandler := http.HandlerFunc(StorageWrapper(HandleGridView, userStore))
+	quickAddHandler := http.HandlerFunc(StorageWrapper(HandleQuickAdd, userStore))
+	backupHandler := http.HandlerFunc(StorageWrapper(HandleBackup, userStore))
+	restoreHandler := http.HandlerFunc(StorageWrapper(HandleRestore, userStore))
+	calDavTokenHandler := http.HandlerFunc(StorageWrapper(HandleCalDavToken, userStore))
+
+	spec := newApiSpec()
+
+	http.Handle("/create_user", LoggerMiddleware(ValidationMiddleware(spec, "/create_user", createUserHandler)))
+	http.Handle("/set_time_zone", LoggerMiddleware(ValidationMiddleware(spec, "/set_time_zone", setTimeZoneHandler)))
+	http.Handle("/create_event", LoggerMiddleware(ValidationMiddleware(spec, "/create_event", createEventHandler)))
+	http.Handle("/update_event", LoggerMiddleware(ValidationMiddleware(spec, "/update_event", updateEventHandler)))
+	http.Handle("/delete_event", LoggerMiddleware(ValidationMiddleware(spec, "/delete_event", deleteEventHandler)))
+	http.Handle("/events_for_day", LoggerMiddleware(ValidationMiddleware(spec, "/events_for_day", dayEventsHandler)))
+	http.Handle("/events_for_week", LoggerMiddleware(ValidationMiddleware(spec, "/events_for_week", weekEventsHandler)))
+	http.Handle("/events_for_month", LoggerMiddleware(ValidationMiddleware(spec, "/events_for_month", monthEventsHandler)))
+	http.Handle("/quick_add", LoggerMiddleware(ValidationMiddleware(spec, "/quick_add", quickAddHandler)))
+	http.Handle("/events/{id}/history", LoggerMiddleware(ValidationMiddleware(spec, "/events/{id}/history", eventHistoryHandler)))
+	http.Handle("/events/{id}/restore", LoggerMiddleware(ValidationMiddleware(spec, "/events/{id}/restore", restoreEventHandler)))
+	http.Handle("/overlays", LoggerMiddleware(ValidationMiddleware(spec, "/overlays", overlaysHandler)))
+	http.Handle("/overlays/{name}", LoggerMiddleware(ValidationMiddleware(spec, "/overlays/{name}", overlayHandler)))
+	http.Handle("/overlays/{name}/subscribe", LoggerMiddleware(ValidationMiddleware(spec, "/overlays/{name}/subscribe", subscribeOverlayHandler)))
+	http.Handle("/overlays/{name}/unsubscribe", LoggerMiddleware(ValidationMiddleware(spec, "/overlays/{name}/unsubscribe", unsubscribeOverlayHandler)))
+	http.Handle("/openapi.json", LoggerMiddleware(ValidationMiddleware(spec, "/openapi.json", OpenApiHandler(spec))))
+	http.Handle("/blobs", LoggerMiddleware(ValidationMiddleware(spec, "/blobs", uploadBlobHandler)))
+	http.Handle("/blobs/{id}", LoggerMiddleware(ValidationMiddleware(spec, "/blobs/{id}", downloadBlobHandler)))
+	http.Handle("/views/agenda", LoggerMiddleware(ValidationMiddleware(spec, "/views/agenda", agendaViewHandler)))
+	http.Handle("/views/month", LoggerMiddleware(ValidationMiddleware(spec, "/views/month", monthViewHandler)))
+	http.Handle("/views/week", LoggerMiddleware(ValidationMiddleware(spec, "/views/week", weekViewHandler)))
+	http.Handle("/views/grid", LoggerMiddleware(ValidationMiddleware(spec, "/views/grid", gridViewHandler)))
+	http.Handle("/create_booking_page", LoggerMiddleware(ValidationMiddleware(spec, "/create_booking_page", createBookingPageHandler)))
+	http.Handle("/booking_pages", LoggerMiddleware(ValidationMiddleware(spec, "/booking_pages", bookingPagesHandler)))
+	http.Handle("/book/{token}/slots", LoggerMiddleware(ValidationMiddleware(spec, "/book/{token}/slots", bookingSlotsHandler)))
+	http.Handle("/book/{token}", LoggerMiddleware(ValidationMiddleware(spec, "/book/{token}", bookHandler)))
+	http.Handle("/admin/backup", LoggerMiddleware(AdminMiddleware(cfg.AdminToken, ValidationMiddleware(spec, "/admin/backup", backupHandler))))
+	http.Handle("/admin/restore", LoggerMiddleware(AdminMiddleware(cfg.AdminToken, ValidationMiddleware(spec, "/admin/restore", restoreHandler))))
+	http.Handle("/admin/caldav_token", LoggerMiddleware(AdminMiddleware(cfg.AdminToken, ValidationMiddleware(spec, "/admin/caldav_token", calDavTokenHandler))))
+
+	calDavHandler := NewCalDAVHandler(userStore)
+	http.Handle(calDavPrefix, LoggerMiddleware(calDavHandler))
+	http.Handle("/.well-known/caldav", LoggerMiddleware(calDavHandler))
+
+	http.Handle(grpcPrefix, LoggerMiddleware(NewGrpcServer(userStore)))
+
+	if cfg.ServeUI {
+		http.Handle(uiPrefix, LoggerMiddleware(UIHandler()))
+		http.Handle("/{$}", http.RedirectHandler(uiPrefix, http.StatusFound))
+	}
+
+	// gRPC clients connect with HTTP/2 without TLS, so it is enabled next to HTTP/1
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+
+	server := &http.Server{
+		Addr:      fmt.Sprintf(":%v", cfg.Port),
+		Handler:   CorsMiddleware(cfg.Cors, http.DefaultServeMux),
+		Protocols: protocols,
+	}
+
+	err = server.ListenAndServe()
 	fmt.Println(err.Error())
 }
 
 type config struct {
-	Port int
+	Port        int
+	AdminToken  string
+	BlobDir     string
+	MaxBlobSize int64
+	OverlayDir  string
+	// Cross-origin requests are rejected by browsers unless it is set
+	Cors    *corsConfig
+	ServeUI bool
 }
 
 func getConfig() (*config, error) {
@@ -533,19 +937,44 @@ func getConfig() (*config, error) {
 		return nil, err
 	}
 
-	config := &config{Port: -1}
+	config := &config{Port: -1, BlobDir: "blobs", MaxBlobSize: 10 << 20, OverlayDir: "overlays"}
 	if err := json.Unmarshal(file, config); err != nil || config.Port == -1 {
 		return nil, err
 	}
 
+	if config.Cors != nil {
+		if err := config.Cors.validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	return config, nil
 }
 func main() {
 	config, err := getConfig()
 	if err != nil {
-		fmt.Println("Couldn't parse config")
+		fmt.Println("Couldn't parse config:", err.Error())
 		return
 	}
 
-	runServer(config.Port)
+	command := "serve"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	switch command {
+	case "serve":
+		runServer(config)
+	case "backup":
+		err = runBackup(config, os.Args[2:])
+	case "restore":
+		err = runRestore(config, os.Args[2:])
+	default:
+		err = fmt.Errorf("Unknown command %v, expected serve, backup or restore", command)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,983 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+
+	// Pattern compiled by newApiSpec, so requests don't compile it again
+	pattern *regexp.Regexp
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Operation struct {
+	OperationId string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type ApiInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type ApiSpec struct {
+	OpenApi    string                           `json:"openapi"`
+	Info       ApiInfo                          `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func objectSchema(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{
+		Type:                 "object",
+		Properties:           properties,
+		Required:             required,
+		AdditionalProperties: boolPtr(false),
+	}
+}
+
+func mergeProperties(base map[string]*Schema, extra map[string]*Schema) map[string]*Schema {
+	res := make(map[string]*Schema, len(base)+len(extra))
+	for name, schema := range base {
+		res[name] = schema
+	}
+	for name, schema := range extra {
+		res[name] = schema
+	}
+	return res
+}
+
+func jsonBody(schema *Schema) *RequestBody {
+	return &RequestBody{
+		Required: true,
+		Content:  map[string]*MediaType{"application/json": {Schema: schema}},
+	}
+}
+
+func queryParam(name string, schema *Schema) *Parameter {
+	return &Parameter{Name: name, In: "query", Required: true, Schema: schema}
+}
+
+func optionalQueryParam(name string, schema *Schema) *Parameter {
+	return &Parameter{Name: name, In: "query", Required: false, Schema: schema}
+}
+
+func pathParam(name string, schema *Schema) *Parameter {
+	return &Parameter{Name: name, In: "path", Required: true, Schema: schema}
+}
+
+func responses(result *Schema) map[string]*Response {
+	return map[string]*Response{
+		"200": {
+			Description: "Success",
+			Content: map[string]*MediaType{"application/json": {Schema: objectSchema(
+				map[string]*Schema{"result": result}, "result",
+			)}},
+		},
+		"default": {
+			Description: "Error",
+			Content:     map[string]*MediaType{"application/json": {Schema: ref("ErrorReport")}},
+		},
+	}
+}
+
+// Response of a list endpoint, see SendPage
+func pagedResponses(items *Schema) map[string]*Response {
+	res := responses(items)
+	res["200"].Content["application/json"].Schema.Properties["page"] = ref("PageInfo")
+	return res
+}
+
+func pageParams(sorts ...string) []*Parameter {
+	return []*Parameter{
+		optionalQueryParam("limit", &Schema{Type: "integer", Minimum: floatPtr(1), Maximum: floatPtr(maxPageLimit)}),
+		optionalQueryParam("cursor", &Schema{Type: "string"}),
+		optionalQueryParam("sort", &Schema{Type: "string", Enum: sorts}),
+		optionalQueryParam("order", &Schema{Type: "string", Enum: []string{"asc", "desc"}}),
+	}
+}
+
+func newApiSpec() *ApiSpec {
+	idSchema := &Schema{Type: "integer", Minimum: floatPtr(0)}
+	titleSchema := &Schema{Type: "string", MinLength: intPtr(1)}
+	timeSchema := &Schema{Type: "string", Format: "date-time"}
+	dateSchema := &Schema{Type: "string", Format: "date"}
+	eventList := &Schema{Type: "array", Items: ref("Event"), Nullable: true}
+
+	blobIdSchema := &Schema{Type: "string", Pattern: blobIdPattern.String()}
+	tagsSchema := &Schema{Type: "array", Items: titleSchema}
+	prioritySchema := &Schema{Type: "integer", Minimum: floatPtr(0), Maximum: floatPtr(9)}
+	urlSchema := &Schema{Type: "string", Format: "uri"}
+	attachmentSchema := objectSchema(map[string]*Schema{
+		"name":      titleSchema,
+		"mime_type": titleSchema,
+		"size":      {Type: "integer", Minimum: floatPtr(0)},
+		"blob_id":   blobIdSchema,
+	}, "name", "blob_id")
+
+	workingHoursSchema := objectSchema(map[string]*Schema{
+		"weekday": {Type: "integer", Minimum: floatPtr(0), Maximum: floatPtr(6)},
+		"start":   {Type: "string", Pattern: "^[0-2][0-9]:[0-5][0-9]$"},
+		"end":     {Type: "string", Pattern: "^[0-2][0-9]:[0-5][0-9]$"},
+	}, "weekday", "start", "end")
+	bookingPageProperties := map[string]*Schema{
+		"title":            titleSchema,
+		"duration_minutes": {Type: "integer", Minimum: floatPtr(1)},
+		"buffer_minutes":   {Type: "integer", Minimum: floatPtr(0)},
+		"working_hours":    {Type: "array", Items: workingHoursSchema},
+		"time_zone":        {Type: "string"},
+		"horizon_days":     {Type: "integer", Minimum: floatPtr(1)},
+		"max_per_day":      {Type: "integer", Minimum: floatPtr(0)},
+	}
+
+	eventBody := func(needId bool) *Schema {
+		properties := map[string]*Schema{
+			"user_id":          idSchema,
+			"event_title":      titleSchema,
+			"event_time":       timeSchema,
+			"duration_minutes": {Type: "integer", Minimum: floatPtr(0)},
+			"description":      {Type: "string"},
+			"location":         {Type: "string"},
+			"tags":             tagsSchema,
+			"priority":         prioritySchema,
+			"url":              urlSchema,
+			"attachments":      {Type: "array", Items: attachmentSchema},
+			"uid":              {Type: "string"},
+			"caldav_name":      {Type: "string"},
+		}
+		required := []string{"user_id", "event_title", "event_time"}
+
+		if needId {
+			properties["event_id"] = idSchema
+			required = append(required, "event_id")
+		}
+
+		return objectSchema(properties, required...)
+	}
+
+	rangeQuery := func(id string, summary string) map[string]*Operation {
+		rangeResponses := pagedResponses(eventList)
+		rangeResponses["200"].Content["application/json"].Schema.Properties["overlays"] = &Schema{
+			Type: "array", Items: ref("OverlayDay"),
+		}
+
+		return map[string]*Operation{
+			"get": {
+				OperationId: id,
+				Summary:     summary,
+				Parameters: append([]*Parameter{
+					queryParam("user_id", idSchema),
+					queryParam("date", dateSchema),
+					optionalQueryParam("tags", &Schema{Type: "string"}),
+				}, pageParams(eventOrder.sortNames()...)...),
+				Responses: rangeResponses,
+			},
+		}
+	}
+
+	viewParams := func(withRange bool) []*Parameter {
+		params := []*Parameter{
+			queryParam("user_id", idSchema),
+			queryParam("date", dateSchema),
+			optionalQueryParam("time_zone", &Schema{Type: "string"}),
+			optionalQueryParam("tags", &Schema{Type: "string"}),
+		}
+		if withRange {
+			params = append(params, optionalQueryParam("range", &Schema{Type: "string", Enum: []string{"week", "month"}}))
+		}
+		return params
+	}
+
+	rendered := func(contentType string, description string) map[string]*Response {
+		return map[string]*Response{
+			"200": {
+				Description: description,
+				Content:     map[string]*MediaType{contentType: {Schema: &Schema{Type: "string"}}},
+			},
+			"default": {
+				Description: "Error",
+				Content:     map[string]*MediaType{"application/json": {Schema: ref("ErrorReport")}},
+			},
+		}
+	}
+
+	spec := &ApiSpec{
+		OpenApi: "3.0.3",
+		Info:    ApiInfo{Title: "Calendar API", Version: "1.0.0"},
+		Paths: map[string]map[string]*Operation{
+			"/create_user": {
+				"post": {
+					OperationId: "createUser",
+					Summary:     "Create a user, the time zone is used by requests that give none",
+					RequestBody: jsonBody(objectSchema(
+						map[string]*Schema{"username": titleSchema, "time_zone": {Type: "string"}}, "username",
+					)),
+					Responses: responses(&Schema{Type: "integer"}),
+				},
+			},
+			"/set_time_zone": {
+				"post": {
+					OperationId: "setTimeZone",
+					Summary:     "Set the IANA time zone of the user, an empty one means UTC",
+					RequestBody: jsonBody(objectSchema(
+						map[string]*Schema{"user_id": idSchema, "time_zone": {Type: "string"}}, "user_id", "time_zone",
+					)),
+					Responses: responses(&Schema{Type: "string"}),
+				},
+			},
+			"/create_event": {
+				"post": {
+					OperationId: "createEvent",
+					Summary:     "Create an event",
+					RequestBody: jsonBody(eventBody(false)),
+					Responses:   responses(&Schema{Type: "integer"}),
+				},
+			},
+			"/quick_add": {
+				"post": {
+					OperationId: "quickAdd",
+					Summary:     "Create events from a phrase like \"Lunch with Anna tomorrow 13:00 for 1h\", English and Russian are understood. Times are taken in the time zone of the user unless time_zone is given",
+					RequestBody: jsonBody(objectSchema(map[string]*Schema{
+						"user_id":      idSchema,
+						"text":         titleSchema,
+						"time_zone":    {Type: "string"},
+						"preview":      {Type: "boolean"},
+						"horizon_days": {Type: "integer", Minimum: floatPtr(1), Maximum: floatPtr(366)},
+					}, "user_id", "text")),
+					Responses: responses(ref("QuickAddResult")),
+				},
+			},
+			"/update_event": {
+				"post": {
+					OperationId: "updateEvent",
+					Summary:     "Replace an event",
+					RequestBody: jsonBody(eventBody(true)),
+					Responses:   responses(&Schema{Type: "string"}),
+				},
+			},
+			"/delete_event": {
+				"post": {
+					OperationId: "deleteEvent",
+					Summary:     "Delete an event",
+					RequestBody: jsonBody(objectSchema(
+						map[string]*Schema{"user_id": idSchema, "event_id": idSchema}, "user_id", "event_id",
+					)),
+					Responses: responses(&Schema{Type: "string"}),
+				},
+			},
+			"/events_for_day":   rangeQuery("eventsForDay", "Events of the given day"),
+			"/events_for_week":  rangeQuery("eventsForWeek", "Events of the week containing the given date"),
+			"/events_for_month": rangeQuery("eventsForMonth", "Events of the month containing the given date"),
+			"/overlays": {
+				"get": {
+					OperationId: "overlays",
+					Summary:     "Overlay calendars available for subscription",
+					Parameters:  pageParams(overlayOrder.sortNames()...),
+					Responses:   pagedResponses(&Schema{Type: "array", Items: ref("Overlay")}),
+				},
+			},
+			"/overlays/{name}": {
+				"get": {
+					OperationId: "overlay",
+					Summary:     "Days of an overlay calendar",
+					Parameters:  []*Parameter{pathParam("name", &Schema{Type: "string"})},
+					Responses:   responses(ref("Overlay")),
+				},
+			},
+			"/overlays/{name}/subscribe": {
+				"post": {
+					OperationId: "subscribeOverlay",
+					Summary:     "Subscribe a user to an overlay, responds with the names of all subscribed overlays",
+					Parameters:  []*Parameter{pathParam("name", &Schema{Type: "string"})},
+					RequestBody: jsonBody(objectSchema(map[string]*Schema{"user_id": idSchema}, "user_id")),
+					Responses:   responses(&Schema{Type: "array", Items: &Schema{Type: "string"}}),
+				},
+			},
+			"/overlays/{name}/unsubscribe": {
+				"post": {
+					OperationId: "unsubscribeOverlay",
+					Summary:     "Unsubscribe a user from an overlay",
+					Parameters:  []*Parameter{pathParam("name", &Schema{Type: "string"})},
+					RequestBody: jsonBody(objectSchema(map[string]*Schema{"user_id": idSchema}, "user_id")),
+					Responses:   responses(&Schema{Type: "array", Items: &Schema{Type: "string"}}),
+				},
+			},
+			"/events/{id}/history": {
+				"get": {
+					OperationId: "eventHistory",
+					Summary:     "Change history of an event",
+					Parameters: append([]*Parameter{
+						pathParam("id", idSchema),
+						queryParam("user_id", idSchema),
+					}, pageParams(revisionOrder.sortNames()...)...),
+					Responses: pagedResponses(&Schema{Type: "array", Items: ref("Revision")}),
+				},
+			},
+			"/events/{id}/restore": {
+				"post": {
+					OperationId: "restoreEvent",
+					Summary:     "Restore an event to the given version",
+					Parameters:  []*Parameter{pathParam("id", idSchema)},
+					RequestBody: jsonBody(objectSchema(
+						map[string]*Schema{"user_id": idSchema, "version": &Schema{Type: "integer", Minimum: floatPtr(1)}},
+						"user_id", "version",
+					)),
+					Responses: responses(ref("Event")),
+				},
+			},
+			"/views/agenda": {
+				"get": {
+					OperationId: "agendaView",
+					Summary:     "Plain text agenda of the week or month",
+					Parameters:  viewParams(true),
+					Responses:   rendered("text/plain", "Agenda"),
+				},
+			},
+			"/views/month": {
+				"get": {
+					OperationId: "monthView",
+					Summary:     "HTML month grid",
+					Parameters:  viewParams(false),
+					Responses:   rendered("text/html", "Month grid"),
+				},
+			},
+			"/views/week": {
+				"get": {
+					OperationId: "weekView",
+					Summary:     "HTML week timeline",
+					Parameters:  viewParams(false),
+					Responses:   rendered("text/html", "Week timeline"),
+				},
+			},
+			"/views/grid": {
+				"get": {
+					OperationId: "gridView",
+					Summary:     "Events of the week or month bucketed by day and hour",
+					Parameters:  viewParams(true),
+					Responses:   responses(ref("Grid")),
+				},
+			},
+			"/create_booking_page": {
+				"post": {
+					OperationId: "createBookingPage",
+					Summary:     "Create a public booking page, its token forms the public link",
+					RequestBody: jsonBody(objectSchema(mergeProperties(bookingPageProperties, map[string]*Schema{"user_id": idSchema}),
+						"user_id", "title", "duration_minutes", "working_hours", "horizon_days",
+					)),
+					Responses: responses(ref("BookingPage")),
+				},
+			},
+			"/booking_pages": {
+				"get": {
+					OperationId: "bookingPages",
+					Summary:     "Booking pages of the user",
+					Parameters:  append([]*Parameter{queryParam("user_id", idSchema)}, pageParams(bookingPageOrder.sortNames()...)...),
+					Responses:   pagedResponses(&Schema{Type: "array", Items: ref("BookingPage")}),
+				},
+			},
+			"/book/{token}/slots": {
+				"get": {
+					OperationId: "bookingSlots",
+					Summary:     "Open slots of a booking page",
+					Parameters:  append([]*Parameter{pathParam("token", &Schema{Type: "string"})}, pageParams(slotOrder.sortNames()...)...),
+					Responses:   pagedResponses(&Schema{Type: "array", Items: ref("Slot")}),
+				},
+			},
+			"/book/{token}": {
+				"post": {
+					OperationId: "book",
+					Summary:     "Book an open slot, responds with 409 if it was taken",
+					Parameters:  []*Parameter{pathParam("token", &Schema{Type: "string"})},
+					RequestBody: jsonBody(objectSchema(map[string]*Schema{
+						"start": timeSchema,
+						"name":  titleSchema,
+						"email": {Type: "string"},
+					}, "start", "name")),
+					Responses: responses(ref("Event")),
+				},
+			},
+			"/admin/backup": {
+				"get": {
+					OperationId: "backup",
+					Summary:     "Dump all users and events into a versioned archive",
+					Responses: map[string]*Response{
+						"200": {
+							Description: "Archive",
+							Content:     map[string]*MediaType{"application/json": {Schema: ref("Archive")}},
+						},
+						"default": {
+							Description: "Error",
+							Content:     map[string]*MediaType{"application/json": {Schema: ref("ErrorReport")}},
+						},
+					},
+				},
+			},
+			"/admin/restore": {
+				"post": {
+					OperationId: "restore",
+					Summary:     "Replace all users and events with the content of an archive",
+					RequestBody: jsonBody(ref("Archive")),
+					Responses:   responses(&Schema{Type: "string"}),
+				},
+			},
+			"/admin/caldav_token": {
+				"post": {
+					OperationId: "issueCalDavToken",
+					Summary:     "Issue a new CalDAV password of the user, the previous one stops working",
+					RequestBody: jsonBody(objectSchema(map[string]*Schema{"user_id": idSchema}, "user_id")),
+					Responses:   responses(&Schema{Type: "string"}),
+				},
+			},
+			"/blobs": {
+				"post": {
+					OperationId: "uploadBlob",
+					Summary:     "Upload attachment content, the blob id is the SHA-256 of the content and content uploaded before keeps its first name and type. Types other than common images, audio, video, PDF and plain text are stored as application/octet-stream",
+					Parameters:  []*Parameter{optionalQueryParam("name", &Schema{Type: "string"})},
+					RequestBody: &RequestBody{
+						Required: true,
+						Content:  map[string]*MediaType{"application/octet-stream": {Schema: &Schema{Type: "string", Format: "binary"}}},
+					},
+					Responses: responses(ref("BlobInfo")),
+				},
+			},
+			"/blobs/{id}": {
+				"get": {
+					OperationId: "downloadBlob",
+					Summary:     "Download attachment content",
+					Parameters:  []*Parameter{pathParam("id", blobIdSchema)},
+					Responses: map[string]*Response{
+						"200": {Description: "Blob content"},
+						"default": {
+							Description: "Error",
+							Content:     map[string]*MediaType{"application/json": {Schema: ref("ErrorReport")}},
+						},
+					},
+				},
+			},
+			"/openapi.json": {
+				"get": {
+					OperationId: "openApi",
+					Summary:     "This document",
+					Responses: map[string]*Response{
+						"200": {Description: "OpenAPI description of the API"},
+					},
+				},
+			},
+		},
+		Components: Components{
+			Schemas: map[string]*Schema{
+				"Event": {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"event_id":         {Type: "integer"},
+						"event_title":      {Type: "string"},
+						"event_time":       timeSchema,
+						"duration_minutes": {Type: "integer"},
+						"description":      {Type: "string"},
+						"location":         {Type: "string"},
+						"tags":             tagsSchema,
+						"priority":         prioritySchema,
+						"url":              urlSchema,
+						"attachments":      {Type: "array", Items: ref("Attachment")},
+						"uid":              {Type: "string"},
+						"caldav_name":      {Type: "string"},
+					},
+				},
+				"Attachment": attachmentSchema,
+				"BookingPage": {
+					Type: "object",
+					Properties: mergeProperties(bookingPageProperties, map[string]*Schema{
+						"page_id": {Type: "integer"},
+						"token":   {Type: "string"},
+					}),
+				},
+				"QuickAddResult": {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"text":       {Type: "string"},
+						"summary":    {Type: "string"},
+						"time_zone":  {Type: "string"},
+						"recurrence": {Type: "string"},
+						"notes":      {Type: "array", Items: &Schema{Type: "string"}},
+						"committed":  {Type: "boolean"},
+						"events":     {Type: "array", Items: ref("Event")},
+					},
+				},
+				"OverlayDay": {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"date":    dateSchema,
+						"name":    {Type: "string"},
+						"working": {Type: "boolean"},
+						"overlay": {Type: "string"},
+					},
+				},
+				"Overlay": {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"name":  {Type: "string"},
+						"title": {Type: "string"},
+						"days":  {Type: "array", Items: ref("OverlayDay")},
+					},
+				},
+				"PageInfo": {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"total":       {Type: "integer"},
+						"limit":       {Type: "integer"},
+						"next_cursor": {Type: "string"},
+					},
+				},
+				"Grid": {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"start": dateSchema,
+						"end":   dateSchema,
+						"days": {Type: "array", Items: &Schema{
+							Type: "object",
+							Properties: map[string]*Schema{
+								"date":  dateSchema,
+								"hours": {Type: "object"},
+							},
+						}},
+					},
+				},
+				"Slot": {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"start": timeSchema,
+						"end":   timeSchema,
+					},
+				},
+				"BlobInfo": {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"blob_id":   blobIdSchema,
+						"name":      {Type: "string"},
+						"mime_type": {Type: "string"},
+						"size":      {Type: "integer"},
+					},
+				},
+				"Revision": {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"version":   {Type: "integer"},
+						"actor":     {Type: "string"},
+						"timestamp": timeSchema,
+						"operation": {Type: "string", Enum: []string{OpCreate, OpUpdate, OpDelete, OpRestore}},
+						"before":    {Ref: "#/components/schemas/Event", Nullable: true},
+						"after":     {Ref: "#/components/schemas/Event", Nullable: true},
+					},
+				},
+				"Archive": {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"version":    {Type: "integer", Minimum: floatPtr(1)},
+						"created_at": timeSchema,
+						"users":      {Type: "object"},
+						"events":     {Type: "object"},
+					},
+					Required: []string{"version", "users"},
+				},
+				"ErrorReport": {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"error":   {Type: "string"},
+						"field":   {Type: "string"},
+						"details": {Type: "array", Items: ref("ErrorReport")},
+					},
+					Required: []string{"error"},
+				},
+			},
+		},
+	}
+	spec.compilePatterns()
+
+	return spec
+}
+
+// Patterns are part of the code, so a broken one panics when the server starts
+func (spec *ApiSpec) compilePatterns() {
+	var compile func(schema *Schema)
+	compile = func(schema *Schema) {
+		if schema == nil {
+			return
+		}
+
+		if schema.Pattern != "" && schema.pattern == nil {
+			schema.pattern = regexp.MustCompile(schema.Pattern)
+		}
+		for _, prop := range schema.Properties {
+			compile(prop)
+		}
+		compile(schema.Items)
+	}
+
+	for _, ops := range spec.Paths {
+		for _, op := range ops {
+			for _, param := range op.Parameters {
+				compile(param.Schema)
+			}
+			if op.RequestBody != nil {
+				for _, media := range op.RequestBody.Content {
+					compile(media.Schema)
+				}
+			}
+		}
+	}
+
+	for _, schema := range spec.Components.Schemas {
+		compile(schema)
+	}
+}
+
+func (spec *ApiSpec) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = spec.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+func (spec *ApiSpec) validateValue(field string, value interface{}, schema *Schema) []ErrorReport {
+	schema = spec.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+
+	if value == nil {
+		if schema.Nullable {
+			return nil
+		}
+		return []ErrorReport{{Field: field, ErrorString: "Must not be null"}}
+	}
+
+	typeError := []ErrorReport{{Field: field, ErrorString: fmt.Sprintf("Must be of type %v", schema.Type)}}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return typeError
+		}
+		return spec.validateObject(field, obj, schema)
+
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return typeError
+		}
+
+		var errs []ErrorReport
+		for idx := range arr {
+			errs = append(errs, spec.validateValue(fmt.Sprintf("%v[%v]", field, idx), arr[idx], schema.Items)...)
+		}
+		return errs
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return typeError
+		}
+		return validateString(field, str, schema)
+
+	case "integer", "number":
+		num, ok := value.(json.Number)
+		if !ok {
+			return typeError
+		}
+
+		if schema.Type == "integer" {
+			if _, err := strconv.Atoi(num.String()); err != nil {
+				return typeError
+			}
+		}
+
+		parsed, err := num.Float64()
+		if err != nil {
+			return typeError
+		}
+		return validateNumber(field, parsed, schema)
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return typeError
+		}
+	}
+
+	return nil
+}
+
+func (spec *ApiSpec) validateObject(field string, obj map[string]interface{}, schema *Schema) []ErrorReport {
+	var errs []ErrorReport
+
+	join := func(name string) string {
+		return field + "." + name
+	}
+
+	for _, name := range schema.Required {
+		if _, ok := obj[name]; !ok {
+			errs = append(errs, ErrorReport{Field: join(name), ErrorString: "Missing field"})
+		}
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		propSchema, ok := schema.Properties[name]
+		if !ok {
+			if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+				errs = append(errs, ErrorReport{Field: join(name), ErrorString: "Unknown field"})
+			}
+			continue
+		}
+
+		errs = append(errs, spec.validateValue(join(name), obj[name], propSchema)...)
+	}
+
+	return errs
+}
+
+func validateString(field string, str string, schema *Schema) []ErrorReport {
+	if schema.MinLength != nil && len([]rune(str)) < *schema.MinLength {
+		return []ErrorReport{{Field: field, ErrorString: fmt.Sprintf("Must be at least %v characters long", *schema.MinLength)}}
+	}
+
+	if schema.pattern != nil {
+		if !schema.pattern.MatchString(str) {
+			return []ErrorReport{{Field: field, ErrorString: fmt.Sprintf("Must match %v", schema.Pattern)}}
+		}
+	}
+
+	if len(schema.Enum) != 0 && !slices.Contains(schema.Enum, str) {
+		return []ErrorReport{{Field: field, ErrorString: fmt.Sprintf("Must be one of %v", strings.Join(schema.Enum, ", "))}}
+	}
+
+	switch schema.Format {
+	case "date":
+		if _, err := time.Parse(time.DateOnly, str); err != nil {
+			return []ErrorReport{{Field: field, ErrorString: "Must be a date in YYYY-MM-DD format"}}
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			return []ErrorReport{{Field: field, ErrorString: "Must be an RFC3339 date-time"}}
+		}
+	case "uri":
+		if parsed, err := url.Parse(str); err != nil || !parsed.IsAbs() {
+			return []ErrorReport{{Field: field, ErrorString: "Must be an absolute URI"}}
+		}
+	}
+
+	return nil
+}
+
+func validateNumber(field string, num float64, schema *Schema) []ErrorReport {
+	if schema.Minimum != nil && num < *schema.Minimum {
+		return []ErrorReport{{Field: field, ErrorString: fmt.Sprintf("Must be at least %v", *schema.Minimum)}}
+	}
+
+	if schema.Maximum != nil && num > *schema.Maximum {
+		return []ErrorReport{{Field: field, ErrorString: fmt.Sprintf("Must be at most %v", *schema.Maximum)}}
+	}
+
+	return nil
+}
+
+// Parameters arrive as strings, so they are converted to the JSON
+// representation their schema expects before validation
+func (spec *ApiSpec) validateParam(param *Parameter, raw string, present bool) []ErrorReport {
+	field := param.In + "." + param.Name
+
+	if !present {
+		if param.Required {
+			return []ErrorReport{{Field: field, ErrorString: "Missing parameter"}}
+		}
+		return nil
+	}
+
+	var value interface{} = raw
+	if schema := spec.resolve(param.Schema); schema != nil && (schema.Type == "integer" || schema.Type == "number") {
+		value = json.Number(raw)
+	}
+
+	return spec.validateValue(field, value, param.Schema)
+}
+
+func (spec *ApiSpec) validateRequest(op *Operation, r *http.Request) ([]ErrorReport, error) {
+	var errs []ErrorReport
+
+	for _, param := range op.Parameters {
+		switch param.In {
+		case "query":
+			errs = append(errs, spec.validateParam(param, r.URL.Query().Get(param.Name), r.URL.Query().Has(param.Name))...)
+		case "path":
+			value := r.PathValue(param.Name)
+			errs = append(errs, spec.validateParam(param, value, value != "")...)
+		case "header":
+			value := r.Header.Get(param.Name)
+			errs = append(errs, spec.validateParam(param, value, value != "")...)
+		}
+	}
+
+	if op.RequestBody == nil {
+		return errs, nil
+	}
+
+	media, ok := op.RequestBody.Content["application/json"]
+	if !ok {
+		return errs, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			errs = append(errs, ErrorReport{Field: "body", ErrorString: "Missing request body"})
+		}
+		return errs, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return append(errs, ErrorReport{Field: "body", ErrorString: "Malformed JSON: " + err.Error()}), nil
+	}
+	if decoder.More() {
+		return append(errs, ErrorReport{Field: "body", ErrorString: "Unexpected data after JSON value"}), nil
+	}
+
+	return append(errs, spec.validateValue("body", value, media.Schema)...), nil
+}
+
+func SendValidationErrors(w http.ResponseWriter, errs []ErrorReport) {
+	w.Header().Set("Content-Type", "application/json")
+	report := ErrorReport{ErrorString: "Request validation failed", Details: errs}
+	if json, errE := json.Marshal(report); errE == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(json)
+		return
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+}
+
+// Methods of the path as the Allow header of a 405 lists them
+func (spec *ApiSpec) allowedMethods(path string) string {
+	var methods []string
+	for method := range spec.Paths[path] {
+		methods = append(methods, strings.ToUpper(method))
+	}
+	slices.Sort(methods)
+	return strings.Join(methods, ", ")
+}
+
+func ValidationMiddleware(spec *ApiSpec, path string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, ok := spec.Paths[path][strings.ToLower(r.Method)]
+		if !ok {
+			w.Header().Set("Allow", spec.allowedMethods(path))
+			SendError(w, errors.New("Method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		errs, err := spec.validateRequest(op, r)
+		if err != nil {
+			SendError(w, err, 400)
+			return
+		}
+
+		if len(errs) != 0 {
+			SendValidationErrors(w, errs)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func OpenApiHandler(spec *ApiSpec) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if json, errE := json.Marshal(spec); errE == nil {
+			w.Write(json)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func newValidationTestMux(spec *ApiSpec, served *int) *http.ServeMux {
+	stub := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*served++
+		SendResult(w, "Success")
+	})
+
+	mux := http.NewServeMux()
+	for _, path := range []string{"/create_event", "/events/{id}/restore", "/views/agenda", "/create_booking_page", "/blobs/{id}"} {
+		mux.Handle(path, ValidationMiddleware(spec, path, stub))
+	}
+	return mux
+}
+
+func TestValidationMiddleware(t *testing.T) {
+	blobId := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		fields []string
+	}{
+		{name: "valid event", method: "POST", target: "/create_event", body: `{"user_id": 0, "event_title": "Standup", "event_time": "2026-03-10T09:30:00Z", "tags": ["team"]}`},
+		{name: "missing title", method: "POST", target: "/create_event", body: `{"user_id": 0, "event_time": "2026-03-10T09:30:00Z"}`, fields: []string{"body.event_title"}},
+		{name: "wrong types", method: "POST", target: "/create_event", body: `{"user_id": "0", "event_title": "Standup", "event_time": "10:30", "priority": 10}`, fields: []string{"body.event_time", "body.priority", "body.user_id"}},
+		{name: "unknown field", method: "POST", target: "/create_event", body: `{"user_id": 0, "event_title": "Standup", "event_time": "2026-03-10T09:30:00Z", "color": "red"}`, fields: []string{"body.color"}},
+		{name: "empty tag", method: "POST", target: "/create_event", body: `{"user_id": 0, "event_title": "Standup", "event_time": "2026-03-10T09:30:00Z", "tags": [""]}`, fields: []string{"body.tags[0]"}},
+		{name: "no body", method: "POST", target: "/create_event", fields: []string{"body"}},
+		{name: "malformed body", method: "POST", target: "/create_event", body: `{"user_id": 0,`, fields: []string{"body"}},
+		{name: "data after the body", method: "POST", target: "/create_event", body: `{} {}`, fields: []string{"body"}},
+		{name: "valid path parameter", method: "POST", target: "/events/3/restore", body: `{"user_id": 0, "version": 1}`},
+		{name: "invalid path parameter", method: "POST", target: "/events/x/restore", body: `{"user_id": 0, "version": 1}`, fields: []string{"path.id"}},
+		{name: "valid query", method: "GET", target: "/views/agenda?user_id=0&date=2026-03-10&range=month"},
+		{name: "invalid query", method: "GET", target: "/views/agenda?user_id=-1&date=10.03.2026&range=year", fields: []string{"query.user_id", "query.date", "query.range"}},
+		{name: "missing query", method: "GET", target: "/views/agenda", fields: []string{"query.user_id", "query.date"}},
+		{name: "pattern", method: "POST", target: "/create_booking_page", body: `{"user_id": 0, "title": "Office hours", "duration_minutes": 30, "horizon_days": 7, "working_hours": [{"weekday": 1, "start": "10:00", "end": "9:00"}]}`, fields: []string{"body.working_hours[0].end"}},
+		{name: "blob id", method: "GET", target: "/blobs/" + blobId},
+		{name: "invalid blob id", method: "GET", target: "/blobs/" + strings.ToUpper(blobId), fields: []string{"path.id"}},
+	}
+
+	spec := newApiSpec()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var served int
+			w := httptest.NewRecorder()
+			newValidationTestMux(spec, &served).ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+
+			if len(tt.fields) == 0 {
+				if w.Code != http.StatusOK || served != 1 {
+					t.Errorf("Status = %v, served %v times, want the request passed on: %v", w.Code, served, w.Body)
+				}
+				return
+			}
+
+			if w.Code != http.StatusBadRequest || served != 0 {
+				t.Fatalf("Status = %v, served %v times, want 400: %v", w.Code, served, w.Body)
+			}
+
+			var report ErrorReport
+			if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+
+			var fields []string
+			for _, detail := range report.Details {
+				fields = append(fields, detail.Field)
+			}
+			slices.Sort(fields)
+			want := slices.Sorted(slices.Values(tt.fields))
+			if !slices.Equal(fields, want) {
+				t.Errorf("Invalid fields = %v, want %v: %v", fields, want, w.Body)
+			}
+		})
+	}
+}
+
+func TestValidationMiddlewareMethodNotAllowed(t *testing.T) {
+	spec := newApiSpec()
+
+	var served int
+	mux := newValidationTestMux(spec, &served)
+
+	tests := []struct {
+		method string
+		target string
+		allow  string
+	}{
+		{method: "GET", target: "/create_event", allow: "POST"},
+		{method: "DELETE", target: "/views/agenda?user_id=0&date=2026-03-10", allow: "GET"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+
+		if w.Code != http.StatusMethodNotAllowed || served != 0 {
+			t.Errorf("%v %v: status %v, served %v times, want 405", tt.method, tt.target, w.Code, served)
+		}
+		if allow := w.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%v %v: Allow = %q, want %q", tt.method, tt.target, allow, tt.allow)
+		}
+	}
+
+	several := &ApiSpec{Paths: map[string]map[string]*Operation{"/items": {"put": {}, "get": {}, "delete": {}}}}
+	if allow := several.allowedMethods("/items"); allow != "DELETE, GET, PUT" {
+		t.Errorf("Allow of several methods = %q, want them sorted", allow)
+	}
+}
+
+func TestSpecPatternsCompiled(t *testing.T) {
+	spec := newApiSpec()
+
+	var check func(field string, schema *Schema)
+	check = func(field string, schema *Schema) {
+		if schema == nil {
+			return
+		}
+		if schema.Pattern != "" && (schema.pattern == nil || schema.pattern.String() != schema.Pattern) {
+			t.Errorf("Pattern of %v isn't compiled", field)
+		}
+		for name, prop := range schema.Properties {
+			check(field+"."+name, prop)
+		}
+		check(field+"[]", schema.Items)
+	}
+
+	for path, ops := range spec.Paths {
+		for method, op := range ops {
+			for _, param := range op.Parameters {
+				check(method+" "+path+" "+param.Name, param.Schema)
+			}
+			if op.RequestBody != nil {
+				for _, media := range op.RequestBody.Content {
+					check(method+" "+path+" body", media.Schema)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Overlays are read-only calendars of whole days, e.g. public holidays of a country.
+// They are loaded once from OverlayDir, a file per overlay named after it:
+//
+//	ru-holidays.json  {"title": "...", "days": [{"date": "2025-01-01", "name": "New Year"}, ...]}
+//	de-holidays.ics   VEVENTs with DATE values, CATEGORIES:WORKING-DAY marks working days
+//
+// A day marked as working is a working day moved onto a weekend, as some countries do.
+
+var ErrNoSuchOverlay = errors.New("No such overlay")
+
+const icsWorkingDayCategory = "WORKING-DAY"
+
+type OverlayDay struct {
+	Date    string `json:"date"`
+	Name    string `json:"name"`
+	Working bool   `json:"working,omitempty"`
+	Overlay string `json:"overlay"`
+}
+
+type Overlay struct {
+	Name  string        `json:"name"`
+	Title string        `json:"title,omitempty"`
+	Days  []*OverlayDay `json:"days,omitempty"`
+}
+
+type OverlayStore struct {
+	overlays map[string]*Overlay
+}
+
+// Days of the overlays a user is subscribed to by date
+type workingCalendar map[string][]*OverlayDay
+
+func LoadOverlays(dir string) (*OverlayStore, error) {
+	store := &OverlayStore{overlays: make(map[string]*Overlay)}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".json" && ext != ".ics") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		overlay := &Overlay{Name: strings.TrimSuffix(entry.Name(), ext)}
+		if ext == ".json" {
+			err = overlay.parseJson(data)
+		} else {
+			err = overlay.parseIcs(string(data))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Overlay %v: %w", entry.Name(), err)
+		}
+
+		slices.SortFunc(overlay.Days, func(a, b *OverlayDay) int {
+			return strings.Compare(a.Date, b.Date)
+		})
+		store.overlays[overlay.Name] = overlay
+	}
+
+	return store, nil
+}
+
+func (o *Overlay) addDay(date time.Time, name string, working bool) {
+	o.Days = append(o.Days, &OverlayDay{
+		Date:    date.Format(time.DateOnly),
+		Name:    name,
+		Working: working,
+		Overlay: o.Name,
+	})
+}
+
+func (o *Overlay) parseJson(data []byte) error {
+	file := &Overlay{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return err
+	}
+
+	o.Title = file.Title
+	for _, day := range file.Days {
+		date, err := time.Parse(time.DateOnly, day.Date)
+		if err != nil {
+			return err
+		}
+		o.addDay(date, day.Name, day.Working)
+	}
+
+	return nil
+}
+
+// Every day from DTSTART up to the exclusive DTEND is added
+func (o *Overlay) parseIcs(data string) error {
+	root, err := parseIcs(data)
+	if err != nil {
+		return err
+	}
+
+	for _, calendar := range root.find("VCALENDAR") {
+		if name := calendar.get("X-WR-CALNAME"); name != nil {
+			o.Title = unescapeIcsText(name.Value)
+		}
+	}
+
+	for _, vevent := range root.find("VEVENT") {
+		dtstart := vevent.get("DTSTART")
+		if dtstart == nil {
+			return errors.New("Missing DTSTART")
+		}
+
+		start, err := parseIcsTime(dtstart)
+		if err != nil {
+			return err
+		}
+		start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+
+		end := start.AddDate(0, 0, 1)
+		if dtend := vevent.get("DTEND"); dtend != nil {
+			if end, err = parseIcsTime(dtend); err != nil {
+				return err
+			}
+		}
+
+		name := ""
+		if summary := vevent.get("SUMMARY"); summary != nil {
+			name = unescapeIcsText(summary.Value)
+		}
+
+		working := false
+		for _, prop := range vevent.Properties {
+			if prop.Name == "CATEGORIES" && slices.ContainsFunc(splitIcsList(prop.Value), func(category string) bool {
+				return strings.EqualFold(category, icsWorkingDayCategory)
+			}) {
+				working = true
+			}
+		}
+
+		for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+			o.addDay(day, name, working)
+		}
+	}
+
+	return nil
+}
+
+func (s *OverlayStore) get(name string) (*Overlay, error) {
+	if overlay, ok := s.overlays[name]; ok {
+		return overlay, nil
+	}
+	return nil, ErrNoSuchOverlay
+}
+
+func (s *OverlayStore) list() []*Overlay {
+	var overlays []*Overlay
+	for _, overlay := range s.overlays {
+		overlays = append(overlays, &Overlay{Name: overlay.Name, Title: overlay.Title})
+	}
+	return overlays
+}
+
+// Overlays that are no longer present in OverlayDir are skipped
+func (s *OverlayStore) calendar(names []string) workingCalendar {
+	calendar := make(workingCalendar)
+	for _, name := range names {
+		if overlay, ok := s.overlays[name]; ok {
+			for _, day := range overlay.Days {
+				calendar[day.Date] = append(calendar[day.Date], day)
+			}
+		}
+	}
+	return calendar
+}
+
+// Overlay days from start up to the exclusive end, dates are taken in the location of start
+func (c workingCalendar) between(start time.Time, end time.Time) []*OverlayDay {
+	var days []*OverlayDay
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		days = append(days, c[day.Format(time.DateOnly)]...)
+	}
+	return days
+}
+
+func (c workingCalendar) isDayOff(date time.Time) bool {
+	days := c[date.Format(time.DateOnly)]
+	return len(days) != 0 && !slices.ContainsFunc(days, func(day *OverlayDay) bool { return day.Working })
+}
+
+func (c workingCalendar) isMovedWorkday(date time.Time) bool {
+	return slices.ContainsFunc(c[date.Format(time.DateOnly)], func(day *OverlayDay) bool { return day.Working })
+}
+
+func userCalendar(userIdx int, userStore *Store[User], overlays *OverlayStore) (workingCalendar, error) {
+	user, err := userStore.get(userIdx)
+	if err != nil {
+		return nil, err
+	}
+
+	return overlays.calendar(user.Overlays), nil
+}
+
+// POST /overlays/{name}/subscribe
+func subscribeOverlay(userIdx int, name string, subscribe bool, actor string, userStore *Store[User], overlays *OverlayStore) ([]string, error) {
+	if _, err := overlays.get(name); err != nil && subscribe {
+		return nil, err
+	}
+
+	// The list is changed under the store lock, so concurrent subscriptions don't overwrite each other
+	var subscribed []string
+	_, err := userStore.updateFunc(userIdx, actor, func(user *User, _ int) (*User, error) {
+		user.Overlays = slices.DeleteFunc(slices.Clone(user.Overlays), func(overlay string) bool {
+			return overlay == name
+		})
+		if subscribe {
+			user.Overlays = append(user.Overlays, name)
+		}
+		subscribed = user.Overlays
+		return user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subscribed, nil
+}
+
+func OverlayWrapper(fn func(http.ResponseWriter, *http.Request, *Store[User], *OverlayStore), userStore *Store[User], overlays *OverlayStore) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fn(w, r, userStore, overlays)
+	}
+}
+
+// GET /overlays
+func HandleOverlays(w http.ResponseWriter, r *http.Request, userStore *Store[User], overlays *OverlayStore) {
+	SendPage(w, r, overlays.list(), overlayOrder)
+}
+
+// GET /overlays/{name}
+func HandleOverlay(w http.ResponseWriter, r *http.Request, userStore *Store[User], overlays *OverlayStore) {
+	overlay, err := overlays.get(r.PathValue("name"))
+	if err != nil {
+		SendError(w, err, 404)
+		return
+	}
+
+	SendResult(w, overlay)
+}
+
+func handleSubscription(w http.ResponseWriter, r *http.Request, userStore *Store[User], overlays *OverlayStore, subscribe bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	userIdx, err := parseUserIdx(body)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	subscribed, err := subscribeOverlay(userIdx, r.PathValue("name"), subscribe, getActor(r, userIdx), userStore, overlays)
+	if err != nil {
+		SendError(w, err, 404)
+		return
+	}
+
+	SendResult(w, subscribed)
+}
+
+func HandleSubscribeOverlay(w http.ResponseWriter, r *http.Request, userStore *Store[User], overlays *OverlayStore) {
+	handleSubscription(w, r, userStore, overlays, true)
+}
+
+// POST /overlays/{name}/unsubscribe
+func HandleUnsubscribeOverlay(w http.ResponseWriter, r *http.Request, userStore *Store[User], overlays *OverlayStore) {
+	handleSubscription(w, r, userStore, overlays, false)
+}
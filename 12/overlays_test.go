@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSubscribeOverlayConcurrently(t *testing.T) {
+	userStore := NewStore(func(u *User, id int) { u.Id = id })
+	userIdx := createUser("anna", "", "test", userStore)
+
+	overlays := &OverlayStore{overlays: make(map[string]*Overlay)}
+	var names []string
+	for i := range 50 {
+		name := fmt.Sprintf("overlay-%v", i)
+		overlays.overlays[name] = &Overlay{Name: name}
+		names = append(names, name)
+	}
+
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Go(func() {
+			if _, err := subscribeOverlay(userIdx, name, true, "test", userStore, overlays); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+
+	user, _ := userStore.get(userIdx)
+	if got := slices.Sorted(slices.Values(user.Overlays)); !slices.Equal(got, slices.Sorted(slices.Values(names))) {
+		t.Fatalf("Subscribed to %v overlays, want %v: %v", len(got), len(names), got)
+	}
+
+	for _, name := range names[:25] {
+		wg.Go(func() {
+			if _, err := subscribeOverlay(userIdx, name, false, "test", userStore, overlays); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+
+	user, _ = userStore.get(userIdx)
+	if got := slices.Sorted(slices.Values(user.Overlays)); !slices.Equal(got, slices.Sorted(slices.Values(names[25:]))) {
+		t.Errorf("Subscribed to %v after unsubscribing, want %v", got, names[25:])
+	}
+}
+
+func TestRuHolidays2026(t *testing.T) {
+	overlays, err := LoadOverlays("overlays")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := overlays.get("ru-holidays-2026"); err != nil {
+		t.Fatal(err)
+	}
+	calendar := overlays.calendar([]string{"ru-holidays-2026"})
+
+	for _, date := range []string{"2026-01-09", "2026-03-09", "2026-05-11", "2026-12-31"} {
+		day, _ := time.Parse(time.DateOnly, date)
+		if !calendar.isDayOff(day) {
+			t.Errorf("%v is a working day, want the day off moved onto it", date)
+		}
+	}
+
+	for _, date := range []string{"2026-01-12", "2026-05-12", "2026-12-30"} {
+		day, _ := time.Parse(time.DateOnly, date)
+		if calendar.isDayOff(day) {
+			t.Errorf("%v is a day off, want a working day", date)
+		}
+	}
+}
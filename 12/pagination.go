@@ -0,0 +1,236 @@
+package main
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxPageLimit = 1000
+
+type PageInfo struct {
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type pageQuery struct {
+	limit  int
+	cursor string
+	sort   string
+	desc   bool
+}
+
+// Position after the last returned item, it is only valid for the same sort and order
+type pageCursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d,omitempty"`
+	Key  string `json:"k,omitempty"`
+	Id   int    `json:"i"`
+}
+
+// Sort keys of a list, every key is a string compared lexicographically.
+// Items with equal keys are ordered by id, so the order is total and pages never overlap
+type listOrder[T interface{}] struct {
+	id          func(*T) int
+	keys        map[string]func(*T) string
+	defaultSort string
+}
+
+// Times are formatted with fixed width so that they compare as strings
+func timeKey(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000")
+}
+
+func noKey[T interface{}](*T) string {
+	return ""
+}
+
+var eventOrder = &listOrder[Event]{
+	id: func(e *Event) int { return e.Id },
+	keys: map[string]func(*Event) string{
+		"time":  func(e *Event) string { return timeKey(e.EventTime) },
+		"title": func(e *Event) string { return strings.ToLower(e.Title) },
+		"id":    noKey[Event],
+	},
+	defaultSort: "time",
+}
+
+// Overlay names are unique, so no id is needed to break ties
+var overlayOrder = &listOrder[Overlay]{
+	id: func(o *Overlay) int { return 0 },
+	keys: map[string]func(*Overlay) string{
+		"name": func(o *Overlay) string { return o.Name },
+	},
+	defaultSort: "name",
+}
+
+var bookingPageOrder = &listOrder[BookingPage]{
+	id: func(p *BookingPage) int { return p.Id },
+	keys: map[string]func(*BookingPage) string{
+		"title": func(p *BookingPage) string { return strings.ToLower(p.Title) },
+		"id":    noKey[BookingPage],
+	},
+	defaultSort: "id",
+}
+
+var revisionOrder = &listOrder[Revision[Event]]{
+	id: func(r *Revision[Event]) int { return r.Version },
+	keys: map[string]func(*Revision[Event]) string{
+		"version": noKey[Revision[Event]],
+	},
+	defaultSort: "version",
+}
+
+// Slots don't overlap, so their start identifies them
+var slotOrder = &listOrder[Slot]{
+	id: func(s *Slot) int { return int(s.Start.Unix()) },
+	keys: map[string]func(*Slot) string{
+		"time": noKey[Slot],
+	},
+	defaultSort: "time",
+}
+
+func (o *listOrder[T]) sortNames() []string {
+	var names []string
+	for name := range o.keys {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func parsePageQuery(r *http.Request) (*pageQuery, error) {
+	query := &pageQuery{
+		cursor: r.URL.Query().Get("cursor"),
+		sort:   r.URL.Query().Get("sort"),
+	}
+
+	if r.URL.Query().Has("limit") {
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return nil, fmt.Errorf("Limit must be between 1 and %v", maxPageLimit)
+		}
+		query.limit = limit
+	}
+
+	switch r.URL.Query().Get("order") {
+	case "", "asc":
+	case "desc":
+		query.desc = true
+	default:
+		return nil, errors.New("Order must be asc or desc")
+	}
+
+	return query, nil
+}
+
+func encodeCursor(cursor *pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, errors.New("Malformed cursor")
+	}
+
+	cursor := &pageCursor{}
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, errors.New("Malformed cursor")
+	}
+
+	return cursor, nil
+}
+
+// Sorts items and cuts the page described by query out of them
+func (o *listOrder[T]) paginate(items []*T, query *pageQuery) ([]*T, *PageInfo, error) {
+	sortName := query.sort
+	if sortName == "" {
+		sortName = o.defaultSort
+	}
+
+	key, ok := o.keys[sortName]
+	if !ok {
+		return nil, nil, fmt.Errorf("Unknown sort %v, expected one of %v", sortName, strings.Join(o.sortNames(), ", "))
+	}
+
+	compare := func(aKey string, aId int, bKey string, bId int) int {
+		res := cmp.Or(strings.Compare(aKey, bKey), cmp.Compare(aId, bId))
+		if query.desc {
+			return -res
+		}
+		return res
+	}
+
+	items = slices.Clone(items)
+	slices.SortFunc(items, func(a, b *T) int {
+		return compare(key(a), o.id(a), key(b), o.id(b))
+	})
+
+	info := &PageInfo{Total: len(items), Limit: query.limit}
+
+	if query.cursor != "" {
+		cursor, err := decodeCursor(query.cursor)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if cursor.Sort != sortName || cursor.Desc != query.desc {
+			return nil, nil, errors.New("Cursor belongs to a different sort order")
+		}
+
+		start := slices.IndexFunc(items, func(item *T) bool {
+			return compare(key(item), o.id(item), cursor.Key, cursor.Id) > 0
+		})
+		if start == -1 {
+			start = len(items)
+		}
+		items = items[start:]
+	}
+
+	if query.limit != 0 && len(items) > query.limit {
+		items = items[:query.limit]
+		last := items[len(items)-1]
+		info.NextCursor = encodeCursor(&pageCursor{Sort: sortName, Desc: query.desc, Key: key(last), Id: o.id(last)})
+	}
+
+	return items, info, nil
+}
+
+// Responds with one page of items, the page is selected by the limit, cursor, sort and order parameters
+func SendPage[T interface{}](w http.ResponseWriter, r *http.Request, items []*T, order *listOrder[T]) {
+	sendPage(w, r, items, order, &ResultReport{})
+}
+
+// Same as SendPage with the rest of the report filled in by the caller
+func sendPage[T interface{}](w http.ResponseWriter, r *http.Request, items []*T, order *listOrder[T], report *ResultReport) {
+	query, err := parsePageQuery(r)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	page, info, err := order.paginate(items, query)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	report.Result = page
+	report.Page = info
+	if json, errE := json.Marshal(report); errE == nil {
+		w.Write(json)
+		return
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Every sort has ties: titles compare without case and three times are shared
+func paginationTestEvents() []*Event {
+	at := func(hour int) time.Time { return time.Date(2026, 3, 10, hour, 0, 0, 0, time.UTC) }
+
+	return []*Event{
+		{Id: 3, Title: "b", EventTime: at(9)},
+		{Id: 0, Title: "A", EventTime: at(10)},
+		{Id: 7, Title: "c", EventTime: at(9)},
+		{Id: 1, Title: "a", EventTime: at(11)},
+		{Id: 5, Title: "B", EventTime: at(9)},
+		{Id: 2, Title: "b", EventTime: at(10)},
+		{Id: 9, Title: "a", EventTime: at(11)},
+		{Id: 4, Title: "c", EventTime: at(10)},
+		{Id: 8, Title: "B", EventTime: at(9)},
+		{Id: 6, Title: "a", EventTime: at(11)},
+	}
+}
+
+func eventIds(events []*Event) []int {
+	var ids []int
+	for _, event := range events {
+		ids = append(ids, event.Id)
+	}
+	return ids
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursors := []*pageCursor{
+		{Sort: "time", Key: timeKey(time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)), Id: 3},
+		{Sort: "title", Desc: true, Key: "lunch / dinner?", Id: 0},
+		{Sort: "id", Id: 42},
+	}
+
+	for _, cursor := range cursors {
+		raw := encodeCursor(cursor)
+		got, err := decodeCursor(raw)
+		if err != nil || *got != *cursor {
+			t.Errorf("decodeCursor(%q) = %+v, %v, want %+v", raw, got, err, cursor)
+		}
+	}
+
+	for _, raw := range []string{"!!!", base64.RawURLEncoding.EncodeToString([]byte("not json")), "eyJz"} {
+		if _, err := decodeCursor(raw); err == nil {
+			t.Errorf("decodeCursor(%q) succeeds", raw)
+		}
+	}
+}
+
+func TestPaginateOrder(t *testing.T) {
+	events := paginationTestEvents()
+
+	tests := []struct {
+		name  string
+		query pageQuery
+		want  []int
+	}{
+		{name: "default sort", want: []int{3, 5, 7, 8, 0, 2, 4, 1, 6, 9}},
+		{name: "time descending", query: pageQuery{sort: "time", desc: true}, want: []int{9, 6, 1, 4, 2, 0, 8, 7, 5, 3}},
+		{name: "title", query: pageQuery{sort: "title"}, want: []int{0, 1, 6, 9, 2, 3, 5, 8, 4, 7}},
+		{name: "id", query: pageQuery{sort: "id"}, want: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{name: "id descending", query: pageQuery{sort: "id", desc: true}, want: []int{9, 8, 7, 6, 5, 4, 3, 2, 1, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, info, err := eventOrder.paginate(events, &tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := eventIds(page); !slices.Equal(got, tt.want) {
+				t.Errorf("Ids = %v, want %v", got, tt.want)
+			}
+			if info.Total != len(events) || info.NextCursor != "" {
+				t.Errorf("Page info = %+v, want all events without a cursor", info)
+			}
+		})
+	}
+
+	if _, _, err := eventOrder.paginate(events, &pageQuery{sort: "priority"}); err == nil {
+		t.Error("Unknown sort succeeds")
+	}
+}
+
+func TestPaginateEqualKeys(t *testing.T) {
+	events := paginationTestEvents()
+
+	for _, sortName := range eventOrder.sortNames() {
+		for _, desc := range []bool{false, true} {
+			all, _, err := eventOrder.paginate(events, &pageQuery{sort: sortName, desc: desc})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for limit := 1; limit <= 4; limit++ {
+				query := &pageQuery{limit: limit, sort: sortName, desc: desc}
+
+				var got []*Event
+				for pages := 0; ; pages++ {
+					if pages > len(events) {
+						t.Fatalf("Paging by %v through %v desc %v doesn't end", limit, sortName, desc)
+					}
+
+					page, info, err := eventOrder.paginate(events, query)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if len(page) > limit || info.Total != len(events) {
+						t.Errorf("Page of %v events, total %v, limit %v", len(page), info.Total, limit)
+					}
+
+					got = append(got, page...)
+					if info.NextCursor == "" {
+						break
+					}
+					query.cursor = info.NextCursor
+				}
+
+				if !slices.Equal(eventIds(got), eventIds(all)) {
+					t.Errorf("Pages by %v sorted by %v desc %v = %v, want %v", limit, sortName, desc, eventIds(got), eventIds(all))
+				}
+			}
+		}
+	}
+}
+
+func TestPaginateCursors(t *testing.T) {
+	events := paginationTestEvents()
+
+	first, info, err := eventOrder.paginate(events, &pageQuery{limit: 4})
+	if err != nil || info.NextCursor == "" {
+		t.Fatalf("First page = %v, %+v, %v", eventIds(first), info, err)
+	}
+	cursor := info.NextCursor
+
+	invalid := []struct {
+		name  string
+		query pageQuery
+	}{
+		{name: "malformed", query: pageQuery{cursor: "%%%"}},
+		{name: "another sort", query: pageQuery{cursor: cursor, sort: "title"}},
+		{name: "another order", query: pageQuery{cursor: cursor, desc: true}},
+	}
+	for _, tt := range invalid {
+		if _, _, err := eventOrder.paginate(events, &tt.query); err == nil {
+			t.Errorf("Cursor of %v succeeds", tt.name)
+		}
+	}
+
+	// The last event of the page is deleted, the next page still starts right after it
+	last := first[len(first)-1]
+	remaining := slices.DeleteFunc(slices.Clone(events), func(e *Event) bool { return e == last })
+	page, _, err := eventOrder.paginate(remaining, &pageQuery{limit: 4, cursor: cursor})
+	if want := []int{0, 2, 4, 1}; err != nil || !slices.Equal(eventIds(page), want) {
+		t.Errorf("Page after a deleted event = %v, %v, want %v", eventIds(page), err, want)
+	}
+
+	// Events added before the cursor are skipped, the ones after it show up
+	added := append(slices.Clone(events),
+		&Event{Id: 10, EventTime: last.EventTime.Add(-time.Hour)},
+		&Event{Id: 11, EventTime: last.EventTime.Add(time.Hour)},
+	)
+	page, _, err = eventOrder.paginate(added, &pageQuery{cursor: cursor})
+	if want := []int{0, 2, 4, 11, 1, 6, 9}; err != nil || !slices.Equal(eventIds(page), want) {
+		t.Errorf("Page after added events = %v, %v, want %v", eventIds(page), err, want)
+	}
+
+	// A cursor past every event gives an empty page
+	end := encodeCursor(&pageCursor{Sort: "time", Key: timeKey(time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))})
+	page, info, err = eventOrder.paginate(events, &pageQuery{cursor: end})
+	if err != nil || len(page) != 0 || info.NextCursor != "" {
+		t.Errorf("Page past the end = %v, %+v, %v", eventIds(page), info, err)
+	}
+}
+
+func TestSendPage(t *testing.T) {
+	events := paginationTestEvents()
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		ids    []int
+	}{
+		{name: "limit", query: "?limit=3&sort=id&order=desc", status: 200, ids: []int{9, 8, 7}},
+		{name: "limit too small", query: "?limit=0", status: 400},
+		{name: "limit too large", query: "?limit=1001", status: 400},
+		{name: "limit not a number", query: "?limit=ten", status: 400},
+		{name: "unknown order", query: "?order=up", status: 400},
+		{name: "unknown sort", query: "?sort=priority", status: 400},
+		{name: "malformed cursor", query: "?cursor=%25%25", status: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			SendPage(w, httptest.NewRequest(http.MethodGet, "/events"+tt.query, nil), events, eventOrder)
+
+			if w.Code != tt.status {
+				t.Fatalf("Status = %v, want %v: %v", w.Code, tt.status, w.Body)
+			}
+			if tt.status != 200 {
+				return
+			}
+
+			var report struct {
+				Result []*Event  `json:"result"`
+				Page   *PageInfo `json:"page"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(eventIds(report.Result), tt.ids) {
+				t.Errorf("Ids = %v, want %v", eventIds(report.Result), tt.ids)
+			}
+			if report.Page.Total != len(events) || report.Page.Limit != 3 || report.Page.NextCursor == "" {
+				t.Errorf("Page info = %+v", report.Page)
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Minimal protocol buffers wire format support for the messages of calendar.proto
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errMalformedProto = errors.New("Malformed protobuf message")
+
+type protoEncoder struct {
+	buf []byte
+}
+
+func (e *protoEncoder) tag(field int, wireType int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field)<<3|uint64(wireType))
+}
+
+// Zero values are omitted as proto3 does for scalar fields
+func (e *protoEncoder) int64(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.buf = binary.AppendUvarint(e.buf, uint64(v))
+}
+
+func (e *protoEncoder) string(field int, v string) {
+	if v == "" {
+		return
+	}
+	e.bytes(field, []byte(v))
+}
+
+func (e *protoEncoder) repeatedString(field int, values []string) {
+	for _, v := range values {
+		e.bytes(field, []byte(v))
+	}
+}
+
+func (e *protoEncoder) bytes(field int, v []byte) {
+	e.tag(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *protoEncoder) message(field int, v []byte) {
+	e.bytes(field, v)
+}
+
+func (e *protoEncoder) timestamp(field int, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+
+	ts := protoEncoder{}
+	ts.int64(1, t.Unix())
+	ts.int64(2, int64(t.Nanosecond()))
+	e.message(field, ts.buf)
+}
+
+type protoField struct {
+	Number   int
+	WireType int
+	Varint   uint64
+	Bytes    []byte
+}
+
+func (f *protoField) int64() (int64, error) {
+	if f.WireType != wireVarint {
+		return 0, fmt.Errorf("Field %v must be a varint", f.Number)
+	}
+	return int64(f.Varint), nil
+}
+
+func (f *protoField) string() (string, error) {
+	if f.WireType != wireBytes {
+		return "", fmt.Errorf("Field %v must be length delimited", f.Number)
+	}
+	return string(f.Bytes), nil
+}
+
+func (f *protoField) timestamp() (time.Time, error) {
+	if f.WireType != wireBytes {
+		return time.Time{}, fmt.Errorf("Field %v must be a message", f.Number)
+	}
+
+	var seconds, nanos int64
+	err := decodeProto(f.Bytes, func(ts *protoField) error {
+		var err error
+		switch ts.Number {
+		case 1:
+			seconds, err = ts.int64()
+		case 2:
+			nanos, err = ts.int64()
+		}
+		return err
+	})
+
+	return time.Unix(seconds, nanos).UTC(), err
+}
+
+// Calls handle for every field of the message, unknown fields are expected to be skipped by it
+func decodeProto(data []byte, handle func(*protoField) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errMalformedProto
+		}
+		data = data[n:]
+
+		field := &protoField{Number: int(key >> 3), WireType: int(key & 7)}
+
+		switch field.WireType {
+		case wireVarint:
+			field.Varint, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errMalformedProto
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errMalformedProto
+			}
+			field.Varint = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errMalformedProto
+			}
+			field.Varint = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		case wireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return errMalformedProto
+			}
+			field.Bytes = data[n : n+int(size)]
+			data = data[n+int(size):]
+		default:
+			return errMalformedProto
+		}
+
+		if err := handle(field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func encodeAttachment(a *Attachment) []byte {
+	e := protoEncoder{}
+	e.string(1, a.Name)
+	e.string(2, a.MimeType)
+	e.int64(3, a.Size)
+	e.string(4, a.BlobId)
+	return e.buf
+}
+
+func decodeAttachment(data []byte) (*Attachment, error) {
+	a := &Attachment{}
+	err := decodeProto(data, func(f *protoField) error {
+		var err error
+		switch f.Number {
+		case 1:
+			a.Name, err = f.string()
+		case 2:
+			a.MimeType, err = f.string()
+		case 3:
+			a.Size, err = f.int64()
+		case 4:
+			a.BlobId, err = f.string()
+		}
+		return err
+	})
+	return a, err
+}
+
+func encodeEvent(ev *Event) []byte {
+	e := protoEncoder{}
+	e.int64(1, int64(ev.Id))
+	e.string(2, ev.Title)
+	e.timestamp(3, ev.EventTime)
+	e.int64(4, int64(ev.DurationMinutes))
+	e.string(5, ev.Description)
+	e.string(6, ev.Location)
+	e.repeatedString(7, ev.Tags)
+	e.int64(8, int64(ev.Priority))
+	e.string(9, ev.Url)
+	for _, a := range ev.Attachments {
+		e.message(10, encodeAttachment(a))
+	}
+	return e.buf
+}
+
+func decodeEvent(data []byte) (*Event, error) {
+	ev := &Event{}
+	err := decodeProto(data, func(f *protoField) error {
+		var err error
+		var num int64
+		var str string
+
+		switch f.Number {
+		case 1:
+			num, err = f.int64()
+			ev.Id = int(num)
+		case 2:
+			ev.Title, err = f.string()
+		case 3:
+			ev.EventTime, err = f.timestamp()
+		case 4:
+			num, err = f.int64()
+			ev.DurationMinutes = int(num)
+		case 5:
+			ev.Description, err = f.string()
+		case 6:
+			ev.Location, err = f.string()
+		case 7:
+			str, err = f.string()
+			ev.Tags = append(ev.Tags, str)
+		case 8:
+			num, err = f.int64()
+			ev.Priority = int(num)
+		case 9:
+			ev.Url, err = f.string()
+		case 10:
+			var a *Attachment
+			if a, err = decodeAttachment(f.Bytes); err == nil {
+				ev.Attachments = append(ev.Attachments, a)
+			}
+		}
+		return err
+	})
+	return ev, err
+}
+
+func encodeEventList(events []*Event) []byte {
+	e := protoEncoder{}
+	for _, ev := range events {
+		e.message(1, encodeEvent(ev))
+	}
+	return e.buf
+}
+
+var protoOperations = map[string]int64{
+	OpCreate:  1,
+	OpUpdate:  2,
+	OpDelete:  3,
+	OpRestore: 4,
+}
+
+func encodeEventChange(change *Change[Event]) []byte {
+	e := protoEncoder{}
+	e.int64(1, int64(change.Id))
+	e.int64(2, int64(change.Revision.Version))
+	e.string(3, change.Revision.Actor)
+	e.timestamp(4, change.Revision.Timestamp)
+	e.int64(5, protoOperations[change.Revision.Operation])
+	if change.Revision.Before != nil {
+		e.message(6, encodeEvent(change.Revision.Before))
+	}
+	if change.Revision.After != nil {
+		e.message(7, encodeEvent(change.Revision.After))
+	}
+	return e.buf
+}
+
+// Fields of the request messages, they only consist of ids, strings and an event
+type protoRequest struct {
+	UserId   int
+	EventId  int
+	Username string
+	Date     string
+	Tags     []string
+	Event    *Event
+}
+
+func decodeRequest(data []byte, fields map[int]string) (*protoRequest, error) {
+	req := &protoRequest{}
+	err := decodeProto(data, func(f *protoField) error {
+		var err error
+		var num int64
+		var str string
+
+		switch fields[f.Number] {
+		case "user_id":
+			num, err = f.int64()
+			req.UserId = int(num)
+		case "event_id":
+			num, err = f.int64()
+			req.EventId = int(num)
+		case "username":
+			req.Username, err = f.string()
+		case "date":
+			req.Date, err = f.string()
+		case "tags":
+			str, err = f.string()
+			req.Tags = append(req.Tags, str)
+		case "event":
+			if f.WireType != wireBytes {
+				return errMalformedProto
+			}
+			req.Event, err = decodeEvent(f.Bytes)
+		}
+		return err
+	})
+	return req, err
+}
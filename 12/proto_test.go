@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Wire encodings of calendar.proto messages made by google.golang.org/protobuf, the hand
+// written codec must produce and read the same bytes. Regenerate after changing the cases:
+//
+//go:generate go run -C testdata/protogolden . ../../calendar.proto ../proto_golden.json
+func loadProtoGolden(t *testing.T) map[string][]byte {
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/proto_golden.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cases []struct {
+		Name string `json:"name"`
+		Wire string `json:"wire"`
+	}
+	if err := json.Unmarshal(data, &cases); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := make(map[string][]byte)
+	for _, c := range cases {
+		if golden[c.Name], err = hex.DecodeString(c.Wire); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return golden
+}
+
+func goldenWire(t *testing.T, golden map[string][]byte, name string) []byte {
+	t.Helper()
+
+	wire, ok := golden[name]
+	if !ok {
+		t.Fatalf("No golden case %q", name)
+	}
+	return wire
+}
+
+func protoTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestProtoEventGolden(t *testing.T) {
+	golden := loadProtoGolden(t)
+
+	tests := []struct {
+		name  string
+		event *Event
+	}{
+		{name: "empty event", event: &Event{}},
+		{
+			name: "event",
+			event: &Event{
+				Id:              300,
+				Title:           "Обед с Анной",
+				EventTime:       protoTime("2026-03-10T12:30:00.250Z"),
+				DurationMinutes: 90,
+				Description:     "line\nbreak",
+				Location:        "Café",
+				Tags:            []string{"work", "", "lunch"},
+				Priority:        7,
+				Url:             "https://example.com/a?b=c",
+				Attachments: []*Attachment{
+					{Name: "menu.pdf", MimeType: "application/pdf", Size: 1 << 20, BlobId: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
+					{Name: "empty"},
+				},
+			},
+		},
+		{name: "event before 1970", event: &Event{Title: "Moon landing", EventTime: protoTime("1969-07-20T20:17:40Z")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wire := goldenWire(t, golden, tt.name)
+
+			if got := encodeEvent(tt.event); !bytes.Equal(got, wire) {
+				t.Errorf("encodeEvent() = %x, want %x", got, wire)
+			}
+
+			got, err := decodeEvent(wire)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.event) {
+				t.Errorf("decodeEvent() = %+v, want %+v", got, tt.event)
+			}
+		})
+	}
+}
+
+func TestProtoResponsesGolden(t *testing.T) {
+	golden := loadProtoGolden(t)
+
+	id := func(v int64) []byte {
+		e := protoEncoder{}
+		e.int64(1, v)
+		return e.buf
+	}
+
+	tests := []struct {
+		name string
+		got  []byte
+	}{
+		{
+			name: "event list",
+			got: encodeEventList([]*Event{
+				{Id: 1, Title: "a", EventTime: protoTime("2026-01-01T00:00:00Z")},
+				{Title: "b", EventTime: protoTime("2026-01-02T09:00:00Z"), DurationMinutes: 30},
+			}),
+		},
+		{name: "create user response", got: id(150)},
+		{name: "create event response", got: id(2)},
+		{
+			name: "create change",
+			got: encodeEventChange(&Change[Event]{Id: 5, Revision: &Revision[Event]{
+				Version:   1,
+				Actor:     "grpc:user:0",
+				Timestamp: protoTime("2026-03-10T08:00:00.000000001Z"),
+				Operation: OpCreate,
+				After:     &Event{Id: 5, Title: "Standup", EventTime: protoTime("2026-03-10T09:30:00Z")},
+			}}),
+		},
+		{
+			name: "update change",
+			got: encodeEventChange(&Change[Event]{Id: 5, Revision: &Revision[Event]{
+				Version:   2,
+				Actor:     "user:0",
+				Timestamp: protoTime("2026-03-10T08:05:00Z"),
+				Operation: OpUpdate,
+				Before:    &Event{Id: 5, Title: "Standup", EventTime: protoTime("2026-03-10T09:30:00Z")},
+				After:     &Event{Id: 5, Title: "Standup", EventTime: protoTime("2026-03-10T09:45:00Z")},
+			}}),
+		},
+		{
+			name: "delete change",
+			got: encodeEventChange(&Change[Event]{Id: 5, Revision: &Revision[Event]{
+				Version:   3,
+				Actor:     "user:0",
+				Timestamp: protoTime("2026-03-10T08:10:00Z"),
+				Operation: OpDelete,
+				Before:    &Event{Id: 5, Title: "Standup", EventTime: protoTime("2026-03-10T09:45:00Z")},
+			}}),
+		},
+	}
+
+	for _, tt := range tests {
+		if wire := goldenWire(t, golden, tt.name); !bytes.Equal(tt.got, wire) {
+			t.Errorf("%v: encoded %x, want %x", tt.name, tt.got, wire)
+		}
+	}
+}
+
+// Field numbers of the methods must match the request messages of calendar.proto
+func TestProtoRequestsGolden(t *testing.T) {
+	golden := loadProtoGolden(t)
+	methods := NewGrpcServer(NewStore(func(u *User, id int) { u.Id = id })).methods
+
+	tests := []struct {
+		name   string
+		method string
+		want   *protoRequest
+	}{
+		{name: "create user request", method: "CreateUser", want: &protoRequest{Username: "anna"}},
+		{
+			name:   "create event request",
+			method: "CreateEvent",
+			want: &protoRequest{UserId: 3, Event: &Event{
+				Title:           "Standup",
+				EventTime:       protoTime("2026-03-10T09:30:00Z"),
+				DurationMinutes: 15,
+				Tags:            []string{"team"},
+			}},
+		},
+		{
+			name:   "update event request",
+			method: "UpdateEvent",
+			want:   &protoRequest{UserId: 3, Event: &Event{Id: 4, Title: "Standup", EventTime: protoTime("2026-03-10T09:45:00Z")}},
+		},
+		{name: "delete event request", method: "DeleteEvent", want: &protoRequest{UserId: 3, EventId: 200}},
+		{name: "get event request", method: "GetEvent", want: &protoRequest{EventId: 1}},
+		{name: "range request", method: "EventsForWeek", want: &protoRequest{UserId: 1, Date: "2026-03-10", Tags: []string{"a", "b"}}},
+		{name: "watch events request", method: "WatchEvents", want: &protoRequest{UserId: 128}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeRequest(goldenWire(t, golden, tt.name), methods[tt.method].fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeRequest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeProtoMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "truncated varint", data: []byte{0x08, 0x80}},
+		{name: "truncated bytes", data: []byte{0x12, 0x05, 'a'}},
+		{name: "truncated fixed64", data: []byte{0x09, 1, 2, 3}},
+		{name: "group", data: []byte{0x0b}},
+		{name: "string as varint", data: []byte{0x10, 0x01}},
+	}
+
+	for _, tt := range tests {
+		if _, err := decodeEvent(tt.data); err == nil {
+			t.Errorf("%v: decodeEvent(%x) succeeded, want error", tt.name, tt.data)
+		}
+	}
+
+	// Unknown fields of any wire type are skipped
+	unknown := []byte{0xf8, 0x01, 0x05, 0xfa, 0x01, 0x01, 'x', 0xfd, 0x01, 1, 2, 3, 4, 0x12, 0x01, 'a'}
+	event, err := decodeEvent(unknown)
+	if err != nil || event.Title != "a" {
+		t.Errorf("decodeEvent(%x) = %+v, %v, want the title a", unknown, event, err)
+	}
+}
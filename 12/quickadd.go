@@ -0,0 +1,792 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Phrases are understood in English and Russian, e.g.
+// "Lunch with Anna tomorrow 13:00 for 1h" or "Планёрка по будням в 9:30 на 15 минут"
+
+const (
+	recurDaily    = "daily"
+	recurWeekdays = "weekdays"
+	recurWeekly   = "weekly"
+)
+
+const defaultQuickAddHorizon = 28
+
+type quickAddRequest struct {
+	UserId      int    `json:"user_id"`
+	Text        string `json:"text"`
+	TimeZone    string `json:"time_zone"`
+	Preview     bool   `json:"preview"`
+	HorizonDays int    `json:"horizon_days"`
+}
+
+type QuickAddResult struct {
+	Text       string   `json:"text"`
+	Summary    string   `json:"summary"`
+	TimeZone   string   `json:"time_zone"`
+	Recurrence string   `json:"recurrence,omitempty"`
+	Notes      []string `json:"notes,omitempty"`
+	Committed  bool     `json:"committed"`
+	Events     []*Event `json:"events"`
+}
+
+var weekdayWords = map[string]time.Weekday{
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"понедельник": time.Monday,
+	"вторник":     time.Tuesday,
+	"среда":       time.Wednesday, "среду": time.Wednesday,
+	"четверг": time.Thursday,
+	"пятница": time.Friday, "пятницу": time.Friday,
+	"суббота": time.Saturday, "субботу": time.Saturday,
+	"воскресенье": time.Sunday,
+}
+
+// "on mondays", "по понедельникам"
+var weekdayPluralWords = map[string]time.Weekday{
+	"mondays": time.Monday, "tuesdays": time.Tuesday, "wednesdays": time.Wednesday,
+	"thursdays": time.Thursday, "fridays": time.Friday, "saturdays": time.Saturday, "sundays": time.Sunday,
+	"понедельникам": time.Monday, "вторникам": time.Tuesday, "средам": time.Wednesday,
+	"четвергам": time.Thursday, "пятницам": time.Friday, "субботам": time.Saturday, "воскресеньям": time.Sunday,
+}
+
+var monthWords = map[string]time.Month{
+	"january": time.January, "jan": time.January, "января": time.January,
+	"february": time.February, "feb": time.February, "февраля": time.February,
+	"march": time.March, "mar": time.March, "марта": time.March,
+	"april": time.April, "apr": time.April, "апреля": time.April,
+	"may": time.May, "мая": time.May,
+	"june": time.June, "jun": time.June, "июня": time.June,
+	"july": time.July, "jul": time.July, "июля": time.July,
+	"august": time.August, "aug": time.August, "августа": time.August,
+	"september": time.September, "sep": time.September, "сентября": time.September,
+	"october": time.October, "oct": time.October, "октября": time.October,
+	"november": time.November, "nov": time.November, "ноября": time.November,
+	"december": time.December, "dec": time.December, "декабря": time.December,
+}
+
+var relativeDayWords = map[string]int{
+	"today": 0, "сегодня": 0,
+	"tomorrow": 1, "завтра": 1,
+	"послезавтра": 2,
+}
+
+var durationUnits = map[string]int{
+	"h": 60, "hr": 60, "hrs": 60, "hour": 60, "hours": 60,
+	"ч": 60, "час": 60, "часа": 60, "часов": 60,
+	"m": 1, "min": 1, "mins": 1, "minute": 1, "minutes": 1,
+	"м": 1, "мин": 1, "минута": 1, "минуту": 1, "минуты": 1, "минут": 1,
+}
+
+// Shifts of the hour given after the number, e.g. "7 pm" or "7 вечера"
+var meridiemWords = map[string]bool{
+	"am": false, "утра": false, "ночи": false,
+	"pm": true, "дня": true, "вечера": true,
+}
+
+var (
+	clockPattern        = regexp.MustCompile(`^(\d{1,2}):(\d{2})(am|pm)?$`)
+	hourPattern         = regexp.MustCompile(`^(\d{1,2})(am|pm)$`)
+	isoDatePattern      = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	dotDatePattern      = regexp.MustCompile(`^(\d{1,2})\.(\d{1,2})(?:\.(\d{2}|\d{4}))?$`)
+	dayNumberPattern    = regexp.MustCompile(`^(\d{1,2})(?:st|nd|rd|th|-?го|-?е)?$`)
+	compactDuration     = regexp.MustCompile(`^(\d+(?:[.,]\d+)?)([a-zа-я]+)$`)
+	compoundDuration    = regexp.MustCompile(`^(\d+)(?:h|ч)(\d+)(?:m|min|м|мин)?$`)
+	quickAddPunctuation = ",;!?"
+)
+
+type quickAddParser struct {
+	now   time.Time
+	words []string
+
+	date    time.Time
+	hasDate bool
+	hour    int
+	minute  int
+	hasTime bool
+
+	duration    int
+	hasDuration bool
+
+	recurrence string
+	weekday    time.Weekday
+	hasWeekday bool
+
+	title    []string
+	tags     []string
+	location string
+}
+
+func normalizeWord(word string) string {
+	word = strings.ToLower(strings.TrimRight(word, quickAddPunctuation+"."))
+	return strings.ReplaceAll(word, "ё", "е")
+}
+
+// Tries match on words and then on words without the leading connector if it is one of connectors
+func withConnector(words []string, connectors []string, match func([]string) int) int {
+	if n := match(words); n > 0 {
+		return n
+	}
+
+	if len(words) > 1 && slices.Contains(connectors, normalizeWord(words[0])) {
+		if n := match(words[1:]); n > 0 {
+			return n + 1
+		}
+	}
+
+	return 0
+}
+
+func parseNumber(word string) (float64, bool) {
+	num, err := strconv.ParseFloat(strings.Replace(word, ",", ".", 1), 64)
+	return num, err == nil && num >= 0
+}
+
+func (p *quickAddParser) today() time.Time {
+	year, month, day := p.now.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, p.now.Location())
+}
+
+func (p *quickAddParser) setDate(date time.Time) {
+	p.date = date
+	p.hasDate = true
+}
+
+func (p *quickAddParser) setTime(hour int, minute int) bool {
+	if hour > 23 || minute > 59 {
+		return false
+	}
+
+	p.hour = hour
+	p.minute = minute
+	p.hasTime = true
+	return true
+}
+
+// Upcoming day of the week, today counts unless strictlyAfter is set
+func (p *quickAddParser) nextWeekday(weekday time.Weekday, strictlyAfter bool) time.Time {
+	days := (int(weekday) - int(p.now.Weekday()) + 7) % 7
+	if days == 0 && strictlyAfter {
+		days = 7
+	}
+	return p.today().AddDate(0, 0, days)
+}
+
+// "every weekday", "daily", "каждый понедельник", "по будням"
+func (p *quickAddParser) matchRecurrence(words []string) int {
+	if p.recurrence != "" {
+		return 0
+	}
+
+	first := normalizeWord(words[0])
+	second := ""
+	if len(words) > 1 {
+		second = normalizeWord(words[1])
+	}
+
+	switch first {
+	case "daily", "everyday", "ежедневно":
+		p.recurrence = recurDaily
+		return 1
+	case "weekly", "еженедельно":
+		p.recurrence = recurWeekly
+		return 1
+	case "weekdays", "будням":
+		p.recurrence = recurWeekdays
+		return 1
+	}
+
+	if weekday, ok := weekdayPluralWords[first]; ok {
+		p.recurrence = recurWeekly
+		p.weekday, p.hasWeekday = weekday, true
+		return 1
+	}
+
+	if first != "every" && first != "каждый" && first != "каждую" && first != "каждое" {
+		return 0
+	}
+
+	switch second {
+	case "day", "день":
+		p.recurrence = recurDaily
+		return 2
+	case "week", "неделю":
+		p.recurrence = recurWeekly
+		return 2
+	case "weekday", "workday":
+		p.recurrence = recurWeekdays
+		return 2
+	case "будний", "рабочий":
+		if len(words) > 2 && normalizeWord(words[2]) == "день" {
+			p.recurrence = recurWeekdays
+			return 3
+		}
+	}
+
+	if weekday, ok := weekdayWords[second]; ok {
+		p.recurrence = recurWeekly
+		p.weekday, p.hasWeekday = weekday, true
+		return 2
+	}
+
+	return 0
+}
+
+// "tomorrow", "next friday", "в среду", "2024-06-01", "05.06", "june 5", "5 июня"
+func (p *quickAddParser) matchDate(words []string) int {
+	if p.hasDate {
+		return 0
+	}
+
+	first := normalizeWord(words[0])
+	second := ""
+	if len(words) > 1 {
+		second = normalizeWord(words[1])
+	}
+
+	if days, ok := relativeDayWords[first]; ok {
+		p.setDate(p.today().AddDate(0, 0, days))
+		return 1
+	}
+
+	if first == "day" && len(words) > 2 && second == "after" && normalizeWord(words[2]) == "tomorrow" {
+		p.setDate(p.today().AddDate(0, 0, 2))
+		return 3
+	}
+
+	if weekday, ok := weekdayWords[first]; ok {
+		p.setDate(p.nextWeekday(weekday, false))
+		return 1
+	}
+
+	if first == "next" || strings.HasPrefix(first, "следующ") {
+		if weekday, ok := weekdayWords[second]; ok {
+			p.setDate(p.nextWeekday(weekday, true))
+			return 2
+		}
+		return 0
+	}
+
+	if isoDatePattern.MatchString(first) {
+		date, err := time.ParseInLocation(time.DateOnly, first, p.now.Location())
+		if err != nil {
+			return 0
+		}
+		p.setDate(date)
+		return 1
+	}
+
+	if match := dotDatePattern.FindStringSubmatch(first); match != nil {
+		day, _ := strconv.Atoi(match[1])
+		month, _ := strconv.Atoi(match[2])
+		year := -1
+		if match[3] != "" {
+			year, _ = strconv.Atoi(match[3])
+			if year < 100 {
+				year += 2000
+			}
+		}
+		if !p.setDayOfMonth(year, time.Month(month), day) {
+			return 0
+		}
+		return 1
+	}
+
+	// "june 5 2025", "5th of june", "5 июня 2025"
+	if month, ok := monthWords[first]; ok {
+		if match := dayNumberPattern.FindStringSubmatch(second); match != nil {
+			day, _ := strconv.Atoi(match[1])
+			return p.matchMonthDay(words, month, day, 2)
+		}
+		return 0
+	}
+
+	if match := dayNumberPattern.FindStringSubmatch(first); match != nil {
+		monthIdx := 1
+		if second == "of" {
+			monthIdx = 2
+		}
+		if len(words) > monthIdx {
+			if month, ok := monthWords[normalizeWord(words[monthIdx])]; ok {
+				day, _ := strconv.Atoi(match[1])
+				return p.matchMonthDay(words, month, day, monthIdx+1)
+			}
+		}
+	}
+
+	return 0
+}
+
+// Explicit four digit year at idx, -1 if there is none
+func (p *quickAddParser) yearAt(words []string, idx int) int {
+	if len(words) <= idx {
+		return -1
+	}
+
+	word := normalizeWord(words[idx])
+	if len(word) != 4 {
+		return -1
+	}
+
+	year, err := strconv.Atoi(word)
+	if err != nil {
+		return -1
+	}
+	return year
+}
+
+// Without a year the nearest upcoming date is taken
+func (p *quickAddParser) setDayOfMonth(year int, month time.Month, day int) bool {
+	if month < time.January || month > time.December || day < 1 {
+		return false
+	}
+
+	explicitYear := year != -1
+	if !explicitYear {
+		year = p.now.Year()
+	}
+
+	date := time.Date(year, month, day, 0, 0, 0, 0, p.now.Location())
+	if date.Day() != day {
+		return false
+	}
+
+	if !explicitYear && date.Before(p.today()) {
+		date = date.AddDate(1, 0, 0)
+	}
+
+	p.setDate(date)
+	return true
+}
+
+// Words taken by a date written with a month name, the year after it is optional
+func (p *quickAddParser) matchMonthDay(words []string, month time.Month, day int, consumed int) int {
+	year := p.yearAt(words, consumed)
+	if !p.setDayOfMonth(year, month, day) {
+		return 0
+	}
+
+	if year != -1 {
+		consumed++
+	}
+	return consumed
+}
+
+// "13:00", "9:30pm", "7pm", "7 pm", "noon", "at 9", "в 7 вечера"
+func (p *quickAddParser) matchTime(words []string) int {
+	if p.hasTime {
+		return 0
+	}
+
+	if n := p.matchClock(words, false); n > 0 {
+		return n
+	}
+
+	if len(words) > 1 {
+		switch normalizeWord(words[0]) {
+		case "at", "в", "во", "около", "around":
+			if n := p.matchClock(words[1:], true); n > 0 {
+				return n + 1
+			}
+		}
+	}
+
+	return 0
+}
+
+func (p *quickAddParser) matchClock(words []string, allowBareHour bool) int {
+	first := normalizeWord(words[0])
+
+	switch first {
+	case "noon", "полдень":
+		p.setTime(12, 0)
+		return 1
+	case "midnight", "полночь":
+		p.setTime(0, 0)
+		return 1
+	}
+
+	hour, minute := -1, 0
+	suffix := ""
+
+	if match := clockPattern.FindStringSubmatch(first); match != nil {
+		hour, _ = strconv.Atoi(match[1])
+		minute, _ = strconv.Atoi(match[2])
+		suffix = match[3]
+	} else if match := hourPattern.FindStringSubmatch(first); match != nil {
+		hour, _ = strconv.Atoi(match[1])
+		suffix = match[2]
+	} else if num, err := strconv.Atoi(first); err == nil && len(first) <= 2 {
+		hour = num
+	} else {
+		return 0
+	}
+
+	consumed := 1
+	if suffix == "" && len(words) > 1 {
+		if _, ok := meridiemWords[normalizeWord(words[1])]; ok {
+			suffix = normalizeWord(words[1])
+			consumed = 2
+		}
+	}
+
+	// A lonely number is an hour only right after "at" or "в"
+	if suffix == "" && !strings.Contains(first, ":") && !allowBareHour {
+		return 0
+	}
+
+	if suffix != "" {
+		if hour > 12 {
+			return 0
+		}
+		if meridiemWords[suffix] && hour < 12 {
+			hour += 12
+		} else if !meridiemWords[suffix] && hour == 12 {
+			hour = 0
+		}
+	}
+
+	if !p.setTime(hour, minute) {
+		return 0
+	}
+	return consumed
+}
+
+// Length of a duration in minutes: "1h", "1h30m", "90min", "2 hours", "an hour", "полчаса"
+func parseDurationWords(words []string) (int, int) {
+	first := normalizeWord(words[0])
+	second := ""
+	if len(words) > 1 {
+		second = normalizeWord(words[1])
+	}
+
+	switch first {
+	case "полчаса":
+		return 30, 1
+	case "час", "hour":
+		return 60, 1
+	case "полтора", "полторы":
+		if second == "часа" {
+			return 90, 2
+		}
+	case "an", "one", "один":
+		if durationUnits[second] == 60 {
+			return 60, 2
+		}
+	case "half":
+		if second == "an" && len(words) > 2 && normalizeWord(words[2]) == "hour" {
+			return 30, 3
+		}
+	}
+
+	if match := compoundDuration.FindStringSubmatch(first); match != nil {
+		hours, _ := strconv.Atoi(match[1])
+		minutes, _ := strconv.Atoi(match[2])
+		return hours*60 + minutes, 1
+	}
+
+	if match := compactDuration.FindStringSubmatch(first); match != nil {
+		if unit, ok := durationUnits[match[2]]; ok {
+			num, _ := parseNumber(match[1])
+			return int(num * float64(unit)), 1
+		}
+	}
+
+	if num, ok := parseNumber(first); ok {
+		if unit, ok := durationUnits[second]; ok {
+			return int(num * float64(unit)), 2
+		}
+	}
+
+	return 0, 0
+}
+
+// "for 1h", "на 30 минут"
+func (p *quickAddParser) matchDuration(words []string) int {
+	if p.hasDuration {
+		return 0
+	}
+
+	return withConnector(words, []string{"for", "на"}, func(words []string) int {
+		minutes, n := parseDurationWords(words)
+		if n == 0 || minutes <= 0 {
+			return 0
+		}
+		p.duration = minutes
+		p.hasDuration = true
+		return n
+	})
+}
+
+// "in 2 hours", "через 15 минут"
+func (p *quickAddParser) matchOffset(words []string) int {
+	if p.hasDate || p.hasTime || len(words) < 2 {
+		return 0
+	}
+
+	first := normalizeWord(words[0])
+	if first != "in" && first != "через" {
+		return 0
+	}
+
+	minutes, n := parseDurationWords(words[1:])
+	if n == 0 {
+		return 0
+	}
+
+	at := p.now.Add(time.Duration(minutes) * time.Minute)
+	year, month, day := at.Date()
+	p.setDate(time.Date(year, month, day, 0, 0, 0, 0, at.Location()))
+	p.setTime(at.Hour(), at.Minute())
+	return n + 1
+}
+
+// "#work" adds a tag, "@office" sets the location
+func (p *quickAddParser) matchMarker(words []string) int {
+	word := strings.TrimRight(words[0], quickAddPunctuation)
+	if len(word) < 2 {
+		return 0
+	}
+
+	switch word[0] {
+	case '#':
+		p.tags = append(p.tags, word[1:])
+		return 1
+	case '@':
+		if p.location != "" {
+			return 0
+		}
+		p.location = strings.ReplaceAll(word[1:], "_", " ")
+		return 1
+	}
+
+	return 0
+}
+
+func (p *quickAddParser) parse(text string) {
+	p.words = strings.Fields(text)
+	matchers := []func([]string) int{
+		p.matchMarker,
+		func(words []string) int { return withConnector(words, []string{"on", "по"}, p.matchRecurrence) },
+		p.matchOffset,
+		func(words []string) int {
+			return withConnector(words, []string{"on", "в", "во", "на"}, p.matchDate)
+		},
+		p.matchTime,
+		p.matchDuration,
+	}
+
+	for idx := 0; idx < len(p.words); {
+		consumed := 0
+		for _, match := range matchers {
+			if consumed = match(p.words[idx:]); consumed > 0 {
+				break
+			}
+		}
+
+		if consumed == 0 {
+			p.title = append(p.title, strings.TrimRight(p.words[idx], quickAddPunctuation))
+			consumed = 1
+		}
+		idx += consumed
+	}
+}
+
+func (p *quickAddParser) occursOn(day time.Time) bool {
+	switch p.recurrence {
+	case recurWeekdays:
+		return day.Weekday() != time.Saturday && day.Weekday() != time.Sunday
+	case recurWeekly:
+		return day.Weekday() == p.weekday
+	}
+	return true
+}
+
+func (p *quickAddParser) at(day time.Time) time.Time {
+	year, month, date := day.Date()
+	return time.Date(year, month, date, p.hour, p.minute, 0, 0, p.now.Location())
+}
+
+func (p *quickAddParser) describeRecurrence() string {
+	switch p.recurrence {
+	case recurDaily:
+		return "every day"
+	case recurWeekdays:
+		return "every weekday"
+	case recurWeekly:
+		return "every " + p.weekday.String()
+	}
+	return ""
+}
+
+// Turns the parsed phrase into events, recurring ones are expanded over horizonDays
+func (p *quickAddParser) events(horizonDays int) ([]*Event, []string, error) {
+	var notes []string
+
+	if len(p.title) == 0 {
+		return nil, nil, errors.New("Missing title")
+	}
+
+	if !p.hasDate && !p.hasTime && p.recurrence == "" {
+		return nil, nil, errors.New("Could not find a date or time in the text")
+	}
+
+	if !p.hasTime {
+		p.setTime(9, 0)
+		notes = append(notes, "No time given, assumed 09:00")
+	}
+
+	first := p.date
+	if !p.hasDate {
+		first = p.today()
+		if p.at(first).Before(p.now) {
+			first = first.AddDate(0, 0, 1)
+		}
+	}
+
+	if p.recurrence == recurWeekly && !p.hasWeekday {
+		p.weekday = first.Weekday()
+	}
+
+	var days []time.Time
+	if p.recurrence == "" {
+		days = append(days, first)
+	} else {
+		for idx := range horizonDays {
+			day := first.AddDate(0, 0, idx)
+			if p.occursOn(day) && !p.at(day).Before(p.now) {
+				days = append(days, day)
+			}
+		}
+		notes = append(notes, fmt.Sprintf("Repeats %v, expanded over %v days", p.describeRecurrence(), horizonDays))
+	}
+
+	if len(days) == 0 {
+		return nil, nil, errors.New("No occurrences within the horizon")
+	}
+
+	if p.at(days[0]).Before(p.now) {
+		notes = append(notes, "The time is in the past")
+	}
+
+	title := strings.Join(p.title, " ")
+	var events []*Event
+	for _, day := range days {
+		events = append(events, &Event{
+			Id:              -1,
+			Title:           title,
+			EventTime:       p.at(day),
+			DurationMinutes: p.duration,
+			Location:        p.location,
+			Tags:            slices.Clone(p.tags),
+		})
+	}
+
+	return events, notes, nil
+}
+
+func (p *quickAddParser) summary(events []*Event) string {
+	first := events[0]
+	summary := fmt.Sprintf("%v on %v at %v", first.Title,
+		first.EventTime.Format("Mon, 02 Jan 2006"), first.EventTime.Format("15:04"))
+
+	if p.recurrence != "" {
+		summary = fmt.Sprintf("%v %v at %v starting %v (%v occurrences)", first.Title, p.describeRecurrence(),
+			first.EventTime.Format("15:04"), first.EventTime.Format("Mon, 02 Jan 2006"), len(events))
+	}
+
+	if first.DurationMinutes != 0 {
+		summary += " for " + formatMinutes(first.DurationMinutes)
+	}
+	if first.Location != "" {
+		summary += " @ " + first.Location
+	}
+
+	return summary
+}
+
+// POST /quick_add
+func quickAdd(req *quickAddRequest, now time.Time, actor string, userStore *Store[User]) (*QuickAddResult, error) {
+	loc, err := userLocation(req.TimeZone, req.UserId, userStore)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.HorizonDays == 0 {
+		req.HorizonDays = defaultQuickAddHorizon
+	}
+
+	parser := &quickAddParser{now: now.In(loc)}
+	parser.parse(req.Text)
+
+	events, notes, err := parser.events(req.HorizonDays)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &QuickAddResult{
+		Text:       req.Text,
+		Summary:    parser.summary(events),
+		TimeZone:   loc.String(),
+		Recurrence: parser.describeRecurrence(),
+		Notes:      notes,
+		Events:     events,
+	}
+
+	if req.Preview {
+		return result, nil
+	}
+
+	for _, event := range events {
+		if _, err := createEvent(req.UserId, event, actor, userStore); err != nil {
+			return nil, err
+		}
+	}
+	result.Committed = true
+
+	return result, nil
+}
+
+func HandleQuickAdd(w http.ResponseWriter, r *http.Request, userStore *Store[User]) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	req := &quickAddRequest{UserId: -1}
+	if err := json.Unmarshal(body, req); err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	if _, err := userStore.get(req.UserId); err != nil {
+		SendError(w, err, 404)
+		return
+	}
+
+	result, err := quickAdd(req, time.Now(), getActor(r, req.UserId), userStore)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	SendResult(w, result)
+}
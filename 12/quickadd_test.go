@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuickAdd(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	userStore := NewStore(func(u *User, id int) { u.Id = id })
+	userIdx := createUser("anna", "Europe/Moscow", "test", userStore)
+
+	// Tuesday
+	now := time.Date(2026, 3, 10, 10, 0, 0, 0, moscow)
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2026, 3, day, hour, minute, 0, 0, moscow)
+	}
+
+	tests := []struct {
+		text       string
+		title      string
+		first      time.Time
+		count      int
+		duration   int
+		recurrence string
+		location   string
+		tags       []string
+	}{
+		{text: "Lunch with Anna tomorrow 13:00 for 1h", title: "Lunch with Anna", first: at(11, 13, 0), count: 1, duration: 60},
+		{text: "Обед с Анной завтра в 13:00 на 1 час", title: "Обед с Анной", first: at(11, 13, 0), count: 1, duration: 60},
+		{text: "Standup every weekday 9:30", title: "Standup", first: at(11, 9, 30), count: 10, recurrence: "every weekday"},
+		{text: "Планёрка по будням в 9:30", title: "Планёрка", first: at(11, 9, 30), count: 10, recurrence: "every weekday"},
+		{text: "Планёрка каждый будний день в 9:30 на 15 минут", title: "Планёрка", first: at(11, 9, 30), count: 10, duration: 15, recurrence: "every weekday"},
+		{text: "Retro every friday 16:00 #team", title: "Retro", first: at(13, 16, 0), count: 2, recurrence: "every Friday", tags: []string{"team"}},
+		{text: "Ретро каждую пятницу в 16:00", title: "Ретро", first: at(13, 16, 0), count: 2, recurrence: "every Friday"},
+		{text: "Dentist on friday at 5pm @clinic", title: "Dentist", first: at(13, 17, 0), count: 1, location: "clinic"},
+		{text: "Call 25 march 14:00", title: "Call", first: at(25, 14, 0), count: 1},
+		{text: "Звонок 25 марта в 14:00", title: "Звонок", first: at(25, 14, 0), count: 1},
+		{text: "Review in 2 hours", title: "Review", first: at(10, 12, 0), count: 1},
+		{text: "Ревью через 30 минут", title: "Ревью", first: at(10, 10, 30), count: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			req := &quickAddRequest{UserId: userIdx, Text: tt.text, Preview: true, HorizonDays: 14}
+			result, err := quickAdd(req, now, "test", userStore)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.TimeZone != "Europe/Moscow" {
+				t.Errorf("Time zone = %v, want the one of the user", result.TimeZone)
+			}
+			if len(result.Events) != tt.count {
+				t.Fatalf("Got %v events, want %v: %+v", len(result.Events), tt.count, result)
+			}
+			if result.Recurrence != tt.recurrence {
+				t.Errorf("Recurrence = %q, want %q", result.Recurrence, tt.recurrence)
+			}
+
+			first := result.Events[0]
+			if first.Title != tt.title || !first.EventTime.Equal(tt.first) || first.DurationMinutes != tt.duration {
+				t.Errorf("First event = %q at %v for %v min, want %q at %v for %v min",
+					first.Title, first.EventTime, first.DurationMinutes, tt.title, tt.first, tt.duration)
+			}
+			if first.Location != tt.location || len(first.Tags) != len(tt.tags) {
+				t.Errorf("First event @ %q #%v, want @ %q #%v", first.Location, first.Tags, tt.location, tt.tags)
+			}
+		})
+	}
+}
+
+func TestQuickAddTimeZone(t *testing.T) {
+	userStore := NewStore(func(u *User, id int) { u.Id = id })
+	userIdx := createUser("anna", "", "test", userStore)
+	now := time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		timeZone string
+		user     string
+		want     time.Time
+	}{
+		{name: "utc by default", want: time.Date(2026, 3, 11, 13, 0, 0, 0, time.UTC)},
+		{name: "time zone of the user", user: "Asia/Tokyo", want: time.Date(2026, 3, 11, 4, 0, 0, 0, time.UTC)},
+		{name: "time zone of the request", user: "Asia/Tokyo", timeZone: "America/New_York", want: time.Date(2026, 3, 11, 17, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := setTimeZone(userIdx, tt.user, "test", userStore); err != nil {
+				t.Fatal(err)
+			}
+
+			req := &quickAddRequest{UserId: userIdx, Text: "Lunch tomorrow 13:00", TimeZone: tt.timeZone, Preview: true}
+			result, err := quickAdd(req, now, "test", userStore)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := result.Events[0].EventTime; !got.Equal(tt.want) {
+				t.Errorf("Event at %v, want %v", got.UTC(), tt.want)
+			}
+		})
+	}
+
+	if _, err := quickAdd(&quickAddRequest{UserId: userIdx, Text: "Lunch tomorrow 13:00", TimeZone: "Mars/Olympus"}, now, "test", userStore); err == nil {
+		t.Error("quickAdd() with an unknown time zone succeeded, want error")
+	}
+}
@@ -0,0 +1,88 @@
+// Fills the wire field of the proto golden cases with the encoding produced by
+// google.golang.org/protobuf from calendar.proto, so the hand written codec of the
+// server is checked against the real implementation:
+//
+//	go run -C testdata/protogolden . ../../calendar.proto ../proto_golden.json
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+type goldenCase struct {
+	Name    string          `json:"name"`
+	Message string          `json:"message"`
+	Value   json.RawMessage `json:"value"`
+	Wire    string          `json:"wire"`
+}
+
+func run(protoPath string, goldenPath string) error {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			ImportPaths: []string{filepath.Dir(protoPath)},
+		}),
+	}
+
+	files, err := compiler.Compile(context.Background(), filepath.Base(protoPath))
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(goldenPath)
+	if err != nil {
+		return err
+	}
+
+	var cases []*goldenCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		return err
+	}
+
+	for _, c := range cases {
+		desc := files[0].Messages().ByName(protoreflect.Name(c.Message))
+		if desc == nil {
+			return fmt.Errorf("%v: no message %v in %v", c.Name, c.Message, protoPath)
+		}
+
+		msg := dynamicpb.NewMessage(desc)
+		if err := protojson.Unmarshal(c.Value, msg); err != nil {
+			return fmt.Errorf("%v: %w", c.Name, err)
+		}
+
+		wire, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("%v: %w", c.Name, err)
+		}
+		c.Wire = hex.EncodeToString(wire)
+	}
+
+	out, err := json.MarshalIndent(cases, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(goldenPath, append(out, '\n'), 0o644)
+}
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Println("Usage: protogolden calendar.proto golden.json")
+		os.Exit(2)
+	}
+
+	if err := run(os.Args[1], os.Args[2]); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"path"
+)
+
+const uiPrefix = "/ui/"
+
+//go:embed ui
+var uiFiles embed.FS
+
+// Serves the bundled single-page UI, paths that aren't files fall back to
+// index.html so that the client side routing works after a reload
+func UIHandler() http.Handler {
+	root, _ := fs.Sub(uiFiles, "ui")
+	files := http.FileServerFS(root)
+
+	return http.StripPrefix(uiPrefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)[1:]
+		if name == "" {
+			name = "index.html"
+		}
+
+		if _, err := fs.Stat(root, name); err != nil {
+			http.ServeFileFS(w, r, root, "index.html")
+			return
+		}
+
+		files.ServeHTTP(w, r)
+	}))
+}
@@ -0,0 +1,71 @@
+"use strict";
+
+const $ = (id) => document.getElementById(id);
+
+function params() {
+  const query = new URLSearchParams({ user_id: $("user").value, date: $("date").value });
+  if ($("zone").value) {
+    query.set("time_zone", $("zone").value);
+  }
+  return query;
+}
+
+function showError(target, err) {
+  target.textContent = err;
+  target.className = "error";
+}
+
+async function loadAgenda() {
+  const resp = await fetch("/views/agenda?" + params());
+  const text = await resp.text();
+  if (!resp.ok) {
+    showError($("agenda"), JSON.parse(text).error);
+    return;
+  }
+  $("agenda").className = "";
+  $("agenda").textContent = text;
+}
+
+async function quickAdd(preview) {
+  const body = {
+    user_id: Number($("user").value),
+    text: $("text").value,
+    preview: preview,
+  };
+  if ($("zone").value) {
+    body.time_zone = $("zone").value;
+  }
+
+  const resp = await fetch("/quick_add", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(body),
+  });
+  const data = await resp.json();
+  if (!resp.ok) {
+    showError($("interpretation"), data.error);
+    return;
+  }
+
+  const result = data.result;
+  $("interpretation").className = "";
+  $("interpretation").textContent = (result.committed ? "Added: " : "Would add: ") + result.summary +
+    (result.notes ? " (" + result.notes.join("; ") + ")" : "");
+
+  if (result.committed) {
+    $("text").value = "";
+    loadAgenda();
+  }
+}
+
+$("date").value = new Date().toISOString().slice(0, 10);
+for (const id of ["user", "date", "zone"]) {
+  $(id).addEventListener("change", loadAgenda);
+}
+$("preview").addEventListener("click", () => quickAdd(true));
+$("quick-add").addEventListener("submit", (e) => {
+  e.preventDefault();
+  quickAdd(false);
+});
+
+loadAgenda();
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+type GridDay struct {
+	Date  string           `json:"date"`
+	Hours map[int][]*Event `json:"hours"`
+}
+
+type Grid struct {
+	Start string     `json:"start"`
+	End   string     `json:"end"`
+	Days  []*GridDay `json:"days"`
+}
+
+type viewQuery struct {
+	userIdx   int
+	date      time.Time
+	rangeName string
+	tags      []string
+}
+
+type monthCell struct {
+	Day    int
+	Events []*Event
+}
+
+type timelineRow struct {
+	Hour  int
+	Cells [][]*Event
+}
+
+var viewFuncs = template.FuncMap{
+	"clock": func(t time.Time, loc *time.Location) string { return t.In(loc).Format("15:04") },
+}
+
+var monthTemplate = template.Must(template.New("month").Funcs(viewFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+table { border-collapse: collapse; width: 100%; table-layout: fixed; }
+th, td { border: 1px solid #ccc; vertical-align: top; padding: 4px; }
+td { height: 6em; }
+.day { font-weight: bold; }
+.event { font-size: 0.85em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<table>
+<tr>{{range .Weekdays}}<th>{{.}}</th>{{end}}</tr>
+{{range .Weeks}}<tr>{{range .}}<td>{{if .Day}}<div class="day">{{.Day}}</div>{{range .Events}}<div class="event">{{clock .EventTime $.Location}} {{.Title}}</div>{{end}}{{end}}</td>{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+var weekTemplate = template.Must(template.New("week").Funcs(viewFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+table { border-collapse: collapse; width: 100%; table-layout: fixed; }
+th, td { border: 1px solid #ccc; vertical-align: top; padding: 2px 4px; }
+th.hour { width: 4em; }
+.event { font-size: 0.85em; background: #def; margin: 1px 0; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<table>
+<tr><th class="hour"></th>{{range .Days}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr><th class="hour">{{printf "%02d:00" .Hour}}</th>{{range .Cells}}<td>{{range .}}<div class="event">{{clock .EventTime $.Location}} {{.Title}}</div>{{end}}</td>{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func parseViewQuery(r *http.Request, userStore *Store[User]) (*viewQuery, error) {
+	userIdx, err := parseUserIdxQuery(r)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := userLocation(r.URL.Query().Get("time_zone"), userIdx, userStore)
+	if err != nil {
+		return nil, err
+	}
+
+	date, err := time.ParseInLocation(time.DateOnly, r.URL.Query().Get("date"), loc)
+	if err != nil {
+		return nil, err
+	}
+
+	rangeName := r.URL.Query().Get("range")
+	if rangeName == "" {
+		rangeName = "week"
+	}
+
+	if rangeName != "week" && rangeName != "month" {
+		return nil, errors.New("Range must be week or month")
+	}
+
+	return &viewQuery{userIdx: userIdx, date: date, rangeName: rangeName, tags: parseTags(r)}, nil
+}
+
+func weekStart(date time.Time) time.Time {
+	year, month, day := date.Date()
+	return time.Date(year, month, day-(int(date.Weekday())+6)%7, 0, 0, 0, 0, date.Location())
+}
+
+func monthStart(date time.Time) time.Time {
+	year, month, _ := date.Date()
+	return time.Date(year, month, 1, 0, 0, 0, 0, date.Location())
+}
+
+// First day and number of days covered by the view together with its events sorted by time
+func viewEvents(query *viewQuery, userStore *Store[User]) (time.Time, int, []*Event, error) {
+	var start time.Time
+	var days int
+	var events []*Event
+	var err error
+
+	if query.rangeName == "month" {
+		start = monthStart(query.date)
+		days = start.AddDate(0, 1, -1).Day()
+		events, err = eventsForMonth(query.userIdx, query.date, userStore)
+	} else {
+		start = weekStart(query.date)
+		days = 7
+		events, err = eventsForWeek(query.userIdx, query.date, userStore)
+	}
+
+	if err != nil {
+		return start, 0, nil, err
+	}
+
+	events = filterByTags(events, query.tags)
+	slices.SortFunc(events, func(a, b *Event) int {
+		return a.EventTime.Compare(b.EventTime)
+	})
+
+	return start, days, events, nil
+}
+
+// Day index of the event relative to start, -1 if it is outside of the view
+func dayIndex(start time.Time, days int, ev *Event) int {
+	year, month, day := ev.EventTime.In(start.Location()).Date()
+	evDay := time.Date(year, month, day, 0, 0, 0, 0, start.Location())
+
+	for idx := range days {
+		if start.AddDate(0, 0, idx).Equal(evDay) {
+			return idx
+		}
+	}
+
+	return -1
+}
+
+func buildGrid(start time.Time, days int, events []*Event) *Grid {
+	grid := &Grid{
+		Start: start.Format(time.DateOnly),
+		End:   start.AddDate(0, 0, days-1).Format(time.DateOnly),
+		Days:  make([]*GridDay, days),
+	}
+
+	for idx := range days {
+		grid.Days[idx] = &GridDay{
+			Date:  start.AddDate(0, 0, idx).Format(time.DateOnly),
+			Hours: make(map[int][]*Event),
+		}
+	}
+
+	for _, ev := range events {
+		if idx := dayIndex(start, days, ev); idx != -1 {
+			hour := ev.EventTime.In(start.Location()).Hour()
+			grid.Days[idx].Hours[hour] = append(grid.Days[idx].Hours[hour], ev)
+		}
+	}
+
+	return grid
+}
+
+func renderAgenda(w io.Writer, start time.Time, days int, events []*Event) {
+	byDay := make([][]*Event, days)
+	for _, ev := range events {
+		if idx := dayIndex(start, days, ev); idx != -1 {
+			byDay[idx] = append(byDay[idx], ev)
+		}
+	}
+
+	empty := true
+	for idx := range days {
+		if len(byDay[idx]) == 0 {
+			continue
+		}
+
+		if !empty {
+			fmt.Fprintln(w)
+		}
+		empty = false
+
+		fmt.Fprintln(w, start.AddDate(0, 0, idx).Format("Mon, 02 Jan 2006"))
+		for _, ev := range byDay[idx] {
+			line := fmt.Sprintf("  %v  %v", ev.EventTime.In(start.Location()).Format("15:04"), ev.Title)
+			if ev.DurationMinutes != 0 {
+				line += " (" + formatMinutes(ev.DurationMinutes) + ")"
+			}
+			if ev.Location != "" {
+				line += " @ " + ev.Location
+			}
+			if len(ev.Tags) != 0 {
+				line += " [" + strings.Join(ev.Tags, ", ") + "]"
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	if empty {
+		fmt.Fprintf(w, "No events from %v to %v\n",
+			start.Format(time.DateOnly), start.AddDate(0, 0, days-1).Format(time.DateOnly))
+	}
+}
+
+// 90 -> "1h30m"
+func formatMinutes(minutes int) string {
+	if minutes < 60 {
+		return fmt.Sprintf("%vm", minutes)
+	}
+	if minutes%60 == 0 {
+		return fmt.Sprintf("%vh", minutes/60)
+	}
+	return fmt.Sprintf("%vh%vm", minutes/60, minutes%60)
+}
+
+func weekdayNames() []string {
+	return []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+}
+
+func renderMonth(w io.Writer, start time.Time, days int, events []*Event) error {
+	grid := buildGrid(start, days, events)
+
+	// Leading cells of the first week belong to the previous month
+	var cells []monthCell
+	for range (int(start.Weekday()) + 6) % 7 {
+		cells = append(cells, monthCell{})
+	}
+
+	for idx, day := range grid.Days {
+		cell := monthCell{Day: idx + 1}
+		for hour := range 24 {
+			cell.Events = append(cell.Events, day.Hours[hour]...)
+		}
+		cells = append(cells, cell)
+	}
+
+	for len(cells)%7 != 0 {
+		cells = append(cells, monthCell{})
+	}
+
+	var weeks [][]monthCell
+	for idx := 0; idx < len(cells); idx += 7 {
+		weeks = append(weeks, cells[idx:idx+7])
+	}
+
+	return monthTemplate.Execute(w, map[string]interface{}{
+		"Title":    start.Format("January 2006"),
+		"Location": start.Location(),
+		"Weekdays": weekdayNames(),
+		"Weeks":    weeks,
+	})
+}
+
+func renderWeek(w io.Writer, start time.Time, events []*Event) error {
+	grid := buildGrid(start, 7, events)
+
+	var dayNames []string
+	for idx, name := range weekdayNames() {
+		dayNames = append(dayNames, name+" "+start.AddDate(0, 0, idx).Format("02 Jan"))
+	}
+
+	rows := make([]timelineRow, 24)
+	for hour := range rows {
+		rows[hour].Hour = hour
+		for _, day := range grid.Days {
+			rows[hour].Cells = append(rows[hour].Cells, day.Hours[hour])
+		}
+	}
+
+	return weekTemplate.Execute(w, map[string]interface{}{
+		"Title":    fmt.Sprintf("Week of %v", start.Format("02 Jan 2006")),
+		"Location": start.Location(),
+		"Days":     dayNames,
+		"Rows":     rows,
+	})
+}
+
+// The page is rendered in full first, so a failing template gives an error instead of half a page
+func sendHtml(w http.ResponseWriter, render func(out io.Writer) error) {
+	var page bytes.Buffer
+	if err := render(&page); err != nil {
+		SendError(w, err, 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page.Bytes())
+}
+
+// GET /views/agenda
+func HandleAgendaView(w http.ResponseWriter, r *http.Request, userStore *Store[User]) {
+	query, err := parseViewQuery(r, userStore)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	start, days, events, err := viewEvents(query, userStore)
+	if err != nil {
+		SendError(w, err, 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	renderAgenda(w, start, days, events)
+}
+
+// GET /views/month
+func HandleMonthView(w http.ResponseWriter, r *http.Request, userStore *Store[User]) {
+	query, err := parseViewQuery(r, userStore)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+	query.rangeName = "month"
+
+	start, days, events, err := viewEvents(query, userStore)
+	if err != nil {
+		SendError(w, err, 500)
+		return
+	}
+
+	sendHtml(w, func(out io.Writer) error { return renderMonth(out, start, days, events) })
+}
+
+// GET /views/week
+func HandleWeekView(w http.ResponseWriter, r *http.Request, userStore *Store[User]) {
+	query, err := parseViewQuery(r, userStore)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+	query.rangeName = "week"
+
+	start, _, events, err := viewEvents(query, userStore)
+	if err != nil {
+		SendError(w, err, 500)
+		return
+	}
+
+	sendHtml(w, func(out io.Writer) error { return renderWeek(out, start, events) })
+}
+
+// GET /views/grid
+func HandleGridView(w http.ResponseWriter, r *http.Request, userStore *Store[User]) {
+	query, err := parseViewQuery(r, userStore)
+	if err != nil {
+		SendError(w, err, 400)
+		return
+	}
+
+	start, days, events, err := viewEvents(query, userStore)
+	if err != nil {
+		SendError(w, err, 500)
+		return
+	}
+
+	SendResult(w, buildGrid(start, days, events))
+}
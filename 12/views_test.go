@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newViewTestStore(t *testing.T) (*Store[User], int) {
+	t.Helper()
+
+	userStore := NewStore(func(u *User, id int) { u.Id = id })
+	userIdx := createUser("anna", "Europe/Moscow", "test", userStore)
+
+	events := []*Event{
+		{Title: "Standup", EventTime: time.Date(2026, 3, 9, 6, 30, 0, 0, time.UTC), Tags: []string{"team"}},
+		{Title: "Lunch <b>", EventTime: time.Date(2026, 3, 11, 10, 0, 0, 0, time.UTC), DurationMinutes: 90, Location: "Café"},
+		{Title: "Retro", EventTime: time.Date(2026, 3, 20, 13, 0, 0, 0, time.UTC), Tags: []string{"team"}},
+		{Title: "April fools", EventTime: time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, event := range events {
+		event.Id = -1
+		if _, err := createEvent(userIdx, event, "test", userStore); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return userStore, userIdx
+}
+
+func serveView(handler func(http.ResponseWriter, *http.Request, *Store[User]), userStore *Store[User], query string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/views?"+query, nil), userStore)
+	return w
+}
+
+func TestViews(t *testing.T) {
+	userStore, _ := newViewTestStore(t)
+
+	tests := []struct {
+		name        string
+		handler     func(http.ResponseWriter, *http.Request, *Store[User])
+		query       string
+		contentType string
+		want        []string
+		notWant     []string
+	}{
+		{
+			name:        "agenda of a week",
+			handler:     HandleAgendaView,
+			query:       "user_id=0&date=2026-03-10",
+			contentType: "text/plain",
+			want:        []string{"Mon, 09 Mar 2026\n  09:30  Standup [team]\n", "Wed, 11 Mar 2026\n  13:00  Lunch <b> (1h30m) @ Café\n"},
+			notWant:     []string{"Retro"},
+		},
+		{
+			name:    "agenda of a month",
+			handler: HandleAgendaView,
+			query:   "user_id=0&date=2026-03-10&range=month",
+			want:    []string{"Standup", "Fri, 20 Mar 2026\n  16:00  Retro [team]\n"},
+			notWant: []string{"April"},
+		},
+		{
+			name:    "agenda by tags",
+			handler: HandleAgendaView,
+			query:   "user_id=0&date=2026-03-10&range=month&tags=team",
+			want:    []string{"Standup", "Retro"},
+			notWant: []string{"Lunch"},
+		},
+		{
+			name:    "agenda in another time zone",
+			handler: HandleAgendaView,
+			query:   "user_id=0&date=2026-03-10&time_zone=UTC",
+			want:    []string{"06:30  Standup"},
+		},
+		{
+			name:    "empty agenda",
+			handler: HandleAgendaView,
+			query:   "user_id=0&date=2026-05-10",
+			want:    []string{"No events from 2026-05-04 to 2026-05-10\n"},
+		},
+		{
+			name:        "month",
+			handler:     HandleMonthView,
+			query:       "user_id=0&date=2026-03-10",
+			contentType: "text/html",
+			want:        []string{"<title>March 2026</title>", "13:00 Lunch &lt;b&gt;", "16:00 Retro", `<div class="day">31</div>`},
+			notWant:     []string{"<b>", "April", `<div class="day">32</div>`},
+		},
+		{
+			name:        "week",
+			handler:     HandleWeekView,
+			query:       "user_id=0&date=2026-03-10&range=month",
+			contentType: "text/html",
+			want:        []string{"Week of 09 Mar 2026", "Mon 09 Mar", "Sun 15 Mar", "09:30 Standup", "13:00 Lunch &lt;b&gt;"},
+			notWant:     []string{"Retro"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveView(tt.handler, userStore, tt.query)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Status = %v: %v", w.Code, w.Body)
+			}
+			if !strings.HasPrefix(w.Header().Get("Content-Type"), tt.contentType) {
+				t.Errorf("Content type = %v, want %v", w.Header().Get("Content-Type"), tt.contentType)
+			}
+
+			body := w.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("View has no %q: %v", want, body)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(body, notWant) {
+					t.Errorf("View has %q: %v", notWant, body)
+				}
+			}
+		})
+	}
+}
+
+func TestGridView(t *testing.T) {
+	userStore, _ := newViewTestStore(t)
+
+	w := serveView(HandleGridView, userStore, "user_id=0&date=2026-03-15")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %v: %v", w.Code, w.Body)
+	}
+
+	var report struct {
+		Result *Grid `json:"result"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	grid := report.Result
+	if grid.Start != "2026-03-09" || grid.End != "2026-03-15" || len(grid.Days) != 7 {
+		t.Fatalf("Grid from %v to %v with %v days, want the week from 2026-03-09", grid.Start, grid.End, len(grid.Days))
+	}
+	if events := grid.Days[0].Hours[9]; len(events) != 1 || events[0].Title != "Standup" {
+		t.Errorf("Monday 9:00 = %+v, want the standup", events)
+	}
+	if events := grid.Days[2].Hours[13]; len(events) != 1 || events[0].Title != "Lunch <b>" {
+		t.Errorf("Wednesday 13:00 = %+v, want the lunch", events)
+	}
+}
+
+func TestViewErrors(t *testing.T) {
+	userStore, _ := newViewTestStore(t)
+
+	tests := []struct {
+		name  string
+		query string
+		code  int
+	}{
+		{name: "no user", query: "date=2026-03-10", code: 400},
+		{name: "no date", query: "user_id=0", code: 400},
+		{name: "invalid date", query: "user_id=0&date=10.03.2026", code: 400},
+		{name: "unknown range", query: "user_id=0&date=2026-03-10&range=year", code: 400},
+		{name: "unknown time zone", query: "user_id=0&date=2026-03-10&time_zone=Mars/Olympus", code: 400},
+		// The events are looked up as by /events_for_week, which fails the same way
+		{name: "unknown user", query: "user_id=7&date=2026-03-10&time_zone=UTC", code: 500},
+	}
+
+	handlers := map[string]func(http.ResponseWriter, *http.Request, *Store[User]){
+		"agenda": HandleAgendaView,
+		"month":  HandleMonthView,
+		"week":   HandleWeekView,
+		"grid":   HandleGridView,
+	}
+
+	for _, tt := range tests {
+		for name, handler := range handlers {
+			if w := serveView(handler, userStore, tt.query); w.Code != tt.code {
+				t.Errorf("%v view with %v: status %v, want %v", name, tt.name, w.Code, tt.code)
+			}
+		}
+	}
+}
+
+func TestSendHtmlError(t *testing.T) {
+	w := httptest.NewRecorder()
+	sendHtml(w, func(out io.Writer) error {
+		io.WriteString(out, "<html><body>half")
+		return errors.New("template failed")
+	})
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Status = %v, want 500", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "half") {
+		t.Errorf("Body has the partial page: %v", w.Body)
+	}
+}